@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+)
+
+const accountLinkCollection = "account_links"
+
+// accountLink records the outcome of LINE's account link flow for a user.
+// The nonce is the one the external service issued, so it can look up
+// which of its accounts the LINE user was bound to.
+type accountLink struct {
+	Nonce     string    `firestore:"nonce"`
+	Result    string    `firestore:"result"`
+	UpdatedAt time.Time `firestore:"updated_at"`
+}
+
+// handleAccountLinkEvent stores the link result for the user. LINE omits
+// the reply token when linking failed, so failures are only recorded.
+func handleAccountLinkEvent(ctx context.Context, bot botClient, e webhook.AccountLinkEvent) {
+	userID, ok := extractUserID(e.Source)
+	if !ok || e.Link == nil {
+		log.Printf("Ignoring account link event without user or link: %+v", e)
+		return
+	}
+
+	link := accountLink{
+		Nonce:     e.Link.Nonce,
+		Result:    string(e.Link.Result),
+		UpdatedAt: time.Now(),
+	}
+	if _, err := firestoreClient.Collection(accountLinkCollection).Doc(userID).Set(ctx, link); err != nil {
+		log.Printf("Failed to save account link for user %s: %v", userID, err)
+	}
+
+	if e.Link.Result != webhook.LinkContentRESULT_OK {
+		log.Printf("Account link failed for user %s", userID)
+		return
+	}
+	log.Printf("Account linked for user %s", userID)
+	if e.ReplyToken != "" {
+		replyText(bot, e.ReplyToken, tr(ctx, userID, "accountlink.done"))
+	}
+}
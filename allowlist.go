@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// allowedUsersCollection lists user IDs allowed to use a private bot when
+// ALLOWLIST_FIRESTORE is enabled. Each document ID is a LINE user ID.
+const allowedUsersCollection = "allowed_users"
+
+// parseAllowlist turns a comma-separated list of user IDs into a set.
+func parseAllowlist(v string) map[string]bool {
+	users := map[string]bool{}
+	for _, id := range strings.Split(v, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			users[id] = true
+		}
+	}
+	return users
+}
+
+// allowlistConfigured reports whether the bot is private. Without an
+// allowlist it is open to everyone.
+func allowlistConfigured() bool {
+	return len(allowlistUsers) > 0 || allowlistFirestore
+}
+
+// isUserAllowed reports whether the user may use the bot. With no
+// allowlist configured every user is allowed.
+func isUserAllowed(ctx context.Context, userID string) bool {
+	if !allowlistConfigured() {
+		return true
+	}
+	if allowlistUsers[userID] {
+		return true
+	}
+	if !allowlistFirestore {
+		return false
+	}
+
+	_, err := firestoreClient.Collection(allowedUsersCollection).Doc(userID).Get(ctx)
+	if err != nil {
+		if status.Code(err) != codes.NotFound {
+			log.Printf("Failed to check allowlist for user %s: %v", userID, err)
+		}
+		return false
+	}
+	return true
+}
+
+// rejectIfNotAllowed replies to message and postback events from users who
+// are not on the allowlist and reports whether the event was rejected.
+func rejectIfNotAllowed(ctx context.Context, bot botClient, event webhook.EventInterface) bool {
+	var src webhook.SourceInterface
+	var replyToken string
+	switch e := event.(type) {
+	case webhook.MessageEvent:
+		src, replyToken = e.Source, e.ReplyToken
+	case webhook.PostbackEvent:
+		src, replyToken = e.Source, e.ReplyToken
+	default:
+		return false
+	}
+	// An open bot lets group messages without a user ID through, so they
+	// get the unknown sender reply instead.
+	if !allowlistConfigured() {
+		return false
+	}
+
+	userID, ok := extractUserID(src)
+	if ok && isUserAllowed(ctx, userID) {
+		return false
+	}
+	log.Printf("Rejected event from user %q not on the allowlist", userID)
+	replyText(bot, replyToken, tr(ctx, userID, "common.not_allowed"))
+	return true
+}
+
+// isDirectChat reports whether the event comes from a one-to-one chat.
+func isDirectChat(src webhook.SourceInterface) bool {
+	_, ok := src.(webhook.UserSource)
+	return ok
+}
+
+// extractUserID returns the ID of the user who triggered an event, for
+// one-to-one chats as well as groups and rooms.
+func extractUserID(src webhook.SourceInterface) (string, bool) {
+	var userID string
+	switch s := src.(type) {
+	case webhook.UserSource:
+		userID = s.UserId
+	case webhook.GroupSource:
+		userID = s.UserId
+	case webhook.RoomSource:
+		userID = s.UserId
+	}
+	return userID, userID != ""
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+)
+
+// TestIsUserAllowed tests the env-based allowlist.
+func TestIsUserAllowed(t *testing.T) {
+	ctx := context.Background()
+	defer func() { allowlistUsers = nil }()
+
+	allowlistUsers = parseAllowlist("")
+	if !isUserAllowed(ctx, "U1") {
+		t.Error("Expected every user to be allowed without an allowlist")
+	}
+
+	allowlistUsers = parseAllowlist("U1, U2")
+	if !isUserAllowed(ctx, "U2") {
+		t.Error("Expected listed user to be allowed")
+	}
+	if isUserAllowed(ctx, "U3") {
+		t.Error("Expected unlisted user to be rejected")
+	}
+}
+
+// TestExtractUserID tests reading the sender from each source type.
+func TestExtractUserID(t *testing.T) {
+	tests := []struct {
+		src    webhook.SourceInterface
+		want   string
+		ok     bool
+		direct bool
+	}{
+		{webhook.UserSource{UserId: "U1"}, "U1", true, true},
+		{webhook.GroupSource{GroupId: "G1", UserId: "U2"}, "U2", true, false},
+		{webhook.RoomSource{RoomId: "R1", UserId: "U3"}, "U3", true, false},
+		{webhook.GroupSource{GroupId: "G1"}, "", false, false},
+		{nil, "", false, false},
+	}
+	for _, tt := range tests {
+		got, ok := extractUserID(tt.src)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("extractUserID(%#v) = %q, %v; want %q, %v", tt.src, got, ok, tt.want, tt.ok)
+		}
+		if direct := isDirectChat(tt.src); direct != tt.direct {
+			t.Errorf("isDirectChat(%#v) = %v; want %v", tt.src, direct, tt.direct)
+		}
+	}
+}
+
+// TestRejectIfNotAllowedWithoutUserID tests that group messages without a
+// user ID pass an open bot and are rejected by a private one.
+func TestRejectIfNotAllowedWithoutUserID(t *testing.T) {
+	defer func() { allowlistUsers = nil }()
+	event := webhook.MessageEvent{ReplyToken: "r1", Source: webhook.GroupSource{GroupId: "G1"}, Message: webhook.TextMessageContent{Text: "/help"}}
+
+	bot := newFakeBot()
+	allowlistUsers = nil
+	if rejectIfNotAllowed(context.Background(), bot, event) || len(bot.replies) != 0 {
+		t.Errorf("Expected the open bot to let the event through, but got: %+v", bot.replies)
+	}
+
+	allowlistUsers = parseAllowlist("U1")
+	if !rejectIfNotAllowed(context.Background(), bot, event) || len(bot.replies) != 1 {
+		t.Errorf("Expected the private bot to reject the event, but got: %+v", bot.replies)
+	}
+}
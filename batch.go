@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+)
+
+// splitEventBatch returns the events to handle before acknowledging the
+// webhook and the ones to handle afterwards. A cap of 0 or less handles
+// everything inline.
+func splitEventBatch(events []webhook.EventInterface, inlineCap int) (inline, queued []webhook.EventInterface) {
+	if inlineCap <= 0 || len(events) <= inlineCap {
+		return events, nil
+	}
+	return events[:inlineCap], events[inlineCap:]
+}
+
+// handleInlineEvents handles the events answered before the webhook
+// returns. Media messages a user sent together, such as an album, get one
+// summary reply instead of one reply each.
+//
+// All events share one eventTimeout budget so the webhook answers LINE in
+// time however many events it carries. The events not started within it
+// are returned for handleQueuedEvents.
+func handleInlineEvents(ctx context.Context, bot botClient, blob blobClient, host string, events []webhook.EventInterface) (deferred []webhook.EventInterface) {
+	var allowed []webhook.EventInterface
+	for _, event := range events {
+		if !rejectIfNotAllowed(ctx, bot, event) {
+			allowed = append(allowed, event)
+		}
+	}
+
+	allowed, batches := batchMediaEvents(ctx, allowed)
+	inlineCtx, cancel := withEventTimeout(ctx)
+	defer cancel()
+	for i, event := range allowed {
+		if inlineCtx.Err() != nil {
+			deferred = allowed[i:]
+			break
+		}
+		handleEventOnce(inlineCtx, bot, blob, host, event)
+	}
+	for _, event := range deferred {
+		// Let the batch summary say these are still on their way.
+		if e, ok := event.(webhook.MessageEvent); ok {
+			userID, _ := extractUserID(e.Source)
+			markBatchItemPending(e.ReplyToken, userID)
+		}
+	}
+	for _, id := range batches {
+		flushUploadBatch(ctx, bot, id)
+	}
+	return deferred
+}
+
+// handleQueuedEvents processes the events left over from a large batch
+// after the webhook was acknowledged. Their reply tokens may expire
+// before we get to them, so media results are pushed instead.
+//
+// On platforms that throttle CPU outside of requests (e.g. Cloud Run
+// without always-on CPU) this work may run slowly.
+func handleQueuedEvents(ctx context.Context, bot botClient, blob blobClient, host string, events []webhook.EventInterface) {
+	for _, event := range events {
+		if rejectIfNotAllowed(ctx, bot, event) {
+			continue
+		}
+		handleEventOnce(ctx, bot, blob, host, deferReplyForMedia(event))
+	}
+}
+
+// deferReplyForMedia swaps the reply token of a media message for one
+// that pushes to the sender.
+func deferReplyForMedia(event webhook.EventInterface) webhook.EventInterface {
+	e, ok := event.(webhook.MessageEvent)
+	if !ok {
+		return event
+	}
+	switch e.Message.(type) {
+	case webhook.ImageMessageContent, webhook.VideoMessageContent, webhook.AudioMessageContent, webhook.FileMessageContent:
+		// Only one-to-one chats get pushes; in groups the reply token
+		// is still tried.
+		if s, ok := e.Source.(webhook.UserSource); ok {
+			e.ReplyToken = deferredReplyToken(s.UserId)
+		}
+	}
+	return e
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+)
+
+// TestSplitEventBatch tests capping the events handled inline.
+func TestSplitEventBatch(t *testing.T) {
+	events := make([]webhook.EventInterface, 5)
+	tests := []struct {
+		cap                    int
+		wantInline, wantQueued int
+	}{
+		{0, 5, 0},
+		{5, 5, 0},
+		{3, 3, 2},
+	}
+	for _, tt := range tests {
+		inline, queued := splitEventBatch(events, tt.cap)
+		if len(inline) != tt.wantInline || len(queued) != tt.wantQueued {
+			t.Errorf("cap %d: expected %d/%d, but got: %d/%d", tt.cap, tt.wantInline, tt.wantQueued, len(inline), len(queued))
+		}
+	}
+}
+
+// TestDeferReplyForMedia tests that only media messages switch to push.
+func TestDeferReplyForMedia(t *testing.T) {
+	src := webhook.UserSource{UserId: "U1"}
+	media := deferReplyForMedia(webhook.MessageEvent{ReplyToken: "r1", Source: src, Message: webhook.ImageMessageContent{Id: "m1"}})
+	if got := media.(webhook.MessageEvent).ReplyToken; got != deferredReplyToken("U1") {
+		t.Errorf("Expected a deferred reply token, but got: %s", got)
+	}
+	text := deferReplyForMedia(webhook.MessageEvent{ReplyToken: "r2", Source: src, Message: webhook.TextMessageContent{Text: "hi"}})
+	if got := text.(webhook.MessageEvent).ReplyToken; got != "r2" {
+		t.Errorf("Expected the reply token to be kept, but got: %s", got)
+	}
+	group := deferReplyForMedia(webhook.MessageEvent{ReplyToken: "r3", Source: webhook.GroupSource{GroupId: "G1", UserId: "U1"}, Message: webhook.ImageMessageContent{Id: "m2"}})
+	if got := group.(webhook.MessageEvent).ReplyToken; got != "r3" {
+		t.Errorf("Expected group media to keep the reply token, but got: %s", got)
+	}
+}
+
+// TestHandleInlineEventsDeadline tests that events not started within the
+// shared budget are handed back, and an album's summary marks them as
+// still on their way.
+func TestHandleInlineEventsDeadline(t *testing.T) {
+	eventTimeout = time.Nanosecond
+	defer func() { eventTimeout = 9 * time.Second }()
+
+	bot := newFakeBot()
+	src := webhook.UserSource{UserId: "U1"}
+	events := []webhook.EventInterface{
+		webhook.MessageEvent{ReplyToken: "r1", Source: src, Message: webhook.ImageMessageContent{Id: "m1"}},
+		webhook.MessageEvent{ReplyToken: "r2", Source: src, Message: webhook.ImageMessageContent{Id: "m2"}},
+	}
+	deferred := handleInlineEvents(context.Background(), bot, nil, "example.com", events)
+	if len(deferred) != 2 {
+		t.Fatalf("Expected both events to be deferred, but got: %d", len(deferred))
+	}
+	if len(bot.replies) != 1 || !strings.Contains(strings.Join(bot.replies[0].texts, "\n"), "⏳") {
+		t.Errorf("Expected one summary marking the files as pending, but got: %+v", bot.replies)
+	}
+}
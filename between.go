@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// betweenMaxFiles caps /between results to what fits in one carousel.
+const betweenMaxFiles = 10
+
+// handleBetweenCommand lists files uploaded between two dates, inclusive.
+func handleBetweenCommand(ctx context.Context, bot botClient, replyToken, userID string, args []string) {
+	from, to, err := parseDateRange(args)
+	if err != nil {
+		replyText(bot, replyToken, tr(ctx, userID, "between.usage"))
+		return
+	}
+
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	files, more, err := listFilesBetween(ctx, srv, from, to, betweenMaxFiles)
+	if err != nil {
+		log.Printf("Failed to list files between %s and %s: %v", from, to, err)
+		replyForError(ctx, bot, replyToken, userID, err)
+		return
+	}
+
+	if len(files) == 0 {
+		replyText(bot, replyToken, trf(ctx, userID, "between.none", args[0], args[1]))
+		return
+	}
+
+	loadSharing(ctx, srv, files)
+	messages := []messaging_api.MessageInterface{
+		&messaging_api.FlexMessage{
+			AltText:  tr(ctx, userID, "between.alt"),
+			Contents: buildFilesCarousel(userLanguage(ctx, userID), files),
+		},
+	}
+	if more {
+		messages = append(messages, &messaging_api.TextMessage{
+			Text: trf(ctx, userID, "between.more", len(files)),
+		})
+	}
+	if err := sendReply(bot, replyToken, messages); err != nil {
+		log.Print(err)
+	}
+}
+
+// parseDateRange parses two YYYY-MM-DD dates in either order and returns
+// the half-open range [from, to) covering both days.
+func parseDateRange(args []string) (time.Time, time.Time, error) {
+	if len(args) != 2 {
+		return time.Time{}, time.Time{}, fmt.Errorf("expected 2 dates, got %d", len(args))
+	}
+	from, err := time.ParseInLocation("2006-01-02", args[0], botLocation)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := time.ParseInLocation("2006-01-02", args[1], botLocation)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if to.Before(from) {
+		from, to = to, from
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+// betweenQuery matches the files created in [from, to). With the
+// drive.file scope the app only sees files it created, so this covers the
+// whole upload folder tree without walking it.
+func betweenQuery(from, to time.Time) string {
+	return fmt.Sprintf("mimeType!='application/vnd.google-apps.folder' and trashed=false and createdTime >= '%s' and createdTime < '%s'",
+		from.Format(time.RFC3339), to.Format(time.RFC3339))
+}
+
+// listFilesBetween returns up to max files created in [from, to), newest
+// first, following result pages. more reports whether files were left out.
+func listFilesBetween(ctx context.Context, srv *drive.Service, from, to time.Time, max int) (files []*drive.File, more bool, err error) {
+	pageToken := ""
+	for {
+		call := scopeFileList(srv.Files.List()).
+			Q(betweenQuery(from, to)).
+			PageSize(int64(max - len(files))).
+			OrderBy("createdTime desc").
+			Fields(googleapi.Field("nextPageToken, " + listingFields()))
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		r, err := call.Context(ctx).Do()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to retrieve files: %w", err)
+		}
+		files = append(files, r.Files...)
+		if r.NextPageToken == "" {
+			return files, false, nil
+		}
+		if len(files) >= max {
+			return files[:max], true, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
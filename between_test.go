@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestParseDateRange tests parsing, reversed ranges and invalid input.
+func TestParseDateRange(t *testing.T) {
+	from, to, err := parseDateRange([]string{"2024-03-31", "2024-03-01"})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if got := from.Format("2006-01-02"); got != "2024-03-01" {
+		t.Errorf("Expected from 2024-03-01, but got: %s", got)
+	}
+	if got := to.Format("2006-01-02"); got != "2024-04-01" {
+		t.Errorf("Expected exclusive end 2024-04-01, but got: %s", got)
+	}
+
+	for _, args := range [][]string{{"2024-03-01"}, {"2024-03-01", "tomorrow"}, {"03/01/2024", "2024-03-02"}} {
+		if _, _, err := parseDateRange(args); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
+
+// TestListFilesBetween tests that results are paged up to the limit.
+func TestListFilesBetween(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("pageToken") == "" {
+			json.NewEncoder(w).Encode(&drive.FileList{
+				Files:         []*drive.File{{Id: "a"}, {Id: "b"}},
+				NextPageToken: "page2",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&drive.FileList{
+			Files:         []*drive.File{{Id: "c"}, {Id: "d"}},
+			NextPageToken: "page3",
+		})
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	now := time.Now()
+	files, more, err := listFilesBetween(context.Background(), srv, now.AddDate(0, -1, 0), now, 3)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if len(files) != 3 || !more {
+		t.Errorf("Expected 3 files with more=true, but got %d files with more=%v", len(files), more)
+	}
+}
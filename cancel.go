@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// activeUploads tracks the uploads /cancel can abort.
+var activeUploads = newUploadRegistry()
+
+// uploadRegistry maps userIDs to the cancel funcs of their running
+// uploads. A user may have several, e.g. when sending an album.
+type uploadRegistry struct {
+	mu      sync.Mutex
+	next    int
+	entries map[string]map[int]context.CancelFunc
+}
+
+func newUploadRegistry() *uploadRegistry {
+	return &uploadRegistry{entries: map[string]map[int]context.CancelFunc{}}
+}
+
+// start registers an upload for the user and returns the context it must
+// run under. The returned func must be called when the upload ends; it
+// removes the entry so finished uploads don't pile up.
+func (r *uploadRegistry) start(ctx context.Context, userID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	id := r.next
+	if r.entries[userID] == nil {
+		r.entries[userID] = map[int]context.CancelFunc{}
+	}
+	r.entries[userID][id] = cancel
+
+	return ctx, func() {
+		cancel()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.entries[userID], id)
+		if len(r.entries[userID]) == 0 {
+			delete(r.entries, userID)
+		}
+	}
+}
+
+// cancel aborts all of the user's running uploads and returns how many
+// there were.
+func (r *uploadRegistry) cancel(userID string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	uploads := r.entries[userID]
+	for _, cancel := range uploads {
+		cancel()
+	}
+	delete(r.entries, userID)
+	return len(uploads)
+}
+
+// handleCancelCommand aborts the user's in-progress uploads.
+func handleCancelCommand(ctx context.Context, bot botClient, replyToken, userID string) {
+	if activeUploads.cancel(userID) == 0 {
+		replyText(bot, replyToken, tr(ctx, userID, "cancel.nothing"))
+		return
+	}
+	replyText(bot, replyToken, tr(ctx, userID, "cancel.done"))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestCancelCommandAbortsUpload tests that /cancel aborts an upload
+// blocked on Drive and that the registry entry is gone afterwards.
+func TestCancelCommandAbortsUpload(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	ctx, done := activeUploads.start(context.Background(), "user1")
+	uploaded := make(chan error, 1)
+	go func() {
+		defer done()
+		_, err := createWithRetry(ctx, srv, &drive.File{Name: "video.mp4"}, strings.NewReader("data"))
+		uploaded <- err
+	}()
+	<-received
+
+	bot, texts := newReplyRecorder(t)
+	handleCancelCommand(context.Background(), bot, "token", "user1")
+
+	select {
+	case err := <-uploaded:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the upload to be cancelled, but got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The upload was not aborted")
+	}
+	if len(*texts) != 1 || (*texts)[0] != translate(langZh, "cancel.done") {
+		t.Errorf("Expected the cancelled reply, but got: %q", *texts)
+	}
+
+	handleCancelCommand(context.Background(), bot, "token2", "user1")
+	if len(*texts) != 2 || (*texts)[1] != translate(langZh, "cancel.nothing") {
+		t.Errorf("Expected the nothing in progress reply, but got: %q", *texts)
+	}
+}
+
+// TestUploadRegistryCleanup tests that finished uploads leave no entries
+// behind.
+func TestUploadRegistryCleanup(t *testing.T) {
+	r := newUploadRegistry()
+	_, done1 := r.start(context.Background(), "user1")
+	ctx2, done2 := r.start(context.Background(), "user1")
+
+	done1()
+	if ctx2.Err() != nil {
+		t.Error("Expected finishing one upload to leave the other running")
+	}
+	done2()
+	if len(r.entries) != 0 {
+		t.Errorf("Expected no entries after the uploads finished, but got: %v", r.entries)
+	}
+	if n := r.cancel("user1"); n != 0 {
+		t.Errorf("Expected nothing to cancel, but got %d", n)
+	}
+}
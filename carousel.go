@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"google.golang.org/api/drive/v3"
+)
+
+// listingFieldSelectors maps the extra file fields operators may request in
+// listings to the Drive API field selector used to fetch them.
+var listingFieldSelectors = map[string]string{
+	"createdTime":  "createdTime",
+	"modifiedTime": "modifiedTime",
+	"size":         "size",
+	"mimeType":     "mimeType",
+	"owners":       "owners(displayName)",
+	"description":  "description",
+}
+
+// parseListingFields validates a comma-separated list of field names
+// against listingFieldSelectors, dropping unknown entries.
+func parseListingFields(v string) []string {
+	var fields []string
+	for _, f := range strings.Split(v, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if _, ok := listingFieldSelectors[f]; !ok {
+			log.Printf("Ignoring unsupported listing field %q", f)
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// listingFields returns the Drive "files(...)" selector for listings. The
+// id, name, mimeType, webViewLink, parents, shared and thumbnailLink
+// fields are always requested.
+func listingFields() string {
+	selectors := []string{"id", "name", "mimeType", "webViewLink", "parents", "shared", "thumbnailLink"}
+	for _, f := range listingExtraFields {
+		if f != "mimeType" {
+			selectors = append(selectors, listingFieldSelectors[f])
+		}
+	}
+	return "files(" + strings.Join(selectors, ", ") + ")"
+}
+
+// buildFilesCarousel renders files as a carousel of bubbles.
+func buildFilesCarousel(lang string, files []*drive.File) *messaging_api.FlexCarousel {
+	var bubbles []messaging_api.FlexBubble
+	for _, file := range files {
+		bubbles = append(bubbles, buildFileBubble(lang, file))
+	}
+	return &messaging_api.FlexCarousel{
+		Contents: bubbles,
+	}
+}
+
+func buildFileBubble(lang string, file *drive.File) messaging_api.FlexBubble {
+	contents := []messaging_api.FlexComponentInterface{
+		&messaging_api.FlexText{
+			Text:   cardHeaderLabel,
+			Weight: "bold",
+			Size:   "sm",
+			Color:  cardAccentColor,
+		},
+		&messaging_api.FlexText{
+			Text:   file.Name,
+			Weight: "bold",
+			Size:   "xl",
+			Margin: "md",
+			Wrap:   true,
+		},
+	}
+	contents = append(contents, &messaging_api.FlexText{
+		Text:  sharingLabel(lang, file),
+		Size:  "xs",
+		Color: "#aaaaaa",
+	})
+	for _, line := range fileDetailLines(lang, file) {
+		contents = append(contents, &messaging_api.FlexText{
+			Text:  line,
+			Size:  "xs",
+			Color: "#aaaaaa",
+			Wrap:  true,
+		})
+	}
+
+	buttons := []messaging_api.FlexComponentInterface{
+		&messaging_api.FlexButton{
+			Style:  "link",
+			Height: "sm",
+			Action: &messaging_api.UriAction{
+				Label: translate(lang, "card.open"),
+				Uri:   file.WebViewLink,
+			},
+		},
+	}
+	// Folders, such as the month folders listed by /recent_files, can't be
+	// deleted from a listing.
+	if file.MimeType != folderMimeType {
+		buttons = append(buttons, &messaging_api.FlexButton{
+			Style:  "link",
+			Height: "sm",
+			Color:  "#FF3B30",
+			Action: &messaging_api.PostbackAction{
+				Label:       translate(lang, "card.delete"),
+				Data:        "action=delete&fileId=" + url.QueryEscape(file.Id),
+				DisplayText: fmt.Sprintf(translate(lang, "card.delete_display"), file.Name),
+			},
+		})
+	}
+	// Let the user make the file's folder the upload destination in one
+	// tap; this reuses the /choose_folder postback.
+	if len(file.Parents) > 0 {
+		buttons = append(buttons, &messaging_api.FlexButton{
+			Style:  "link",
+			Height: "sm",
+			Action: &messaging_api.PostbackAction{
+				Label:       translate(lang, "card.set_folder"),
+				Data:        "action=set_folder&folder_id=" + url.QueryEscape(file.Parents[0]),
+				DisplayText: translate(lang, "card.set_folder"),
+			},
+		})
+	}
+
+	return messaging_api.FlexBubble{
+		Hero: buildThumbnailHero(file),
+		Body: &messaging_api.FlexBox{
+			Layout:   "vertical",
+			Contents: contents,
+		},
+		Footer: &messaging_api.FlexBox{
+			Layout:   "vertical",
+			Spacing:  "sm",
+			Contents: buttons,
+		},
+	}
+}
+
+// buildThumbnailHero previews the file with its Drive thumbnail. Files
+// without one, such as audio, keep the text-only bubble. The thumbnail
+// link is signed and expires after a few hours, so it is only good for
+// the reply it is sent in: LINE fetches the image when the message is
+// delivered, and an old message may later show a broken preview.
+func buildThumbnailHero(file *drive.File) messaging_api.FlexComponentInterface {
+	if !strings.HasPrefix(file.ThumbnailLink, "https://") {
+		return nil
+	}
+	return &messaging_api.FlexImage{
+		Url:         file.ThumbnailLink,
+		Size:        "full",
+		AspectRatio: "20:13",
+		AspectMode:  "cover",
+		Action: &messaging_api.UriAction{
+			Uri: file.WebViewLink,
+		},
+	}
+}
+
+// loadSharing fetches the permissions of the shared files so bubbles can
+// tell link-shared files apart. Private files need no extra request.
+func loadSharing(ctx context.Context, srv *drive.Service, files []*drive.File) {
+	for _, file := range files {
+		if !file.Shared {
+			continue
+		}
+		r, err := srv.Permissions.List(file.Id).Fields("permissions(type)").Context(ctx).Do()
+		if err != nil {
+			log.Printf("Failed to list permissions for file %s: %v", file.Id, err)
+			continue
+		}
+		file.Permissions = r.Permissions
+	}
+}
+
+// sharingLabel describes who can see the file.
+func sharingLabel(lang string, file *drive.File) string {
+	if !file.Shared {
+		return translate(lang, "card.private")
+	}
+	for _, p := range file.Permissions {
+		if p.Type == "anyone" {
+			return translate(lang, "card.anyone")
+		}
+	}
+	return translate(lang, "card.shared")
+}
+
+// fileDetailLines formats the configured extra fields that are present on
+// the file, in the configured order.
+func fileDetailLines(lang string, file *drive.File) []string {
+	var lines []string
+	for _, f := range listingExtraFields {
+		switch f {
+		case "createdTime":
+			if file.CreatedTime != "" {
+				lines = append(lines, fmt.Sprintf(translate(lang, "card.created"), formatDriveTime(file.CreatedTime)))
+			}
+		case "modifiedTime":
+			if file.ModifiedTime != "" {
+				lines = append(lines, fmt.Sprintf(translate(lang, "card.modified"), formatDriveTime(file.ModifiedTime)))
+			}
+		case "size":
+			if file.Size > 0 {
+				lines = append(lines, fmt.Sprintf(translate(lang, "card.size"), file.Size))
+			}
+		case "mimeType":
+			if file.MimeType != "" {
+				lines = append(lines, fmt.Sprintf(translate(lang, "card.type"), file.MimeType))
+			}
+		case "owners":
+			var names []string
+			for _, o := range file.Owners {
+				names = append(names, o.DisplayName)
+			}
+			if len(names) > 0 {
+				lines = append(lines, fmt.Sprintf(translate(lang, "card.owner"), strings.Join(names, ", ")))
+			}
+		case "description":
+			if file.Description != "" {
+				lines = append(lines, file.Description)
+			}
+		}
+	}
+	return lines
+}
+
+// formatDriveTime shortens an RFC 3339 timestamp returned by Drive.
+func formatDriveTime(v string) string {
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return v
+	}
+	return t.Format("2006-01-02 15:04")
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestParseListingFields tests that unknown fields are dropped.
+func TestParseListingFields(t *testing.T) {
+	fields := parseListingFields("size, bogus,createdTime,,owners")
+	want := []string{"size", "createdTime", "owners"}
+	if len(fields) != len(want) {
+		t.Fatalf("Expected %v, but got: %v", want, fields)
+	}
+	for i := range want {
+		if fields[i] != want[i] {
+			t.Errorf("Expected %v, but got: %v", want, fields)
+		}
+	}
+}
+
+// TestListingFields tests the Drive field selector built from the config.
+func TestListingFields(t *testing.T) {
+	listingExtraFields = []string{"size", "owners"}
+	defer func() { listingExtraFields = nil }()
+
+	want := "files(id, name, mimeType, webViewLink, parents, shared, thumbnailLink, size, owners(displayName))"
+	if got := listingFields(); got != want {
+		t.Errorf("Expected '%s', but got: '%s'", want, got)
+	}
+}
+
+// TestFileDetailLines tests that only fields present on the file render.
+func TestFileDetailLines(t *testing.T) {
+	listingExtraFields = []string{"createdTime", "size"}
+	defer func() { listingExtraFields = nil }()
+
+	lines := fileDetailLines(langEn, &drive.File{CreatedTime: "2024-03-01T10:20:00Z"})
+	if len(lines) != 1 || lines[0] != "Created: 2024-03-01 10:20" {
+		t.Errorf("Expected only the created line, but got: %v", lines)
+	}
+}
+
+// TestBuildFileBubbleSetFolder tests the set-as-default button.
+func TestBuildFileBubbleSetFolder(t *testing.T) {
+	bubble := buildFileBubble(langEn, &drive.File{Name: "a.jpg", Parents: []string{"folder_1"}})
+	b, err := json.Marshal(bubble)
+	if err != nil {
+		t.Fatalf("Failed to marshal bubble: %v", err)
+	}
+	if !strings.Contains(string(b), "action=set_folder\\u0026folder_id=folder_1") {
+		t.Errorf("Expected set_folder postback in bubble, got: %s", b)
+	}
+
+	bubble = buildFileBubble(langEn, &drive.File{Name: "a.jpg"})
+	if n := len(bubble.Footer.Contents); n != 2 {
+		t.Errorf("Expected only the open and delete buttons without parents, but got %d buttons", n)
+	}
+}
+
+// TestBuildFileBubbleFolder tests that folders get no delete button.
+func TestBuildFileBubbleFolder(t *testing.T) {
+	bubble := buildFileBubble(langEn, &drive.File{Id: "month_id", Name: "2024-03", MimeType: folderMimeType})
+	b, err := json.Marshal(bubble)
+	if err != nil {
+		t.Fatalf("Failed to marshal bubble: %v", err)
+	}
+	if strings.Contains(string(b), "action=delete") {
+		t.Errorf("Expected no delete postback for a folder, got: %s", b)
+	}
+}
+
+// TestBuildFileBubbleThumbnail tests that the thumbnail hero is only added
+// for files that have a thumbnail.
+func TestBuildFileBubbleThumbnail(t *testing.T) {
+	bubble := buildFileBubble(langEn, &drive.File{Name: "a.jpg", WebViewLink: "https://drive.google.com/file/d/1/view", ThumbnailLink: "https://lh3.googleusercontent.com/thumb=s220"})
+	hero, ok := bubble.Hero.(*messaging_api.FlexImage)
+	if !ok {
+		t.Fatalf("Expected an image hero, but got: %#v", bubble.Hero)
+	}
+	if hero.Url != "https://lh3.googleusercontent.com/thumb=s220" {
+		t.Errorf("Expected the thumbnail URL, but got: %q", hero.Url)
+	}
+
+	bubble = buildFileBubble(langEn, &drive.File{Name: "a.m4a"})
+	if bubble.Hero != nil {
+		t.Errorf("Expected no hero without a thumbnail, but got: %#v", bubble.Hero)
+	}
+	b, err := json.Marshal(bubble)
+	if err != nil {
+		t.Fatalf("Failed to marshal bubble: %v", err)
+	}
+	if strings.Contains(string(b), `"hero"`) {
+		t.Errorf("Expected the text-only layout without a thumbnail, but got: %s", b)
+	}
+}
+
+// TestBuildFileBubbleBranding tests that the header uses the configured
+// label and accent color.
+func TestBuildFileBubbleBranding(t *testing.T) {
+	cardHeaderLabel, cardAccentColor = "Acme Files", "#FF0000"
+	defer func() { cardHeaderLabel, cardAccentColor = "Recent Upload", "#1DB446" }()
+
+	bubble := buildFileBubble(langEn, &drive.File{Name: "a.jpg"})
+	header := bubble.Body.Contents[0].(*messaging_api.FlexText)
+	if header.Text != "Acme Files" || header.Color != "#FF0000" {
+		t.Errorf("Expected branded header, but got %q %q", header.Text, header.Color)
+	}
+}
+
+// TestEnvColor tests that only #RRGGBB values are accepted.
+func TestEnvColor(t *testing.T) {
+	for v, want := range map[string]string{"#00ff00": "#00ff00", "green": "#1DB446", "#12345": "#1DB446", "": "#1DB446"} {
+		t.Setenv("CARD_ACCENT_COLOR", v)
+		if got := envColor("CARD_ACCENT_COLOR", "#1DB446"); got != want {
+			t.Errorf("envColor(%q) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+// TestLoadSharing tests that permissions are only fetched for shared files
+// and that link sharing is reported as public.
+func TestLoadSharing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/files/public/permissions":
+			json.NewEncoder(w).Encode(&drive.PermissionList{Permissions: []*drive.Permission{{Type: "user"}, {Type: "anyone"}}})
+		case "/files/team/permissions":
+			json.NewEncoder(w).Encode(&drive.PermissionList{Permissions: []*drive.Permission{{Type: "user"}}})
+		default:
+			t.Errorf("Unexpected request: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	files := []*drive.File{{Id: "private"}, {Id: "public", Shared: true}, {Id: "team", Shared: true}}
+	loadSharing(context.Background(), srv, files)
+
+	want := []string{"🔒 Private", "🌐 Anyone with the link", "👥 Shared"}
+	for i, file := range files {
+		if got := sharingLabel(langEn, file); got != want[i] {
+			t.Errorf("%s: expected '%s', but got: '%s'", file.Id, want[i], got)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// commandRequest is a parsed command message.
+type commandRequest struct {
+	ReplyToken string
+	UserID     string
+	Args       []string
+
+	// Host is the webhook request's host, used to pick the OAuth redirect
+	// URL.
+	Host string
+}
+
+// commandHandler runs one command. Each command the bot understands,
+// without the prefix, has one in commandHandlers.
+type commandHandler func(ctx context.Context, bot botClient, req commandRequest)
+
+// commandHandlers is filled in by init: several handlers mention other
+// commands through withCommandPrefix, which reads this map.
+var commandHandlers map[string]commandHandler
+
+func init() {
+	commandHandlers = map[string]commandHandler{
+		"connect_drive":    handleConnectDriveCommand,
+		"disconnect_drive": handleDisconnectDriveCommand,
+		"reconnect":        handleReconnectCommand,
+		"help": func(ctx context.Context, bot botClient, req commandRequest) {
+			replyText(bot, req.ReplyToken, tr(ctx, req.UserID, "help.commands"))
+		},
+		"recent_files": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleRecentFilesCommand(ctx, bot, req.ReplyToken, req.UserID, "")
+		},
+		"search": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleSearchCommand(ctx, bot, req.ReplyToken, req.UserID, req.Args)
+		},
+		"between": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleBetweenCommand(ctx, bot, req.ReplyToken, req.UserID, req.Args)
+		},
+		"manifest": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleManifestCommand(ctx, bot, req.ReplyToken, req.UserID, req.Args)
+		},
+		"history": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleHistoryCommand(ctx, bot, req.ReplyToken, req.UserID)
+		},
+		"usage": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleUsageCommand(ctx, bot, req.ReplyToken, req.UserID)
+		},
+		"stats": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleStatsCommand(ctx, bot, req.ReplyToken, req.UserID)
+		},
+		"whoami": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleWhoamiCommand(ctx, bot, req.ReplyToken, req.UserID)
+		},
+		"quota": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleQuotaCommand(ctx, bot, req.ReplyToken, req.UserID)
+		},
+		"trash": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleTrashCommand(ctx, bot, req.ReplyToken, req.UserID, 0)
+		},
+		"rename": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleRenameCommand(ctx, bot, req.ReplyToken, req.UserID, req.Args)
+		},
+		"undo": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleUndoCommand(ctx, bot, req.ReplyToken, req.UserID)
+		},
+		"cancel": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleCancelCommand(ctx, bot, req.ReplyToken, req.UserID)
+		},
+		"lang": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleLangCommand(ctx, bot, req.ReplyToken, req.UserID, req.Args)
+		},
+		"selftest": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleSelfTestCommand(ctx, bot, req.ReplyToken, req.UserID)
+		},
+		"choose_folder": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleChooseFolderCommand(ctx, bot, req.ReplyToken, req.UserID, 0)
+		},
+		"pause": func(ctx context.Context, bot botClient, req commandRequest) {
+			handlePauseCommand(ctx, bot, req.ReplyToken, req.UserID, req.Args)
+		},
+		"resume": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleResumeCommand(ctx, bot, req.ReplyToken, req.UserID)
+		},
+		"route": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleRouteCommand(ctx, bot, req.ReplyToken, req.UserID, req.Args)
+		},
+		"routes": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleRoutesCommand(ctx, bot, req.ReplyToken, req.UserID)
+		},
+		"setfolder": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleSetFolderCommand(ctx, bot, req.ReplyToken, req.UserID, req.Args)
+		},
+		"currentfolder": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleCurrentFolderCommand(ctx, bot, req.ReplyToken, req.UserID)
+		},
+		"where": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleWhereCommand(ctx, bot, req.ReplyToken, req.UserID)
+		},
+		"description": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleDescriptionCommand(ctx, bot, req.ReplyToken, req.UserID, req.Args)
+		},
+		"sandbox": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleSandboxCommand(ctx, bot, req.ReplyToken, req.UserID, req.Args)
+		},
+		"manual": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleManualCommand(ctx, bot, req.ReplyToken, req.UserID, req.Args)
+		},
+		"dedupe": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleDedupeCommand(ctx, bot, req.ReplyToken, req.UserID, req.Args)
+		},
+		"note": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleNoteCommand(ctx, bot, req.ReplyToken, req.UserID, req.Args)
+		},
+		"menu": func(ctx context.Context, bot botClient, req commandRequest) {
+			handleMenuCommand(ctx, bot, req.ReplyToken, req.UserID, req.Args)
+		},
+	}
+}
+
+// parseCommand splits a text message into its command name, with the
+// configured prefix removed, and its arguments. The name is empty when the
+// text isn't a known command.
+func parseCommand(text string) (string, []string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], commandPrefix) {
+		return "", nil
+	}
+	name := strings.TrimPrefix(fields[0], commandPrefix)
+	if commandHandlers[name] == nil {
+		return "", nil
+	}
+	return name, fields[1:]
+}
+
+// commandText returns what users type to run the named command, e.g.
+// "/help".
+func commandText(name string) string {
+	return commandPrefix + name
+}
+
+var slashCommandPattern = regexp.MustCompile(`/([a-z_]+)`)
+
+// withCommandPrefix rewrites the "/command" mentions in a built-in message
+// to use the configured prefix.
+func withCommandPrefix(s string) string {
+	if commandPrefix == "/" {
+		return s
+	}
+	return slashCommandPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if name := m[1:]; commandHandlers[name] != nil {
+			return commandText(name)
+		}
+		return m
+	})
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+)
+
+// TestParseCommand tests splitting text into a command and its arguments.
+func TestParseCommand(t *testing.T) {
+	command, args := parseCommand("  /menu   main ")
+	if command != "menu" {
+		t.Errorf("Expected command 'menu', but got: '%s'", command)
+	}
+	if len(args) != 1 || args[0] != "main" {
+		t.Errorf("Expected args [main], but got: %v", args)
+	}
+
+	for _, text := range []string{"", "/unknown", "menu", "hello /menu"} {
+		if command, args := parseCommand(text); command != "" || len(args) != 0 {
+			t.Errorf("%q: expected no command, but got: '%s' %v", text, command, args)
+		}
+	}
+}
+
+// TestParseCommandPrefix tests custom and empty command prefixes.
+func TestParseCommandPrefix(t *testing.T) {
+	defer func() { commandPrefix = "/" }()
+
+	commandPrefix = "!"
+	if command, _ := parseCommand("!help"); command != "help" {
+		t.Errorf("Expected 'help' with prefix '!', but got: '%s'", command)
+	}
+	if command, _ := parseCommand("/help"); command != "" {
+		t.Errorf("Expected '/help' to be ignored with prefix '!', but got: '%s'", command)
+	}
+
+	commandPrefix = ""
+	if command, _ := parseCommand("help"); command != "help" {
+		t.Errorf("Expected 'help' with no prefix, but got: '%s'", command)
+	}
+	if command, _ := parseCommand("hello there"); command != "" {
+		t.Errorf("Expected plain text not to be a command, but got: '%s'", command)
+	}
+}
+
+// TestWithCommandPrefix tests rewriting command mentions in messages.
+func TestWithCommandPrefix(t *testing.T) {
+	defer func() { commandPrefix = "/" }()
+
+	commandPrefix = "."
+	got := withCommandPrefix("/help - 說明\n/pause <時間> /resume\n2024/03 /unknown")
+	want := ".help - 說明\n.pause <時間> .resume\n2024/03 /unknown"
+	if got != want {
+		t.Errorf("Expected %q, but got %q", want, got)
+	}
+}
+
+// TestUsageRepliesUseCommandPrefix tests that usage replies mention
+// commands with the configured prefix.
+func TestUsageRepliesUseCommandPrefix(t *testing.T) {
+	defer func() { commandPrefix = "/" }()
+	commandPrefix = "!"
+
+	handlers := map[string]func(context.Context, botClient, string, string, []string){
+		"between":   handleBetweenCommand,
+		"dedupe":    handleDedupeCommand,
+		"manual":    handleManualCommand,
+		"menu":      handleMenuCommand,
+		"note":      handleNoteCommand,
+		"pause":     handlePauseCommand,
+		"sandbox":   handleSandboxCommand,
+		"search":    handleSearchCommand,
+		"setfolder": handleSetFolderCommand,
+	}
+	for name, handler := range handlers {
+		bot := newFakeBot()
+		handler(context.Background(), bot, "token", "U1", nil)
+		if len(bot.replies) != 1 || len(bot.replies[0].texts) != 1 {
+			t.Fatalf("%s: expected one usage reply, but got: %+v", name, bot.replies)
+		}
+		text := bot.replies[0].texts[0]
+		if !strings.Contains(text, "!"+name) || strings.Contains(text, "/"+name) {
+			t.Errorf("%s: expected the usage to mention !%s, but got: %q", name, name, text)
+		}
+	}
+}
+
+// newReplyRecorder returns a bot whose replies are appended, as text, to
+// the returned slice.
+func newReplyRecorder(t *testing.T) (*messaging_api.MessagingApiAPI, *[]string) {
+	t.Helper()
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Text string `json:"text"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		for _, m := range req.Messages {
+			texts = append(texts, m.Text)
+		}
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+	bot, err := messaging_api.NewMessagingApiAPI("token", messaging_api.WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create mock bot: %v", err)
+	}
+	return bot, &texts
+}
+
+// TestCommandHandlers tests invoking individual command handlers through
+// the dispatcher.
+func TestCommandHandlers(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+		want    string
+	}{
+		{"help", nil, "/connect_drive - 連結 Google Drive"},
+		{"lang", nil, "/lang en|zh"},
+		{"lang", []string{"fr"}, "/lang en|zh"},
+		{"rename", nil, "/rename 收據.jpg"},
+	}
+	for _, tt := range tests {
+		bot, texts := newReplyRecorder(t)
+		commandHandlers[tt.command](context.Background(), bot, commandRequest{ReplyToken: "r1", UserID: "U1", Args: tt.args})
+		if len(*texts) != 1 || !strings.Contains((*texts)[0], tt.want) {
+			t.Errorf("/%s %v: expected a reply containing %q, but got: %q", tt.command, tt.args, tt.want, *texts)
+		}
+	}
+}
+
+// TestCommandHandlersMatchHelp tests that every command in the help text
+// of each language is dispatched.
+func TestCommandHandlersMatchHelp(t *testing.T) {
+	for lang, catalog := range messageCatalogs {
+		for _, m := range slashCommandPattern.FindAllStringSubmatch(catalog["help.commands"], -1) {
+			if commandHandlers[m[1]] == nil {
+				t.Errorf("Expected a handler for /%s in the %s help", m[1], lang)
+			}
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	// The alpine runtime image ships without zoneinfo, which BOT_TIMEZONE
+	// needs.
+	_ "time/tzdata"
+)
+
+// Tunables that operators can override through environment variables.
+// Each one keeps a sensible default so the bot runs without extra setup.
+var (
+	richMenuLinkRetries = 3
+	richMenuLinkBackoff = 500 * time.Millisecond
+	oauthForceApproval  = false
+	debugLogging        = false
+	debugWebhook        = false
+	defaultReply        = "help"
+	memberLeftAction    = memberLeftLog
+	// onboardingTip replaces the "upload.onboarding" message when
+	// onboardingTipSet; an empty tip turns it off.
+	onboardingTip      string
+	onboardingTipSet   bool
+	maxFilenameLen     = 255
+	listingExtraFields []string
+	richMenuMainAlias  string
+	richMenuConnect    string
+	richMenuMain       string
+	commandPrefix      = "/"
+	reconnectCommand   = "/reconnect"
+	reconnectMessage   string
+	tasksSecret        string
+	tokenExpiryWindow  = 24 * time.Hour
+	uploadIcons        = true
+	successRedirectURL string
+	successTemplate    *template.Template
+	lineBotID          string
+	allowlistUsers     map[string]bool
+	allowlistFirestore = false
+	progressMinBytes   = int64(20 << 20)
+	progressInterval   = 5 * time.Second
+
+	contentFetchTimeout = 10 * time.Second
+	eventTimeout        = 9 * time.Second
+	eventDedupeTTL      = time.Hour
+
+	folderDateLayout = defaultFolderDateLayout
+	sharedDriveID    string
+	botLocation      = time.UTC
+
+	cardHeaderLabel = "Recent Upload"
+	cardAccentColor = "#1DB446"
+
+	externalContentHosts    map[string]bool
+	maxExternalContentBytes = int64(200 << 20)
+	maxUploadBytes          = int64(1 << 30)
+	maxDedupeBytes          = int64(50 << 20)
+	uploadChunkSize         = 8 << 20
+
+	serverReadHeaderTimeout = 10 * time.Second
+	serverReadTimeout       = 30 * time.Second
+	serverWriteTimeout      = 10 * time.Minute
+	serverIdleTimeout       = 2 * time.Minute
+	shutdownTimeout         = 10 * time.Second
+
+	commandDebounceWindow = 3 * time.Second
+	webhookInlineEvents   = 10
+
+	lineRateLimit  = 0
+	lineMaxRetries = 3
+
+	tokenHealthThrottle    = 200 * time.Millisecond
+	tokenHealthMinInterval = 12 * time.Hour
+)
+
+// loadConfig reads optional settings from the environment.
+func loadConfig() {
+	richMenuLinkRetries = envInt("RICHMENU_LINK_RETRIES", richMenuLinkRetries)
+	richMenuLinkBackoff = envDuration("RICHMENU_LINK_BACKOFF", richMenuLinkBackoff)
+	oauthForceApproval = envBool("OAUTH_FORCE_APPROVAL", oauthForceApproval)
+	debugLogging = envBool("DEBUG", debugLogging)
+	debugWebhook = envBool("DEBUG_WEBHOOK", debugWebhook)
+	maxFilenameLen = envInt("MAX_FILENAME_LEN", maxFilenameLen)
+	listingExtraFields = parseListingFields(os.Getenv("LISTING_FIELDS"))
+	richMenuMainAlias = os.Getenv("RICHMENU_MAIN_ALIAS")
+	richMenuConnect = os.Getenv("RICHMENU_CONNECT_ID")
+	richMenuMain = os.Getenv("RICHMENU_MAIN_ID")
+	if richMenuConnect == "" || richMenuMain == "" {
+		log.Printf("RICHMENU_CONNECT_ID or RICHMENU_MAIN_ID is not set; users won't get rich menus")
+	}
+	if v, ok := os.LookupEnv("COMMAND_PREFIX"); ok {
+		commandPrefix = v
+	}
+	reconnectCommand = envString("RECONNECT_COMMAND", commandText("reconnect"))
+	reconnectMessage = os.Getenv("RECONNECT_MESSAGE")
+	tasksSecret = os.Getenv("TASKS_SECRET")
+	tokenExpiryWindow = envDuration("TOKEN_EXPIRY_WINDOW", tokenExpiryWindow)
+	uploadIcons = envBool("UPLOAD_ICONS", uploadIcons)
+	successRedirectURL = os.Getenv("SUCCESS_REDIRECT_URL")
+	lineBotID = os.Getenv("LINE_BOT_ID")
+	allowlistUsers = parseAllowlist(os.Getenv("ALLOWLIST"))
+	allowlistFirestore = envBool("ALLOWLIST_FIRESTORE", allowlistFirestore)
+	progressMinBytes = envInt64("PROGRESS_MIN_BYTES", progressMinBytes)
+	progressInterval = envDuration("PROGRESS_INTERVAL", progressInterval)
+	if path := os.Getenv("SUCCESS_TEMPLATE"); path != "" {
+		t, err := template.ParseFiles(path)
+		if err != nil {
+			log.Printf("Failed to load SUCCESS_TEMPLATE %q, using the default page: %v", path, err)
+		} else {
+			successTemplate = t
+		}
+	}
+	tokenHealthThrottle = envDuration("TOKEN_HEALTH_THROTTLE", tokenHealthThrottle)
+	tokenHealthMinInterval = envDuration("TOKEN_HEALTH_MIN_INTERVAL", tokenHealthMinInterval)
+	lineRateLimit = envInt("LINE_RATE_LIMIT", lineRateLimit)
+	lineMaxRetries = envInt("LINE_MAX_RETRIES", lineMaxRetries)
+	contentFetchTimeout = envDuration("CONTENT_FETCH_TIMEOUT", contentFetchTimeout)
+	serverReadHeaderTimeout = envDuration("SERVER_READ_HEADER_TIMEOUT", serverReadHeaderTimeout)
+	serverReadTimeout = envDuration("SERVER_READ_TIMEOUT", serverReadTimeout)
+	serverWriteTimeout = envDuration("SERVER_WRITE_TIMEOUT", serverWriteTimeout)
+	serverIdleTimeout = envDuration("SERVER_IDLE_TIMEOUT", serverIdleTimeout)
+	shutdownTimeout = envDuration("SHUTDOWN_TIMEOUT", shutdownTimeout)
+	defaultReply = envString("DEFAULT_REPLY", defaultReply)
+	commandDebounceWindow = envDuration("COMMAND_DEBOUNCE_WINDOW", commandDebounceWindow)
+	webhookInlineEvents = envInt("WEBHOOK_INLINE_EVENTS", webhookInlineEvents)
+	connectLimiter = newUserRateLimiter(envInt("CONNECT_RATE_LIMIT", connectLimiter.burst), time.Minute)
+	memberLeftAction = envString("MEMBER_LEFT_ACTION", memberLeftAction)
+	onboardingTip, onboardingTipSet = os.LookupEnv("ONBOARDING_TIP")
+	externalContentHosts = parseAllowlist(strings.ToLower(os.Getenv("EXTERNAL_CONTENT_HOSTS")))
+	maxExternalContentBytes = envInt64("MAX_EXTERNAL_CONTENT_BYTES", maxExternalContentBytes)
+	maxUploadBytes = envInt64("MAX_UPLOAD_BYTES", maxUploadBytes)
+	maxDedupeBytes = envInt64("DEDUPE_MAX_BYTES", maxDedupeBytes)
+	uploadChunkSize = envInt("UPLOAD_CHUNK_SIZE", uploadChunkSize)
+	cardHeaderLabel = envString("CARD_HEADER_LABEL", cardHeaderLabel)
+	cardAccentColor = envColor("CARD_ACCENT_COLOR", cardAccentColor)
+	eventTimeout = envDuration("EVENT_TIMEOUT", eventTimeout)
+	eventDedupeTTL = envDuration("EVENT_DEDUPE_TTL", eventDedupeTTL)
+	if v, ok := os.LookupEnv("FOLDER_DATE_LAYOUT"); ok {
+		folderDateLayout = parseFolderDateLayout(v)
+	}
+	botLocation = parseBotTimezone(os.Getenv("BOT_TIMEZONE"))
+	sharedDriveID = os.Getenv("SHARED_DRIVE_ID")
+}
+
+// parseBotTimezone loads the IANA time zone that date folders follow.
+// Unset or invalid zones fall back to UTC rather than the server's local
+// time, so folder names don't depend on where the bot runs.
+func parseBotTimezone(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		logger.Warn("Invalid BOT_TIMEZONE, using UTC", "value", name, "error", err)
+		return time.UTC
+	}
+	return loc
+}
+
+// defaultFolderDateLayout names upload subfolders by month, e.g. "2024-03".
+const defaultFolderDateLayout = "2006-01"
+
+// folderDateLayouts are the Go time layouts accepted for
+// FOLDER_DATE_LAYOUT. They never contain path separators, and they sort
+// chronologically by name.
+var folderDateLayouts = map[string]bool{
+	"2006":       true,
+	"2006-01":    true,
+	"200601":     true,
+	"2006-01-02": true,
+	"20060102":   true,
+}
+
+// parseFolderDateLayout validates a FOLDER_DATE_LAYOUT value. An empty
+// value disables date subfolders; an unknown layout falls back to monthly
+// folders.
+func parseFolderDateLayout(v string) string {
+	if v == "" || folderDateLayouts[v] {
+		return v
+	}
+	log.Printf("Invalid value for FOLDER_DATE_LAYOUT: %q, using default %s", v, defaultFolderDateLayout)
+	return defaultFolderDateLayout
+}
+
+// debugf logs at debug level, which is only written when DEBUG is enabled.
+func debugf(format string, v ...interface{}) {
+	logger.Debug(fmt.Sprintf(format, v...))
+}
+
+// envString returns the value of the named environment variable, or def
+// when it is unset.
+func envString(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// envInt returns the integer value of the named environment variable,
+// or def when it is unset or malformed.
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default %d", name, v, def)
+		return def
+	}
+	return n
+}
+
+// envInt64 is envInt for 64-bit values such as byte sizes.
+func envInt64(name string, def int64) int64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default %d", name, v, def)
+		return def
+	}
+	return n
+}
+
+// hexColorPattern matches the "#RRGGBB" colors Flex messages accept.
+var hexColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// envColor returns the "#RRGGBB" color in the named environment variable,
+// or def when it is unset or not a valid hex color.
+func envColor(name, def string) string {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	if !hexColorPattern.MatchString(v) {
+		log.Printf("Invalid value for %s: %q, using default %s", name, v, def)
+		return def
+	}
+	return v
+}
+
+// envDuration returns the duration value (e.g. "500ms", "2s") of the named
+// environment variable, or def when it is unset or malformed.
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default %s", name, v, def)
+		return def
+	}
+	return d
+}
+
+// envBool returns the boolean value (e.g. "true", "0") of the named
+// environment variable, or def when it is unset or malformed.
+func envBool(name string, def bool) bool {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default %t", name, v, def)
+		return def
+	}
+	return b
+}
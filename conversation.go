@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const conversationCollection = "conversation_state"
+
+// conversationTTL is how long the bot waits for the follow-up message of a
+// multi-step command before forgetting about it.
+const conversationTTL = 5 * time.Minute
+
+// Conversation states. Each one names the input the bot is waiting for.
+const (
+	stateAwaitingDescription   = "awaiting_description"
+	stateAwaitingUploadConfirm = "awaiting_upload_confirm"
+)
+
+// conversationState is a pending multi-step command for one user.
+type conversationState struct {
+	State     string            `firestore:"state"`
+	Data      map[string]string `firestore:"data"`
+	ExpiresAt time.Time         `firestore:"expires_at"`
+}
+
+// setConversationState makes the user's next message the answer to state.
+func setConversationState(ctx context.Context, userID, state string, data map[string]string) error {
+	cs := conversationState{State: state, Data: data, ExpiresAt: time.Now().Add(conversationTTL)}
+	if _, err := firestoreClient.Collection(conversationCollection).Doc(userID).Set(ctx, cs); err != nil {
+		return fmt.Errorf("failed to save conversation state: %w", err)
+	}
+	return nil
+}
+
+// getConversationState returns the user's pending state, or nil when there
+// is none or it has expired.
+func getConversationState(ctx context.Context, userID string) (*conversationState, error) {
+	doc, err := firestoreClient.Collection(conversationCollection).Doc(userID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get conversation state: %w", err)
+	}
+	var cs conversationState
+	if err := doc.DataTo(&cs); err != nil {
+		return nil, fmt.Errorf("failed to parse conversation state: %w", err)
+	}
+	if conversationExpired(&cs, time.Now()) {
+		return nil, nil
+	}
+	return &cs, nil
+}
+
+func conversationExpired(cs *conversationState, now time.Time) bool {
+	return !now.Before(cs.ExpiresAt)
+}
+
+func clearConversationState(ctx context.Context, userID string) {
+	if _, err := firestoreClient.Collection(conversationCollection).Doc(userID).Delete(ctx); err != nil {
+		log.Printf("Failed to clear conversation state for user %s: %v", userID, err)
+	}
+}
+
+// handleConversationReply routes text to the user's pending multi-step
+// command, if any, and reports whether it consumed the message. Pending
+// state is one-shot: any reply clears it, and sending another command
+// abandons it.
+func handleConversationReply(ctx context.Context, bot botClient, blob blobClient, replyToken, userID, text string) bool {
+	cs, err := getConversationState(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load conversation state for user %s: %v", userID, err)
+		return false
+	}
+	if cs == nil {
+		return false
+	}
+	clearConversationState(ctx, userID)
+
+	text = strings.TrimSpace(text)
+	if command, _ := parseCommand(text); command != "" {
+		return false
+	}
+	if text == "cancel" || isCatalogText("common.cancel", text) {
+		replyText(bot, replyToken, tr(ctx, userID, "common.cancelled"))
+		return true
+	}
+
+	switch cs.State {
+	case stateAwaitingDescription:
+		setDescription(ctx, bot, replyToken, userID, text)
+	case stateAwaitingUploadConfirm:
+		confirmPendingUpload(ctx, bot, blob, replyToken, userID, text, cs.Data)
+	default:
+		log.Printf("Unknown conversation state %q for user %s", cs.State, userID)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestConversationExpired tests the TTL check on pending state.
+func TestConversationExpired(t *testing.T) {
+	now := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	if conversationExpired(&conversationState{ExpiresAt: now.Add(time.Minute)}, now) {
+		t.Error("Expected state expiring in the future to be pending")
+	}
+	if !conversationExpired(&conversationState{ExpiresAt: now}, now) {
+		t.Error("Expected state expiring now to be expired")
+	}
+}
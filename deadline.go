@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// withEventTimeout bounds the inline handling of one webhook request so it
+// answers LINE in time. A zero eventTimeout disables the deadline.
+func withEventTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if eventTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, eventTimeout)
+}
+
+// pastEventDeadline reports whether err, or the event itself, ran into the
+// event deadline.
+func pastEventDeadline(ctx context.Context, err error) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded) && (err == nil || errors.Is(err, context.DeadlineExceeded))
+}
+
+// finishUploadInBackground restarts an upload that ran out of event time
+// without a deadline. In one-to-one chats the result is pushed; elsewhere
+// the original reply token is used.
+func finishUploadInBackground(ctx context.Context, bot botClient, blob blobClient, replyToken, userID string, msg mediaMessage) {
+	logger := loggerFrom(ctx)
+	logger.Warn("Upload exceeded the event deadline; continuing in the background", "userID", userID, "messageID", msg.ID)
+	if msg.Direct {
+		replyToken = acknowledgeUpload(bot, replyToken, userID, tr(ctx, userID, "upload.processing"))
+	}
+	go uploadMedia(withLogger(context.Background(), logger), bot, blob, replyToken, userID, msg)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestPastEventDeadline tests that a Drive call cut off by the event
+// deadline is recognized, and other failures are not.
+func TestPastEventDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	eventTimeout = 50 * time.Millisecond
+	defer func() { eventTimeout = 9 * time.Second }()
+	ctx, cancel := withEventTimeout(context.Background())
+	defer cancel()
+
+	_, err = srv.Files.Create(&drive.File{Name: "a.jpg"}).Media(strings.NewReader("data")).Context(ctx).Do()
+	if !pastEventDeadline(ctx, err) {
+		t.Errorf("Expected the upload to hit the event deadline, but got: %v", err)
+	}
+
+	if pastEventDeadline(context.Background(), errors.New("boom")) {
+		t.Errorf("Expected other errors not to count as the deadline")
+	}
+}
+
+// TestWithEventTimeoutDisabled tests that a zero timeout sets no deadline.
+func TestWithEventTimeoutDisabled(t *testing.T) {
+	eventTimeout = 0
+	defer func() { eventTimeout = 9 * time.Second }()
+
+	ctx, cancel := withEventTimeout(context.Background())
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("Expected no deadline when EVENT_TIMEOUT is 0")
+	}
+}
+
+// TestDriveLookupDeadline tests that a Drive call that never answers gives
+// up at the request deadline and is reported as a timeout.
+func TestDriveLookupDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err = getRecentFiles(ctx, srv, mainFolderName, recentFilesPageSize, "")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to give up at the deadline, but took %v", elapsed)
+	}
+	if !errors.Is(classify(err), ErrTimeout) {
+		t.Fatalf("Expected a timeout, but got: %v", err)
+	}
+
+	bot, texts := newReplyRecorder(t)
+	if !replyForError(context.Background(), bot, "token", "user1", err) {
+		t.Fatal("Expected the timeout to be answered")
+	}
+	if len(*texts) != 1 || (*texts)[0] != translate(langZh, "error.timeout") {
+		t.Errorf("Expected the timeout reply, but got: %q", *texts)
+	}
+}
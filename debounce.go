@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// commandDebouncer drops repeats of the same command sent by a user
+// within a short window, e.g. rapid taps on a rich menu button.
+type commandDebouncer struct {
+	mu   sync.Mutex
+	last map[string]debounceEntry
+}
+
+type debounceEntry struct {
+	text     string
+	at       time.Time
+	notified bool
+}
+
+var commandDebounce = &commandDebouncer{last: map[string]debounceEntry{}}
+
+// check reports whether text repeats the user's previous command within
+// window. notify is true only for the first dropped repeat, so the user
+// gets a single "please wait" reply.
+func (d *commandDebouncer) check(userID, text string, window time.Duration, now time.Time) (duplicate, notify bool) {
+	if window <= 0 {
+		return false, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e, ok := d.last[userID]
+	if ok && e.text == text && now.Sub(e.at) < window {
+		notify = !e.notified
+		e.notified = true
+		d.last[userID] = e
+		return true, notify
+	}
+	d.last[userID] = debounceEntry{text: text, at: now}
+	d.prune(window, now)
+	return false, false
+}
+
+// prune drops entries older than window; they can no longer match a
+// repeat. Like userRateLimiter.prune, it only runs once the map has grown.
+func (d *commandDebouncer) prune(window time.Duration, now time.Time) {
+	if len(d.last) < 1000 {
+		return
+	}
+	for userID, e := range d.last {
+		if now.Sub(e.at) >= window {
+			delete(d.last, userID)
+		}
+	}
+}
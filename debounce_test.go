@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestCommandDebouncer tests that repeats inside the window are dropped
+// and only the first one asks for a reply.
+func TestCommandDebouncer(t *testing.T) {
+	d := &commandDebouncer{last: map[string]debounceEntry{}}
+	now := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	window := 3 * time.Second
+
+	steps := []struct {
+		user, text string
+		offset     time.Duration
+		dup, notif bool
+	}{
+		{"u1", "/recent_files", 0, false, false},
+		{"u1", "/recent_files", time.Second, true, true},
+		{"u1", "/recent_files", 2 * time.Second, true, false},
+		{"u2", "/recent_files", 2 * time.Second, false, false},
+		{"u1", "/history", 2 * time.Second, false, false},
+		{"u1", "/history", 6 * time.Second, false, false},
+	}
+	for i, s := range steps {
+		dup, notify := d.check(s.user, s.text, window, now.Add(s.offset))
+		if dup != s.dup || notify != s.notif {
+			t.Errorf("step %d: expected (%v, %v), but got: (%v, %v)", i, s.dup, s.notif, dup, notify)
+		}
+	}
+
+	if dup, _ := d.check("u1", "/history", 0, now); dup {
+		t.Error("Expected a zero window to disable debouncing")
+	}
+}
+
+// TestCommandDebouncerPrune tests that expired entries are dropped once
+// the map has grown.
+func TestCommandDebouncerPrune(t *testing.T) {
+	d := &commandDebouncer{last: map[string]debounceEntry{}}
+	now := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	window := 3 * time.Second
+
+	for i := 0; i < 1000; i++ {
+		d.check(fmt.Sprintf("u%d", i), "/help", window, now)
+	}
+	d.check("late", "/help", window, now.Add(window))
+	if len(d.last) != 1 {
+		t.Errorf("Expected only the latest entry to remain, but got %d", len(d.last))
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// uploadHashCollection indexes each user's uploads by content hash so a
+// re-sent file can be matched to the existing copy.
+const uploadHashCollection = "upload_hashes"
+
+type uploadHash struct {
+	FileID     string    `firestore:"file_id"`
+	Month      string    `firestore:"month"`
+	UploadedAt time.Time `firestore:"uploaded_at"`
+}
+
+// duplicateUploadError is returned by uploadToDrive when the content was
+// already uploaded and the user has not turned /dedupe off.
+type duplicateUploadError struct {
+	FileID string
+	Month  string
+}
+
+func (e *duplicateUploadError) Error() string {
+	return fmt.Sprintf("file already uploaded in %s as %s", e.Month, e.FileID)
+}
+
+func uploadHashDocID(userID, hash string) string {
+	return userID + "_" + hash
+}
+
+// handleDedupeCommand turns duplicate detection on or off.
+func handleDedupeCommand(ctx context.Context, bot botClient, replyToken, userID string, args []string) {
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		replyText(bot, replyToken, tr(ctx, userID, "dedupe.usage"))
+		return
+	}
+	on := args[0] == "on"
+	if err := updateUserPrefs(ctx, userID, map[string]interface{}{"dedupe": on}); err != nil {
+		log.Printf("Failed to set dedupe for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "error.save_setting"))
+		return
+	}
+	if on {
+		replyText(bot, replyToken, tr(ctx, userID, "dedupe.on"))
+	} else {
+		replyText(bot, replyToken, tr(ctx, userID, "dedupe.off"))
+	}
+}
+
+// isDedupeEnabled reports whether duplicate detection is on for the user.
+func isDedupeEnabled(ctx context.Context, userID string) bool {
+	prefs, err := getUserPrefs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load prefs for user %s, skipping dedupe: %v", userID, err)
+		return false
+	}
+	return dedupeEnabled(prefs)
+}
+
+// dedupeEnabled applies the default: duplicate detection is on unless the
+// user turned it off.
+func dedupeEnabled(prefs *userPrefs) bool {
+	return prefs.Dedupe == nil || *prefs.Dedupe
+}
+
+// dedupeFits reports whether content of the given length is small enough
+// to spool for duplicate detection. The spooled copy lives in the
+// container's in-memory filesystem, so larger or unknown-length content
+// skips the check. A zero maxDedupeBytes removes the limit.
+func dedupeFits(contentLength int64) bool {
+	if maxDedupeBytes <= 0 {
+		return true
+	}
+	return contentLength >= 0 && contentLength <= maxDedupeBytes
+}
+
+// spoolContent copies r to a temporary file while hashing it with SHA-256,
+// so the hash is known before the upload starts. The caller must close and
+// remove the returned file.
+func spoolContent(r io.Reader) (*os.File, string, error) {
+	f, err := os.CreateTemp("", "linebot-upload-*")
+	if err != nil {
+		return nil, "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, "", err
+	}
+	return f, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// findPreviousUpload returns the user's earlier upload with the same
+// content hash, or nil if there is none or it has since been trashed.
+func findPreviousUpload(ctx context.Context, srv *drive.Service, userID, hash string) (*uploadHash, error) {
+	ref := firestoreClient.Collection(uploadHashCollection).Doc(uploadHashDocID(userID, hash))
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get upload hash: %w", err)
+	}
+	var prev uploadHash
+	if err := doc.DataTo(&prev); err != nil {
+		return nil, fmt.Errorf("failed to parse upload hash: %w", err)
+	}
+
+	file, err := srv.Files.Get(prev.FileID).SupportsAllDrives(supportsAllDrives()).Fields("id, trashed").Context(ctx).Do()
+	if err != nil || file.Trashed {
+		debugf("Previous upload %s for user %s is gone: %v", prev.FileID, userID, err)
+		if _, err := ref.Delete(ctx); err != nil {
+			log.Printf("Failed to delete stale upload hash for user %s: %v", userID, err)
+		}
+		return nil, nil
+	}
+	return &prev, nil
+}
+
+// recordUploadHash indexes a new upload by its content hash.
+func recordUploadHash(ctx context.Context, userID, hash, fileID string, now time.Time) error {
+	_, err := firestoreClient.Collection(uploadHashCollection).Doc(uploadHashDocID(userID, hash)).Set(ctx, uploadHash{
+		FileID:     fileID,
+		Month:      now.In(botLocation).Format("2006-01"),
+		UploadedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save upload hash: %w", err)
+	}
+	return nil
+}
+
+// driveFileURL links to a file in the Drive web UI.
+func driveFileURL(fileID string) string {
+	return "https://drive.google.com/file/d/" + fileID + "/view"
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestSpoolContent tests that the spooled copy is complete and hashed.
+func TestSpoolContent(t *testing.T) {
+	f, hash, err := spoolContent(strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("spoolContent failed: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if hash != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("Expected sha256 of 'hello', but got: %s", hash)
+	}
+	got, _ := io.ReadAll(f)
+	if string(got) != "hello" {
+		t.Errorf("Expected spooled content 'hello', but got: '%s'", got)
+	}
+}
+
+// TestDedupeFits tests that only content within DEDUPE_MAX_BYTES is
+// spooled for duplicate detection.
+func TestDedupeFits(t *testing.T) {
+	original := maxDedupeBytes
+	defer func() { maxDedupeBytes = original }()
+	maxDedupeBytes = 10
+
+	if !dedupeFits(10) {
+		t.Errorf("Expected content at the limit to fit")
+	}
+	if dedupeFits(11) {
+		t.Errorf("Expected content over the limit not to fit")
+	}
+	if dedupeFits(-1) {
+		t.Errorf("Expected content of unknown length not to fit")
+	}
+
+	maxDedupeBytes = 0
+	if !dedupeFits(-1) {
+		t.Errorf("Expected everything to fit when DEDUPE_MAX_BYTES is 0")
+	}
+}
+
+// TestDedupeEnabled tests that duplicate detection defaults to on.
+func TestDedupeEnabled(t *testing.T) {
+	on, off := true, false
+	if !dedupeEnabled(&userPrefs{}) {
+		t.Error("Expected dedupe to be on by default")
+	}
+	if !dedupeEnabled(&userPrefs{Dedupe: &on}) {
+		t.Error("Expected dedupe on")
+	}
+	if dedupeEnabled(&userPrefs{Dedupe: &off}) {
+		t.Error("Expected dedupe off")
+	}
+}
+
+// TestFindPreviousUpload checks against the Firestore emulator that a known
+// hash returns the earlier upload, an unknown one misses, and a hash whose
+// file was trashed is forgotten.
+func TestFindPreviousUpload(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST not set")
+	}
+
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("Failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+	original := firestoreClient
+	firestoreClient = client
+	defer func() { firestoreClient = original }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trashed := strings.HasSuffix(r.URL.Path, "/file_trashed")
+		fmt.Fprintf(w, `{"id": "%s", "trashed": %v}`, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], trashed)
+	}))
+	defer server.Close()
+	srv, err := drive.NewService(ctx, option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create drive service: %v", err)
+	}
+
+	now := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	if err := recordUploadHash(ctx, "user_1", "hash_hit", "file_1", now); err != nil {
+		t.Fatalf("recordUploadHash failed: %v", err)
+	}
+	prev, err := findPreviousUpload(ctx, srv, "user_1", "hash_hit")
+	if err != nil || prev == nil || prev.FileID != "file_1" || prev.Month != "2024-03" {
+		t.Errorf("Expected a hit for file_1 in 2024-03, but got %+v, %v", prev, err)
+	}
+
+	if prev, err := findPreviousUpload(ctx, srv, "user_2", "hash_hit"); err != nil || prev != nil {
+		t.Errorf("Expected hashes to be per user, but got %+v, %v", prev, err)
+	}
+	if prev, err := findPreviousUpload(ctx, srv, "user_1", "hash_miss"); err != nil || prev != nil {
+		t.Errorf("Expected a miss, but got %+v, %v", prev, err)
+	}
+
+	if err := recordUploadHash(ctx, "user_1", "hash_trashed", "file_trashed", now); err != nil {
+		t.Fatalf("recordUploadHash failed: %v", err)
+	}
+	if prev, err := findPreviousUpload(ctx, srv, "user_1", "hash_trashed"); err != nil || prev != nil {
+		t.Errorf("Expected a trashed file to miss, but got %+v, %v", prev, err)
+	}
+	doc, _ := client.Collection(uploadHashCollection).Doc(uploadHashDocID("user_1", "hash_trashed")).Get(ctx)
+	if doc != nil && doc.Exists() {
+		t.Error("Expected the stale hash to be deleted")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// maxFolderDepth bounds the walk up a file's parents when checking that it
+// sits inside the upload folders.
+const maxFolderDepth = 10
+
+var (
+	errFileGone       = errors.New("file already removed")
+	errOutsideUploads = errors.New("file is outside the upload folders")
+	errIsFolder       = errors.New("file is a folder")
+)
+
+// handleDeletePostback moves a file to the trash from the Delete button on
+// a listing bubble, after the user confirmed it. Only files inside the
+// bot's upload folders may be deleted, and never folders.
+func handleDeletePostback(ctx context.Context, bot botClient, replyToken, userID, fileID string, confirmed bool) {
+	if fileID == "" {
+		return
+	}
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+	prefs, err := getUserPrefs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load prefs for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "error.read_settings"))
+		return
+	}
+
+	var roots []string
+	for _, name := range []string{rootFolderName(prefs), sandboxFolderName} {
+		id, err := findFolder(ctx, srv, name, "root")
+		if err != nil {
+			log.Printf("Failed to find folder '%s' for user %s: %v", name, userID, err)
+			replyForError(ctx, bot, replyToken, userID, err)
+			return
+		}
+		if id != "" {
+			roots = append(roots, id)
+		}
+	}
+
+	if !confirmed {
+		file, err := getDeletableUpload(ctx, srv, fileID, roots)
+		if replyDeleteError(ctx, bot, replyToken, userID, fileID, err) {
+			return
+		}
+		if err := sendReply(bot, replyToken, []messaging_api.MessageInterface{
+			&messaging_api.TextMessage{
+				Text: trf(ctx, userID, "delete.confirm_prompt", file.Name),
+				QuickReply: &messaging_api.QuickReply{
+					Items: []messaging_api.QuickReplyItem{
+						{Action: &messaging_api.PostbackAction{
+							Label:       tr(ctx, userID, "delete.confirm"),
+							Data:        "action=delete&confirm=1&fileId=" + url.QueryEscape(fileID),
+							DisplayText: tr(ctx, userID, "delete.confirm"),
+						}},
+						{Action: &messaging_api.PostbackAction{
+							Label:       tr(ctx, userID, "common.cancel"),
+							Data:        "action=delete_cancel",
+							DisplayText: tr(ctx, userID, "common.cancel"),
+						}},
+					},
+				},
+			},
+		}); err != nil {
+			log.Print(err)
+		}
+		return
+	}
+
+	name, err := trashUploadedFile(ctx, srv, fileID, roots)
+	if replyDeleteError(ctx, bot, replyToken, userID, fileID, err) {
+		return
+	}
+	replyText(bot, replyToken, trf(ctx, userID, "delete.done", name))
+}
+
+// replyDeleteError answers a failed delete and reports whether err was
+// non-nil.
+func replyDeleteError(ctx context.Context, bot botClient, replyToken, userID, fileID string, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, errFileGone):
+		replyText(bot, replyToken, tr(ctx, userID, "delete.gone"))
+	case errors.Is(err, errOutsideUploads):
+		log.Printf("User %s tried to delete file %s outside the upload folders", userID, fileID)
+		replyText(bot, replyToken, tr(ctx, userID, "delete.not_upload"))
+	case errors.Is(err, errIsFolder):
+		log.Printf("User %s tried to delete folder %s", userID, fileID)
+		replyText(bot, replyToken, tr(ctx, userID, "delete.folder"))
+	default:
+		log.Printf("Failed to delete file %s for user %s: %v", fileID, userID, err)
+		if !replyForError(ctx, bot, replyToken, userID, err) {
+			replyText(bot, replyToken, tr(ctx, userID, "delete.failed"))
+		}
+	}
+	return true
+}
+
+// getDeletableUpload returns the file if it is not a folder, not already
+// trashed, and one of its ancestors is in roots.
+func getDeletableUpload(ctx context.Context, srv *drive.Service, fileID string, roots []string) (*drive.File, error) {
+	file, err := srv.Files.Get(fileID).SupportsAllDrives(supportsAllDrives()).Fields("id, name, mimeType, parents, trashed").Context(ctx).Do()
+	if isNotFound(err) || (err == nil && file.Trashed) {
+		return nil, errFileGone
+	}
+	if err != nil {
+		return nil, err
+	}
+	if file.MimeType == folderMimeType {
+		return nil, errIsFolder
+	}
+
+	inside, err := hasAncestor(ctx, srv, file.Parents, roots)
+	if err != nil {
+		return nil, err
+	}
+	if !inside {
+		return nil, errOutsideUploads
+	}
+	return file, nil
+}
+
+// trashUploadedFile moves the file to the trash if getDeletableUpload
+// allows it and returns its name. It can be restored with /trash.
+func trashUploadedFile(ctx context.Context, srv *drive.Service, fileID string, roots []string) (string, error) {
+	file, err := getDeletableUpload(ctx, srv, fileID, roots)
+	if err != nil {
+		return "", err
+	}
+	if _, err := srv.Files.Update(fileID, &drive.File{Trashed: true}).SupportsAllDrives(supportsAllDrives()).Fields("id").Context(ctx).Do(); err != nil {
+		if isNotFound(err) {
+			return "", errFileGone
+		}
+		return "", err
+	}
+	return file.Name, nil
+}
+
+// hasAncestor walks up from parents and reports whether any folder on the
+// way is one of roots.
+func hasAncestor(ctx context.Context, srv *drive.Service, parents, roots []string) (bool, error) {
+	for depth := 0; len(parents) > 0 && depth < maxFolderDepth; depth++ {
+		for _, p := range parents {
+			if containsString(roots, p) {
+				return true, nil
+			}
+		}
+		folder, err := srv.Files.Get(parents[0]).SupportsAllDrives(supportsAllDrives()).Fields("parents").Context(ctx).Do()
+		if err != nil {
+			return false, fmt.Errorf("failed to get folder %s: %w", parents[0], err)
+		}
+		parents = folder.Parents
+	}
+	return false, nil
+}
+
+// isNotFound reports whether err is a Drive 404.
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestTrashUploadedFile tests that only files under the upload folders
+// are trashed, folders never are, and removed files are reported as gone.
+func TestTrashUploadedFile(t *testing.T) {
+	folders := map[string]*drive.File{
+		"inside":   {Id: "inside", Name: "a.jpg", Parents: []string{"month_id"}},
+		"outside":  {Id: "outside", Name: "b.jpg", Parents: []string{"other_id"}},
+		"trashed":  {Id: "trashed", Name: "c.jpg", Parents: []string{"month_id"}, Trashed: true},
+		"month_id": {Id: "month_id", MimeType: folderMimeType, Parents: []string{"main_id"}},
+		"other_id": {Id: "other_id", Parents: []string{"my_drive"}},
+		"my_drive": {Id: "my_drive"},
+	}
+	var trashed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Path[len("/files/"):]
+		switch r.Method {
+		case http.MethodGet:
+			f, ok := folders[id]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error": {"code": 404, "message": "File not found"}}`))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(f)
+		case http.MethodPatch:
+			trashed = append(trashed, id)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(folders[id])
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+	roots := []string{"main_id"}
+
+	name, err := trashUploadedFile(context.Background(), srv, "inside", roots)
+	if err != nil || name != "a.jpg" {
+		t.Errorf("Expected a.jpg to be trashed, but got %q, %v", name, err)
+	}
+	if _, err := trashUploadedFile(context.Background(), srv, "month_id", roots); !errors.Is(err, errIsFolder) {
+		t.Errorf("Expected errIsFolder, but got: %v", err)
+	}
+	if _, err := trashUploadedFile(context.Background(), srv, "outside", roots); !errors.Is(err, errOutsideUploads) {
+		t.Errorf("Expected errOutsideUploads, but got: %v", err)
+	}
+	for _, id := range []string{"trashed", "missing"} {
+		if _, err := trashUploadedFile(context.Background(), srv, id, roots); !errors.Is(err, errFileGone) {
+			t.Errorf("%s: expected errFileGone, but got: %v", id, err)
+		}
+	}
+	if len(trashed) != 1 || trashed[0] != "inside" {
+		t.Errorf("Expected only 'inside' to be trashed, but got: %v", trashed)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// descriptionData is the data available to description templates.
+type descriptionData struct {
+	Date string
+}
+
+// handleDescriptionCommand sets the Drive description applied to every
+// upload. "/description clear" removes it. Without arguments, the next
+// message is taken as the description.
+func handleDescriptionCommand(ctx context.Context, bot botClient, replyToken, userID string, args []string) {
+	if len(args) == 0 {
+		if err := setConversationState(ctx, userID, stateAwaitingDescription, nil); err != nil {
+			log.Printf("Failed to start /description for user %s: %v", userID, err)
+			replyText(bot, replyToken, tr(ctx, userID, "description.usage"))
+			return
+		}
+		replyText(bot, replyToken, tr(ctx, userID, "description.prompt"))
+		return
+	}
+	setDescription(ctx, bot, replyToken, userID, strings.Join(args, " "))
+}
+
+// setDescription validates and stores text as the upload description.
+func setDescription(ctx context.Context, bot botClient, replyToken, userID, text string) {
+	reply := trf(ctx, userID, "description.set", text)
+	if text == "clear" {
+		text = ""
+		reply = tr(ctx, userID, "description.cleared")
+	} else if _, err := template.New("description").Parse(text); err != nil {
+		replyText(bot, replyToken, tr(ctx, userID, "description.invalid"))
+		return
+	}
+
+	if err := updateUserPrefs(ctx, userID, map[string]interface{}{"description": text}); err != nil {
+		log.Printf("Failed to save description for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "description.failed"))
+		return
+	}
+	replyText(bot, replyToken, reply)
+}
+
+// renderDescription expands the {{.Date}} placeholder for an upload made
+// at now. Templates that fail to render are used verbatim.
+func renderDescription(text string, now time.Time) string {
+	if text == "" {
+		return ""
+	}
+	t, err := template.New("description").Parse(text)
+	if err != nil {
+		return text
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, descriptionData{Date: now.Format("2006-01-02")}); err != nil {
+		return text
+	}
+	return sb.String()
+}
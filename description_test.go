@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRenderDescription tests expanding the date placeholder.
+func TestRenderDescription(t *testing.T) {
+	now := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"Uploaded from LINE", "Uploaded from LINE"},
+		{"Uploaded on {{.Date}}", "Uploaded on 2024-03-05"},
+		{"Broken {{.Date", "Broken {{.Date"},
+	}
+	for _, tt := range tests {
+		if got := renderDescription(tt.in, now); got != tt.want {
+			t.Errorf("%q: expected '%s', but got: '%s'", tt.in, tt.want, got)
+		}
+	}
+}
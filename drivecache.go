@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// driveServiceTTL is how long a user's Drive service is reused before the
+// token is read from Firestore again.
+const driveServiceTTL = 5 * time.Minute
+
+// driveServices caches each user's Drive service so several media sent in
+// quick succession share one Firestore read and token source.
+var driveServices = newDriveServiceCache(driveServiceTTL, buildGoogleDriveService)
+
+type cachedDriveService struct {
+	srv     *drive.Service
+	expires time.Time
+}
+
+// driveServiceCache maps userIDs to Drive services built by build.
+type driveServiceCache struct {
+	mu      sync.RWMutex
+	entries map[string]cachedDriveService
+	ttl     time.Duration
+	build   func(ctx context.Context, userID string) (*drive.Service, error)
+}
+
+func newDriveServiceCache(ttl time.Duration, build func(ctx context.Context, userID string) (*drive.Service, error)) *driveServiceCache {
+	return &driveServiceCache{
+		entries: map[string]cachedDriveService{},
+		ttl:     ttl,
+		build:   build,
+	}
+}
+
+// get returns the cached service for the user, building a new one when
+// there is none or it has expired. ctx bounds loading the token. Errors
+// are not cached.
+func (c *driveServiceCache) get(ctx context.Context, userID string, now time.Time) (*drive.Service, error) {
+	c.mu.RLock()
+	entry, ok := c.entries[userID]
+	c.mu.RUnlock()
+	if ok && now.Before(entry.expires) {
+		return entry.srv, nil
+	}
+
+	srv, err := c.build(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[userID] = cachedDriveService{srv: srv, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return srv, nil
+}
+
+// invalidate drops the user's cached service, e.g. after their token was
+// revoked or replaced.
+func (c *driveServiceCache) invalidate(userID string) {
+	c.mu.Lock()
+	delete(c.entries, userID)
+	c.mu.Unlock()
+}
+
+// invalidateOnAuthError drops the user's cached service when err means
+// their token no longer works, so the next call reloads it.
+func (c *driveServiceCache) invalidateOnAuthError(userID string, err error) {
+	switch classify(err) {
+	case ErrTokenNotFound, ErrTokenInvalid, ErrInsufficientScope:
+		c.invalidate(userID)
+	}
+}
+
+// buildGoogleDriveService loads the user's token from Firestore and builds
+// a Drive service for it, noting the token's last use. The service is
+// cached beyond the request, so its token source must not inherit ctx's
+// deadline.
+func buildGoogleDriveService(ctx context.Context, userID string) (*drive.Service, error) {
+	token, err := loadToken(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	srv, err := newDriveService(context.Background(), &token.Token)
+	if err != nil {
+		return nil, err
+	}
+	touchTokenLastUsed(ctx, userID, token, time.Now())
+	return srv, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// TestDriveServiceCache tests that a second upload within the TTL reuses
+// the service instead of reading the token from Firestore again.
+func TestDriveServiceCache(t *testing.T) {
+	reads := 0
+	cache := newDriveServiceCache(5*time.Minute, func(ctx context.Context, userID string) (*drive.Service, error) {
+		reads++
+		return &drive.Service{}, nil
+	})
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	first, _ := cache.get(ctx, "user1", now)
+	second, _ := cache.get(ctx, "user1", now.Add(time.Minute))
+	if reads != 1 {
+		t.Errorf("Expected 1 token read within the TTL, but got %d", reads)
+	}
+	if first != second {
+		t.Errorf("Expected the cached service to be reused")
+	}
+
+	cache.get(ctx, "user1", now.Add(6*time.Minute))
+	if reads != 2 {
+		t.Errorf("Expected a new token read after the TTL, but got %d reads", reads)
+	}
+
+	cache.invalidateOnAuthError("user1", ErrTokenInvalid)
+	cache.get(ctx, "user1", now.Add(7*time.Minute))
+	if reads != 3 {
+		t.Errorf("Expected a new token read after an auth error, but got %d reads", reads)
+	}
+
+	cache.invalidateOnAuthError("user1", ErrRateLimited)
+	cache.get(ctx, "user1", now.Add(8*time.Minute))
+	if reads != 3 {
+		t.Errorf("Expected other errors to keep the cache, but got %d reads", reads)
+	}
+}
+
+// TestGetDriveServiceOrPromptDeadline tests that a token lookup blocking
+// past the event deadline gives up and tells the user to try again.
+func TestGetDriveServiceOrPromptDeadline(t *testing.T) {
+	original := driveServices
+	defer func() { driveServices = original }()
+	driveServices = newDriveServiceCache(time.Minute, func(ctx context.Context, userID string) (*drive.Service, error) {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to get token from firestore: %w", ctx.Err())
+		case <-time.After(5 * time.Second):
+			return &drive.Service{}, nil
+		}
+	})
+	bot, texts := newReplyRecorder(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, ok := getDriveServiceOrPrompt(ctx, bot, "token", "user1"); ok {
+		t.Fatal("Expected no service after the deadline")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to give up at the deadline, but took %v", elapsed)
+	}
+	if len(*texts) != 1 || (*texts)[0] != translate(langZh, "error.timeout") {
+		t.Errorf("Expected the timeout reply, but got: %q", *texts)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Typed errors returned by classify. Handlers switch on these to pick a
+// reply instead of inspecting raw Google or Firestore errors.
+var (
+	ErrTokenNotFound = errors.New("oauth2 token not found")
+	ErrTokenInvalid  = errors.New("oauth2 token is expired or revoked")
+	ErrQuotaExceeded = errors.New("drive storage quota exceeded")
+	ErrRateLimited   = errors.New("drive rate limit exceeded")
+	ErrFileTooLarge  = errors.New("file too large")
+
+	// ErrInsufficientScope means the token works but the user didn't
+	// grant every scope we need, e.g. after unticking Drive on consent.
+	ErrInsufficientScope = errors.New("oauth2 token lacks required scopes")
+
+	// ErrTimeout means Firestore or Drive didn't answer before the
+	// event's deadline.
+	ErrTimeout = errors.New("dependency timed out")
+)
+
+var typedErrors = []error{
+	ErrTokenNotFound,
+	ErrTokenInvalid,
+	ErrQuotaExceeded,
+	ErrRateLimited,
+	ErrFileTooLarge,
+	ErrInsufficientScope,
+	ErrTimeout,
+}
+
+// classify maps a raw error to one of the typed errors above. Errors it
+// does not recognize are returned unchanged.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, typed := range typedErrors {
+		if errors.Is(err, typed) {
+			return typed
+		}
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		// Drive reports quota and rate limit problems as 403s, so the
+		// reason has to be checked before treating 403 as an auth issue.
+		for _, item := range apiErr.Errors {
+			switch item.Reason {
+			case "storageQuotaExceeded", "quotaExceeded":
+				return ErrQuotaExceeded
+			case "userRateLimitExceeded", "rateLimitExceeded":
+				return ErrRateLimited
+			case "insufficientPermissions", "ACCESS_TOKEN_SCOPE_INSUFFICIENT":
+				return ErrInsufficientScope
+			}
+		}
+		switch apiErr.Code {
+		case http.StatusTooManyRequests:
+			return ErrRateLimited
+		case http.StatusRequestEntityTooLarge:
+			return ErrFileTooLarge
+		}
+	}
+
+	if isGoogleAuthError(err) {
+		return ErrTokenInvalid
+	}
+	// Firestore reports deadlines as gRPC statuses rather than wrapping
+	// context.DeadlineExceeded.
+	if errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded {
+		return ErrTimeout
+	}
+	return err
+}
+
+// replyForError sends the reply matching a classified error. It returns
+// false when the error is not one of the typed errors, leaving the reply
+// to the caller.
+func replyForError(ctx context.Context, bot botClient, replyToken, userID string, err error) bool {
+	switch classify(err) {
+	case ErrTokenNotFound:
+		sendConnectionPrompt(ctx, bot, replyToken, userID)
+	case ErrTokenInvalid:
+		sendReconnectionPrompt(ctx, bot, replyToken, userID)
+	case ErrQuotaExceeded:
+		replyText(bot, replyToken, tr(ctx, userID, "error.quota"))
+	case ErrRateLimited:
+		replyText(bot, replyToken, tr(ctx, userID, "error.rate_limited"))
+	case ErrFileTooLarge:
+		replyText(bot, replyToken, tr(ctx, userID, "error.too_large"))
+	case ErrInsufficientScope:
+		replyText(bot, replyToken, trf(ctx, userID, "error.scope", reconnectCommand))
+	case ErrTimeout:
+		replyText(bot, replyToken, tr(ctx, userID, "error.timeout"))
+	default:
+		return false
+	}
+	return true
+}
+
+// missingScopes returns the required scopes absent from the token's
+// granted scopes. Google reports them in the "scope" field of the token
+// response; when it's missing nothing can be checked and nil is returned.
+func missingScopes(token *oauth2.Token, required []string) []string {
+	granted, _ := token.Extra("scope").(string)
+	if granted == "" {
+		return nil
+	}
+	have := map[string]bool{}
+	for _, s := range strings.Fields(granted) {
+		have[s] = true
+	}
+	var missing []string
+	for _, s := range required {
+		if !have[s] {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+// isGoogleAuthError checks if the error from a Google API call is due to
+// an authentication/authorization issue (e.g., expired or revoked token).
+func isGoogleAuthError(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		// 401 Unauthorized or 403 Forbidden are strong indicators of a token issue.
+		return apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden
+	}
+
+	// The oauth2 library returns invalid_grant when the refresh token is
+	// expired, revoked, or otherwise invalid.
+	return isInvalidGrantError(err)
+}
+
+// isInvalidGrantError reports whether Google rejected the refresh token,
+// which means the user has to authorize the app again. Errors that aren't
+// a RetrieveError, e.g. ones flattened into a string by another library,
+// are matched on their text.
+func isInvalidGrantError(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return retrieveErrorCode(retrieveErr) == "invalid_grant"
+	}
+	return err != nil && strings.Contains(err.Error(), "invalid_grant")
+}
+
+// retrieveErrorCode returns the OAuth error code of a failed token
+// request. The oauth2 package only fills in ErrorCode when it recognized
+// the response, so the JSON or form-encoded body is decoded as a fallback.
+func retrieveErrorCode(e *oauth2.RetrieveError) string {
+	if e.ErrorCode != "" {
+		return e.ErrorCode
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(e.Body, &body); err == nil {
+		return body.Error
+	}
+	if values, err := url.ParseQuery(string(e.Body)); err == nil {
+		return values.Get("error")
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestClassify tests mapping raw errors to the typed errors.
+func TestClassify(t *testing.T) {
+	unknown := errors.New("connection reset")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"nil", nil, nil},
+		{"token not found", fmt.Errorf("wrapped: %w", ErrTokenNotFound), ErrTokenNotFound},
+		{"unauthorized", &googleapi.Error{Code: http.StatusUnauthorized}, ErrTokenInvalid},
+		{"forbidden", &googleapi.Error{Code: http.StatusForbidden}, ErrTokenInvalid},
+		{"storage quota", &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "storageQuotaExceeded"}}}, ErrQuotaExceeded},
+		{"user rate limit", &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "userRateLimitExceeded"}}}, ErrRateLimited},
+		{"too many requests", &googleapi.Error{Code: http.StatusTooManyRequests}, ErrRateLimited},
+		{"insufficient scope", &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "insufficientPermissions"}}}, ErrInsufficientScope},
+		{"too large", &googleapi.Error{Code: http.StatusRequestEntityTooLarge}, ErrFileTooLarge},
+		{"invalid grant", fmt.Errorf("refresh: %w", &oauth2.RetrieveError{ErrorCode: "invalid_grant"}), ErrTokenInvalid},
+		{"deadline", fmt.Errorf("failed to get token from firestore: %w", context.DeadlineExceeded), ErrTimeout},
+		{"grpc deadline", status.Error(codes.DeadlineExceeded, "deadline exceeded"), ErrTimeout},
+		{"unknown", unknown, unknown},
+	}
+	for _, tt := range tests {
+		if got := classify(tt.err); got != tt.want {
+			t.Errorf("%s: expected %v, but got: %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestIsInvalidGrantError tests detection of rejected refresh tokens in
+// the shapes the oauth2 package returns them.
+func TestIsInvalidGrantError(t *testing.T) {
+	jsonResponse := &http.Response{StatusCode: http.StatusBadRequest, Header: http.Header{"Content-Type": {"application/json"}}}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"invalid_grant", &oauth2.RetrieveError{Response: jsonResponse, ErrorCode: "invalid_grant", ErrorDescription: "Token has been expired or revoked."}, true},
+		{"wrapped invalid_grant", fmt.Errorf("refresh: %w", &oauth2.RetrieveError{ErrorCode: "invalid_grant"}), true},
+		{"JSON body only", &oauth2.RetrieveError{Response: jsonResponse, Body: []byte(`{"error_description": "Bad Request", "error": "invalid_grant"}`)}, true},
+		{"form body only", &oauth2.RetrieveError{Body: []byte("error=invalid_grant&error_description=Bad+Request")}, true},
+		{"invalid_client", &oauth2.RetrieveError{Response: jsonResponse, ErrorCode: "invalid_client", ErrorDescription: "The OAuth client was not found."}, false},
+		{"invalid_client mentioning invalid_grant", &oauth2.RetrieveError{ErrorCode: "invalid_client", Body: []byte(`{"error": "invalid_client", "error_description": "not an invalid_grant"}`)}, false},
+		{"flattened string", errors.New(`oauth2: cannot fetch token: 400 Bad Request Response: {"error": "invalid_grant"}`), true},
+		{"unrelated", errors.New("connection reset"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isInvalidGrantError(tt.err); got != tt.want {
+			t.Errorf("%s: expected %v, but got: %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestIsGoogleAuthError tests which Drive and OAuth errors mean the token
+// no longer works.
+func TestIsGoogleAuthError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unauthorized", &googleapi.Error{Code: http.StatusUnauthorized}, true},
+		{"forbidden", &googleapi.Error{Code: http.StatusForbidden}, true},
+		{"not found", &googleapi.Error{Code: http.StatusNotFound}, false},
+		{"invalid_grant", fmt.Errorf("get token: %w", &oauth2.RetrieveError{ErrorCode: "invalid_grant"}), true},
+		{"invalid_client", &oauth2.RetrieveError{ErrorCode: "invalid_client"}, false},
+		{"unrelated", errors.New("connection reset"), false},
+	}
+	for _, tt := range tests {
+		if got := isGoogleAuthError(tt.err); got != tt.want {
+			t.Errorf("%s: expected %v, but got: %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestMissingScopes tests comparing granted and required scopes.
+func TestMissingScopes(t *testing.T) {
+	required := []string{"https://www.googleapis.com/auth/drive.file"}
+
+	token := (&oauth2.Token{}).WithExtra(map[string]interface{}{"scope": "openid https://www.googleapis.com/auth/drive.file"})
+	if got := missingScopes(token, required); len(got) != 0 {
+		t.Errorf("Expected no missing scopes, but got: %v", got)
+	}
+
+	token = (&oauth2.Token{}).WithExtra(map[string]interface{}{"scope": "openid"})
+	if got := missingScopes(token, required); len(got) != 1 {
+		t.Errorf("Expected drive.file to be missing, but got: %v", got)
+	}
+
+	if got := missingScopes(&oauth2.Token{}, required); got != nil {
+		t.Errorf("Expected nil without a scope field, but got: %v", got)
+	}
+}
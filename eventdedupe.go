@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// processedEventCollection records the webhook event IDs already handled
+// so events LINE redelivers after a slow response aren't handled twice.
+// Documents carry expires_at for a Firestore TTL policy.
+const processedEventCollection = "processed_events"
+
+type processedEvent struct {
+	ExpiresAt time.Time `firestore:"expires_at"`
+}
+
+// webhookEventID returns the event's unique ID, or "" for event types the
+// bot doesn't handle.
+func webhookEventID(event webhook.EventInterface) string {
+	switch e := event.(type) {
+	case webhook.MessageEvent:
+		return e.WebhookEventId
+	case webhook.PostbackEvent:
+		return e.WebhookEventId
+	case webhook.FollowEvent:
+		return e.WebhookEventId
+	case webhook.MemberJoinedEvent:
+		return e.WebhookEventId
+	case webhook.MemberLeftEvent:
+		return e.WebhookEventId
+	case webhook.AccountLinkEvent:
+		return e.WebhookEventId
+	case webhook.VideoPlayCompleteEvent:
+		return e.WebhookEventId
+	}
+	return ""
+}
+
+// isRedelivery reports whether LINE marked the event as a redelivery of
+// one it sent before. Every event type with a delivery context is listed,
+// including those the bot ignores, so logs flag their redeliveries too.
+func isRedelivery(event webhook.EventInterface) bool {
+	var dc *webhook.DeliveryContext
+	switch e := event.(type) {
+	case webhook.MessageEvent:
+		dc = e.DeliveryContext
+	case webhook.PostbackEvent:
+		dc = e.DeliveryContext
+	case webhook.FollowEvent:
+		dc = e.DeliveryContext
+	case webhook.UnfollowEvent:
+		dc = e.DeliveryContext
+	case webhook.JoinEvent:
+		dc = e.DeliveryContext
+	case webhook.LeaveEvent:
+		dc = e.DeliveryContext
+	case webhook.MemberJoinedEvent:
+		dc = e.DeliveryContext
+	case webhook.MemberLeftEvent:
+		dc = e.DeliveryContext
+	case webhook.AccountLinkEvent:
+		dc = e.DeliveryContext
+	case webhook.VideoPlayCompleteEvent:
+		dc = e.DeliveryContext
+	case webhook.UnsendEvent:
+		dc = e.DeliveryContext
+	case webhook.BeaconEvent:
+		dc = e.DeliveryContext
+	case webhook.ThingsEvent:
+		dc = e.DeliveryContext
+	case webhook.ModuleEvent:
+		dc = e.DeliveryContext
+	case webhook.ActivatedEvent:
+		dc = e.DeliveryContext
+	case webhook.DeactivatedEvent:
+		dc = e.DeliveryContext
+	case webhook.BotSuspendedEvent:
+		dc = e.DeliveryContext
+	case webhook.BotResumedEvent:
+		dc = e.DeliveryContext
+	case webhook.PnpDeliveryCompletionEvent:
+		dc = e.DeliveryContext
+	}
+	return dc != nil && dc.IsRedelivery
+}
+
+// handleEventOnce handles the event unless its ID was already processed.
+// The ID is recorded only once handleEvent succeeded within ctx, so an
+// event whose handling failed or was cut short is handled again when LINE
+// redelivers it. When
+// the check itself fails, first deliveries are handled anyway but
+// redeliveries are dropped: during a retry storm a duplicate upload or
+// reply is worse than a missed one.
+func handleEventOnce(ctx context.Context, bot botClient, blob blobClient, host string, event webhook.EventInterface) {
+	logger := eventLogger(event)
+	ctx = withLogger(ctx, logger)
+	logger.Info("Handling event")
+
+	id := webhookEventID(event)
+	if id == "" || eventDedupeTTL <= 0 {
+		if err := handleEvent(ctx, bot, blob, host, event); err != nil {
+			logger.Warn("Event not fully handled", "error", err)
+		}
+		return
+	}
+
+	redelivered := isRedelivery(event)
+	done, err := isEventProcessed(ctx, id, time.Now())
+	if err != nil && redelivered {
+		logger.Warn("Failed to check redelivered event, skipping it", "error", err)
+		return
+	}
+	if err != nil {
+		logger.Warn("Failed to check event, handling it anyway", "error", err)
+	}
+	if done {
+		logger.Info("Skipping already processed event")
+		return
+	}
+
+	err = handleEvent(ctx, bot, blob, host, event)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		// Left unrecorded so a redelivery gets another chance.
+		logger.Warn("Event not fully handled, not recording it", "error", err)
+		return
+	}
+
+	if err := markEventProcessed(context.WithoutCancel(ctx), id, time.Now()); err != nil {
+		logger.Error("Failed to record processed event", "error", err)
+	}
+}
+
+func isEventProcessed(ctx context.Context, id string, now time.Time) (bool, error) {
+	doc, err := firestoreClient.Collection(processedEventCollection).Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get processed event: %w", err)
+	}
+	var pe processedEvent
+	if err := doc.DataTo(&pe); err != nil {
+		return false, fmt.Errorf("failed to parse processed event: %w", err)
+	}
+	// Firestore TTL deletion can lag, so check the expiry too.
+	return now.Before(pe.ExpiresAt), nil
+}
+
+func markEventProcessed(ctx context.Context, id string, now time.Time) error {
+	_, err := firestoreClient.Collection(processedEventCollection).Doc(id).Set(ctx, processedEvent{ExpiresAt: now.Add(eventDedupeTTL)})
+	if err != nil {
+		return fmt.Errorf("failed to save processed event: %w", err)
+	}
+	return nil
+}
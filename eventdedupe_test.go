@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+)
+
+// TestWebhookEventID tests reading the event ID from handled event types.
+func TestWebhookEventID(t *testing.T) {
+	tests := []struct {
+		event webhook.EventInterface
+		want  string
+	}{
+		{webhook.MessageEvent{WebhookEventId: "msg"}, "msg"},
+		{webhook.PostbackEvent{WebhookEventId: "pb"}, "pb"},
+		{webhook.FollowEvent{WebhookEventId: "follow"}, "follow"},
+		{webhook.UnfollowEvent{WebhookEventId: "unfollow"}, ""},
+	}
+	for _, tt := range tests {
+		if got := webhookEventID(tt.event); got != tt.want {
+			t.Errorf("%T: expected '%s', but got: '%s'", tt.event, tt.want, got)
+		}
+	}
+}
+
+// TestIsRedelivery tests reading LINE's redelivery flag.
+func TestIsRedelivery(t *testing.T) {
+	tests := []struct {
+		event webhook.EventInterface
+		want  bool
+	}{
+		{webhook.MessageEvent{DeliveryContext: &webhook.DeliveryContext{IsRedelivery: true}}, true},
+		{webhook.PostbackEvent{DeliveryContext: &webhook.DeliveryContext{IsRedelivery: false}}, false},
+		{webhook.MessageEvent{}, false},
+		{webhook.UnfollowEvent{DeliveryContext: &webhook.DeliveryContext{IsRedelivery: true}}, true},
+		{webhook.JoinEvent{DeliveryContext: &webhook.DeliveryContext{IsRedelivery: true}}, true},
+		{webhook.BeaconEvent{DeliveryContext: &webhook.DeliveryContext{IsRedelivery: true}}, true},
+		{webhook.UnfollowEvent{}, false},
+	}
+	for _, tt := range tests {
+		if got := isRedelivery(tt.event); got != tt.want {
+			t.Errorf("%T %+v: expected %v, but got: %v", tt.event, tt.event, tt.want, got)
+		}
+	}
+}
+
+// TestHandleEventOnceRedeliveryUncheckable tests that a redelivered media
+// message is not uploaded when Firestore can't tell whether the first
+// delivery was handled.
+func TestHandleEventOnceRedeliveryUncheckable(t *testing.T) {
+	// Nothing listens here, so every Firestore call fails.
+	t.Setenv("FIRESTORE_EMULATOR_HOST", "127.0.0.1:1")
+	client, err := firestore.NewClient(context.Background(), "test-project")
+	if err != nil {
+		t.Fatalf("Failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+	original := firestoreClient
+	firestoreClient = client
+	defer func() { firestoreClient = original }()
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	bot, err := messaging_api.NewMessagingApiAPI("token", messaging_api.WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create mock bot: %v", err)
+	}
+	blob, err := messaging_api.NewMessagingApiBlobAPI("token", messaging_api.WithBlobEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create mock blob client: %v", err)
+	}
+
+	event := webhook.MessageEvent{
+		WebhookEventId:  "event_1",
+		ReplyToken:      "reply_1",
+		DeliveryContext: &webhook.DeliveryContext{IsRedelivery: true},
+		Source:          webhook.UserSource{UserId: "user1"},
+		Message:         webhook.ImageMessageContent{Id: "message_1"},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	handleEventOnce(ctx, bot, blob, "example.com", event)
+
+	if len(requests) != 0 {
+		t.Errorf("Expected the redelivered event to be skipped, but got requests: %v", requests)
+	}
+}
+
+// TestHandleEventOnceFailureNotRecorded checks against the Firestore
+// emulator that an event whose upload failed is left unrecorded so a
+// redelivery retries it, while a handled one is recorded.
+func TestHandleEventOnceFailureNotRecorded(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST not set")
+	}
+
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("Failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+	original := firestoreClient
+	firestoreClient = client
+	defer func() { firestoreClient = original }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every content download fails.
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	blob, err := messaging_api.NewMessagingApiBlobAPI("token", messaging_api.WithBlobEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create mock blob client: %v", err)
+	}
+	bot := newFakeBot()
+
+	failed := webhook.MessageEvent{
+		WebhookEventId: "event_failed",
+		ReplyToken:     "reply_1",
+		Source:         webhook.UserSource{UserId: "user1"},
+		Message:        webhook.ImageMessageContent{Id: "message_failed"},
+	}
+	handleEventOnce(ctx, bot, blob, "example.com", failed)
+	if done, err := isEventProcessed(ctx, "event_failed", time.Now()); err != nil || done {
+		t.Errorf("Expected the failed event not to be recorded, but got done=%v err=%v", done, err)
+	}
+
+	handled := webhook.MessageEvent{
+		WebhookEventId: "event_handled",
+		ReplyToken:     "reply_2",
+		Source:         webhook.UserSource{UserId: "user1"},
+		Message:        webhook.StickerMessageContent{StickerId: "1"},
+	}
+	handleEventOnce(ctx, bot, blob, "example.com", handled)
+	if done, err := isEventProcessed(ctx, "event_handled", time.Now()); err != nil || !done {
+		t.Errorf("Expected the handled event to be recorded, but got done=%v err=%v", done, err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+)
+
+// externalFetchTimeout bounds the whole download of external content.
+const externalFetchTimeout = 60 * time.Second
+
+// errExternalContentBlocked is returned for external content whose URL is
+// not allowed by EXTERNAL_CONTENT_HOSTS.
+var errExternalContentBlocked = errors.New("external content host not allowed")
+
+// externalContentURL logs the message's content provider and returns the
+// URL to fetch for external content, or "" for content hosted by LINE.
+func externalContentURL(messageID string, p *webhook.ContentProvider) string {
+	if p == nil {
+		return ""
+	}
+	debugf("Content provider for message %s: %s", messageID, p.Type)
+	if p.Type != webhook.ContentProviderTYPE_EXTERNAL {
+		return ""
+	}
+	log.Printf("Message %s has external content: %s", messageID, redactURL(p.OriginalContentUrl))
+	return p.OriginalContentUrl
+}
+
+// checkExternalURL allows only https URLs whose host is in
+// externalContentHosts, so the bot can't be pointed at internal services.
+func checkExternalURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errExternalContentBlocked, err)
+	}
+	if u.Scheme != "https" || !externalContentHosts[strings.ToLower(u.Hostname())] {
+		return fmt.Errorf("%w: %s", errExternalContentBlocked, raw)
+	}
+	return nil
+}
+
+var externalClient = &http.Client{
+	Timeout: externalFetchTimeout,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		return checkExternalURL(req.URL.String())
+	},
+}
+
+// fetchExternalContent downloads content hosted outside LINE. Only allowed
+// hosts are contacted, redirects are checked the same way, and the body
+// is cut off at maxExternalContentBytes.
+func fetchExternalContent(raw string) (*http.Response, error) {
+	if err := checkExternalURL(raw); err != nil {
+		return nil, err
+	}
+	resp, err := externalClient.Get(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch external content: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch external content: status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxExternalContentBytes {
+		resp.Body.Close()
+		return nil, fmt.Errorf("external content too large: %d bytes", resp.ContentLength)
+	}
+	resp.Body = &limitedBody{r: io.LimitReader(resp.Body, maxExternalContentBytes+1), c: resp.Body}
+	return resp, nil
+}
+
+// limitedBody fails reads past maxExternalContentBytes for responses that
+// didn't declare their length.
+type limitedBody struct {
+	r io.Reader
+	c io.Closer
+	n int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	if b.n > maxExternalContentBytes {
+		return n, fmt.Errorf("external content exceeds %d bytes", maxExternalContentBytes)
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.c.Close()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestCheckExternalURL tests the scheme and host allowlist.
+func TestCheckExternalURL(t *testing.T) {
+	externalContentHosts = map[string]bool{"cdn.example.com": true}
+	defer func() { externalContentHosts = nil }()
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://cdn.example.com/a.jpg", true},
+		{"https://CDN.example.com/a.jpg", true},
+		{"http://cdn.example.com/a.jpg", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"https://cdn.example.com.evil.com/a.jpg", false},
+		{"://bad", false},
+	}
+	for _, tt := range tests {
+		err := checkExternalURL(tt.url)
+		if tt.allowed && err != nil {
+			t.Errorf("%s: expected allowed, but got: %v", tt.url, err)
+		}
+		if !tt.allowed && !errors.Is(err, errExternalContentBlocked) {
+			t.Errorf("%s: expected blocked, but got: %v", tt.url, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// sanitizeFilename makes a user supplied file name safe to store in Drive:
+// path separators are replaced and over-long names are truncated to
+// maxFilenameLen characters while keeping the extension intact.
+func sanitizeFilename(name string) string {
+	name = strings.TrimSpace(name)
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	return truncateFilename(name, maxFilenameLen)
+}
+
+// truncateFilename shortens name to at most max runes, preserving the
+// extension when it fits.
+func truncateFilename(name string, max int) string {
+	runes := []rune(name)
+	if max <= 0 || len(runes) <= max {
+		return name
+	}
+
+	ext := []rune(filepath.Ext(name))
+	if len(ext) >= max {
+		return string(runes[:max])
+	}
+	base := runes[:len(runes)-len(ext)]
+	return string(base[:max-len(ext)]) + string(ext)
+}
+
+// sniffExtensions maps content types detected by http.DetectContentType to
+// the extension we append to extension-less file names.
+var sniffExtensions = map[string]string{
+	"application/pdf":    ".pdf",
+	"application/zip":    ".zip",
+	"application/x-gzip": ".gz",
+	"application/ogg":    ".ogg",
+	"audio/mpeg":         ".mp3",
+	"audio/wave":         ".wav",
+	"image/bmp":          ".bmp",
+	"image/gif":          ".gif",
+	"image/jpeg":         ".jpg",
+	"image/png":          ".png",
+	"image/webp":         ".webp",
+	"text/html":          ".html",
+	"text/plain":         ".txt",
+	"video/mp4":          ".mp4",
+	"video/webm":         ".webm",
+}
+
+// ensureExtension appends an extension matching the sniffed content type
+// when name has none, so Drive can preview the file. The returned reader
+// yields the full content, including the sniffed bytes.
+func ensureExtension(name string, r io.Reader) (string, io.Reader, error) {
+	if filepath.Ext(name) != "" {
+		return name, r, nil
+	}
+
+	br := bufio.NewReaderSize(r, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return name, nil, err
+	}
+
+	if ext, ok := sniffExtensions[sniffContentType(head)]; ok {
+		name += ext
+	}
+	return name, br, nil
+}
+
+// detectMediaType sniffs the first bytes of r and returns the matching
+// extension and MIME type. ext is empty when the type is not one we know.
+// rest yields the full content, including the sniffed bytes; read errors
+// are left for the caller to hit when reading rest.
+func detectMediaType(r io.Reader) (ext, mime string, rest io.Reader) {
+	br := bufio.NewReaderSize(r, 512)
+	head, _ := br.Peek(512)
+	mime = sniffContentType(head)
+	return sniffExtensions[mime], mime, br
+}
+
+// sniffContentType returns the content type of head without parameters.
+func sniffContentType(head []byte) string {
+	contentType := http.DetectContentType(head)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return contentType
+}
+
+// imageFileType gives an image upload the extension and MIME type of its
+// actual format. The content is sniffed first, then the Content-Type
+// header is consulted; JPEG is assumed only when both fail.
+func imageFileType(name, header string, r io.Reader) (string, string, io.Reader) {
+	ext, mimeType, rest := detectMediaType(r)
+	if ext == "" || !strings.HasPrefix(mimeType, "image/") {
+		mimeType = strings.TrimSpace(strings.Split(header, ";")[0])
+		ext = sniffExtensions[mimeType]
+		if ext == "" || !strings.HasPrefix(mimeType, "image/") {
+			ext, mimeType = ".jpg", "image/jpeg"
+		}
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ext, mimeType, rest
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestSanitizeFilenameTruncates tests that long names are cut to the limit
+// with their extension intact.
+func TestSanitizeFilenameTruncates(t *testing.T) {
+	maxFilenameLen = 255
+	name := strings.Repeat("a", 300) + ".pdf"
+
+	got := sanitizeFilename(name)
+	if utf8.RuneCountInString(got) != 255 {
+		t.Errorf("Expected length 255, but got: %d", utf8.RuneCountInString(got))
+	}
+	if !strings.HasSuffix(got, ".pdf") {
+		t.Errorf("Expected extension '.pdf' to be preserved, but got: '%s'", got[len(got)-10:])
+	}
+}
+
+// TestSanitizeFilenameSeparators tests that path separators are replaced.
+func TestSanitizeFilenameSeparators(t *testing.T) {
+	maxFilenameLen = 255
+	if got := sanitizeFilename(" a/b\\c.txt "); got != "a_b_c.txt" {
+		t.Errorf("Expected 'a_b_c.txt', but got: '%s'", got)
+	}
+}
+
+// TestEnsureExtension tests that extension-less names get a sniffed
+// extension and that the content is preserved.
+func TestEnsureExtension(t *testing.T) {
+	pdf := "%PDF-1.4 test document"
+	name, r, err := ensureExtension("report", strings.NewReader(pdf))
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if name != "report.pdf" {
+		t.Errorf("Expected 'report.pdf', but got: '%s'", name)
+	}
+	b, _ := io.ReadAll(r)
+	if string(b) != pdf {
+		t.Errorf("Expected content to be preserved, but got: '%s'", b)
+	}
+
+	name, _, _ = ensureExtension("photo.png", strings.NewReader(pdf))
+	if name != "photo.png" {
+		t.Errorf("Expected existing extension to be kept, but got: '%s'", name)
+	}
+}
+
+func TestDetectMediaType(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		ext     string
+		mime    string
+	}{
+		{"png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", ".png", "image/png"},
+		{"jpeg", "\xff\xd8\xff\xe0\x00\x10JFIF", ".jpg", "image/jpeg"},
+		{"gif", "GIF89a\x01\x00\x01\x00", ".gif", "image/gif"},
+		{"unknown", "\x00\x01\x02\x03", "", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ext, mime, rest := detectMediaType(strings.NewReader(tt.content))
+			if ext != tt.ext || mime != tt.mime {
+				t.Errorf("Expected %q %q, but got: %q %q", tt.ext, tt.mime, ext, mime)
+			}
+			b, _ := io.ReadAll(rest)
+			if string(b) != tt.content {
+				t.Errorf("Expected content to be preserved, but got: %q", b)
+			}
+		})
+	}
+}
+
+func TestImageFileType(t *testing.T) {
+	name, mime, _ := imageFileType("line-bot-upload-1.jpg", "image/jpeg", strings.NewReader("GIF89a\x01\x00\x01\x00"))
+	if name != "line-bot-upload-1.gif" || mime != "image/gif" {
+		t.Errorf("Expected sniffed GIF, but got: %q %q", name, mime)
+	}
+
+	name, mime, _ = imageFileType("line-bot-upload-1.jpg", "image/png; charset=binary", strings.NewReader("\x00\x01"))
+	if name != "line-bot-upload-1.png" || mime != "image/png" {
+		t.Errorf("Expected header type PNG, but got: %q %q", name, mime)
+	}
+
+	name, mime, _ = imageFileType("line-bot-upload-1.jpg", "", strings.NewReader("\x00\x01"))
+	if name != "line-bot-upload-1.jpg" || mime != "image/jpeg" {
+		t.Errorf("Expected JPEG fallback, but got: %q %q", name, mime)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// folderClaimCollection holds one document per folder being created, so
+// instances racing to create the same folder agree on a single winner.
+const folderClaimCollection = "folder_claims"
+
+const (
+	// folderClaimTimeout is how long a claim without a folder ID blocks
+	// other instances; after that the creator is assumed to have died.
+	folderClaimTimeout = 30 * time.Second
+
+	// folderClaimTTL is how long a created folder's ID is handed out.
+	// Drive search can lag behind creation, but after this the folder
+	// search is trusted again, e.g. when the user deleted the folder.
+	folderClaimTTL = 5 * time.Minute
+
+	folderClaimRetries = 10
+	folderClaimWait    = 500 * time.Millisecond
+)
+
+// folderClaim is stored in folderClaimCollection.
+type folderClaim struct {
+	FolderID  string    `firestore:"folder_id"`
+	ClaimedAt time.Time `firestore:"claimed_at"`
+}
+
+// folderClaimKey is the document ID for the folder with the given name
+// under parentID. Drive IDs are globally unique, so the parent must be a
+// real ID rather than the per-user "root" alias.
+func folderClaimKey(parentID, name string) string {
+	sum := sha256.Sum256([]byte(parentID + "\x00" + name))
+	return hex.EncodeToString(sum[:])
+}
+
+// claimFolderCreation tries to become the instance that creates the
+// folder. It returns the folder's ID if another instance created it
+// recently, or claimed=true if the caller should create it now.
+func claimFolderCreation(ctx context.Context, client *firestore.Client, key string, now time.Time) (folderID string, claimed bool, err error) {
+	ref := client.Collection(folderClaimCollection).Doc(key)
+	err = client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		folderID, claimed = "", false
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			var c folderClaim
+			if err := doc.DataTo(&c); err != nil {
+				return err
+			}
+			age := now.Sub(c.ClaimedAt)
+			if c.FolderID != "" && age < folderClaimTTL {
+				folderID = c.FolderID
+				return nil
+			}
+			if c.FolderID == "" && age < folderClaimTimeout {
+				return nil
+			}
+		}
+		claimed = true
+		return tx.Set(ref, folderClaim{ClaimedAt: now})
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to claim folder creation: %w", err)
+	}
+	return folderID, claimed, nil
+}
+
+// createFolderOnce creates the folder under a Firestore claim so that
+// several instances handling the same user's uploads don't each create a
+// copy. Instances that lose the claim wait for the winner's folder.
+func createFolderOnce(ctx context.Context, srv *drive.Service, name, parentID string) (string, error) {
+	parent := driveParent(parentID)
+	if parent == "root" {
+		root, err := srv.Files.Get("root").Fields("id").Context(ctx).Do()
+		if err != nil {
+			return "", fmt.Errorf("failed to get root folder: %w", err)
+		}
+		parent = root.Id
+	}
+	ref := firestoreClient.Collection(folderClaimCollection).Doc(folderClaimKey(parent, name))
+
+	for i := 0; i < folderClaimRetries; i++ {
+		folderID, claimed, err := claimFolderCreation(ctx, firestoreClient, ref.ID, time.Now())
+		if err != nil {
+			// Creating a possible duplicate beats failing the upload.
+			log.Printf("Creating folder '%s' without a claim: %v", name, err)
+			return createFolder(ctx, srv, name, parentID)
+		}
+		if folderID != "" {
+			return folderID, nil
+		}
+		if claimed {
+			folderID, err := createFolder(ctx, srv, name, parentID)
+			// The claim is settled even if the request was cut short.
+			settleCtx := context.WithoutCancel(ctx)
+			if err != nil {
+				if _, err := ref.Delete(settleCtx); err != nil {
+					log.Printf("Failed to release claim for folder '%s': %v", name, err)
+				}
+				return "", err
+			}
+			if _, err := ref.Set(settleCtx, map[string]interface{}{"folder_id": folderID}, firestore.MergeAll); err != nil {
+				log.Printf("Failed to record created folder '%s': %v", name, err)
+			}
+			return folderID, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(folderClaimWait):
+		}
+		if folderID, err := findFolder(ctx, srv, name, parentID); err == nil && folderID != "" {
+			return folderID, nil
+		}
+	}
+	return "", fmt.Errorf("timed out waiting for folder '%s' to be created", name)
+}
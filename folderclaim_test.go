@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// TestClaimFolderCreation checks against the Firestore emulator that only
+// one caller may create a folder and later callers get its ID.
+func TestClaimFolderCreation(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST not set")
+	}
+
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("Failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	key := folderClaimKey("parent_1", "2024-03")
+	ref := client.Collection(folderClaimCollection).Doc(key)
+	ref.Delete(ctx)
+	now := time.Now()
+
+	if _, claimed, err := claimFolderCreation(ctx, client, key, now); err != nil || !claimed {
+		t.Fatalf("Expected first claim to succeed, but got claimed=%v err=%v", claimed, err)
+	}
+	if id, claimed, _ := claimFolderCreation(ctx, client, key, now.Add(time.Second)); claimed || id != "" {
+		t.Errorf("Expected claim in progress to block, but got claimed=%v id=%q", claimed, id)
+	}
+
+	ref.Set(ctx, map[string]interface{}{"folder_id": "folder_1"}, firestore.MergeAll)
+	if id, claimed, _ := claimFolderCreation(ctx, client, key, now.Add(time.Second)); claimed || id != "folder_1" {
+		t.Errorf("Expected created folder ID, but got claimed=%v id=%q", claimed, id)
+	}
+	if _, claimed, _ := claimFolderCreation(ctx, client, key, now.Add(folderClaimTTL+time.Second)); !claimed {
+		t.Errorf("Expected an old claim to be taken over")
+	}
+}
+
+// TestFolderClaimKey tests that keys differ per parent and name.
+func TestFolderClaimKey(t *testing.T) {
+	if folderClaimKey("a", "b") != folderClaimKey("a", "b") {
+		t.Errorf("Expected keys to be deterministic")
+	}
+	if folderClaimKey("a", "bc") == folderClaimKey("ab", "c") {
+		t.Errorf("Expected different parent and name pairs to have different keys")
+	}
+}
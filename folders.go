@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"google.golang.org/api/drive/v3"
+)
+
+// folderPickerPageSize is the number of folders offered per page. LINE
+// allows 13 quick reply items; the rest are used for "default" and "more".
+const folderPickerPageSize = 11
+
+// handleChooseFolderCommand offers the folders under the main folder as
+// quick replies. Selecting one sends a set_folder postback.
+func handleChooseFolderCommand(ctx context.Context, bot botClient, replyToken, userID string, page int) {
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	folders, err := listUploadFolders(ctx, srv, userRootFolderName(ctx, userID))
+	if err != nil {
+		log.Printf("Failed to list folders: %v", err)
+		replyForError(ctx, bot, replyToken, userID, err)
+		return
+	}
+
+	items := folderPickerItems(userLanguage(ctx, userID), folders, page)
+	if _, err := bot.ReplyMessage(
+		&messaging_api.ReplyMessageRequest{
+			ReplyToken: replyToken,
+			Messages: []messaging_api.MessageInterface{
+				&messaging_api.TextMessage{
+					Text: tr(ctx, userID, "folders.prompt"),
+					QuickReply: &messaging_api.QuickReply{
+						Items: items,
+					},
+				},
+			},
+		},
+	); err != nil {
+		log.Print(err)
+	}
+}
+
+// folderPickerItems builds the quick reply items for one page of folders.
+func folderPickerItems(lang string, folders []*drive.File, page int) []messaging_api.QuickReplyItem {
+	items := []messaging_api.QuickReplyItem{
+		{
+			Action: &messaging_api.PostbackAction{
+				Label:       translate(lang, "folders.default"),
+				Data:        "action=set_folder",
+				DisplayText: translate(lang, "folders.default"),
+			},
+		},
+	}
+
+	start := page * folderPickerPageSize
+	if start > len(folders) {
+		start = len(folders)
+	}
+	end := start + folderPickerPageSize
+	if end > len(folders) {
+		end = len(folders)
+	}
+	for _, f := range folders[start:end] {
+		items = append(items, messaging_api.QuickReplyItem{
+			Action: &messaging_api.PostbackAction{
+				Label:       quickReplyLabel(f.Name),
+				Data:        "action=set_folder&folder_id=" + url.QueryEscape(f.Id),
+				DisplayText: f.Name,
+			},
+		})
+	}
+
+	if end < len(folders) {
+		items = append(items, messaging_api.QuickReplyItem{
+			Action: &messaging_api.PostbackAction{
+				Label: translate(lang, "folders.more"),
+				Data:  "action=choose_folder&page=" + strconv.Itoa(page+1),
+			},
+		})
+	}
+	return items
+}
+
+// quickReplyLabel trims a label to LINE's 20 character limit.
+func quickReplyLabel(s string) string {
+	runes := []rune(s)
+	if len(runes) <= 20 {
+		return s
+	}
+	return string(runes[:19]) + "…"
+}
+
+// listUploadFolders returns every folder directly under the rootName
+// folder, newest name first, so month folders are listed in reverse order.
+func listUploadFolders(ctx context.Context, srv *drive.Service, rootName string) ([]*drive.File, error) {
+	mainFolderID, err := findOrCreateFolder(ctx, srv, rootName, "root")
+	if err != nil {
+		return nil, fmt.Errorf("could not find or create the main upload folder: %w", err)
+	}
+
+	query := fmt.Sprintf("mimeType='application/vnd.google-apps.folder' and trashed=false and '%s' in parents", mainFolderID)
+	var folders []*drive.File
+	pageToken := ""
+	for {
+		call := scopeFileList(srv.Files.List()).Q(query).PageSize(1000).OrderBy("name desc").Fields("nextPageToken, files(id, name)")
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		r, err := call.Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list folders: %w", err)
+		}
+		folders = append(folders, r.Files...)
+		if r.NextPageToken == "" {
+			return folders, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// handleSetFolderPostback stores the folder picked from /choose_folder as
+// the user's upload destination. An empty folder ID restores the default.
+func handleSetFolderPostback(ctx context.Context, bot botClient, replyToken, userID, folderID string) {
+	if folderID == "" {
+		if err := updateUserPrefs(ctx, userID, map[string]interface{}{
+			"destination_folder_id":   "",
+			"destination_folder_name": "",
+		}); err != nil {
+			log.Printf("Failed to reset destination for user %s: %v", userID, err)
+			replyText(bot, replyToken, tr(ctx, userID, "folders.save_failed"))
+			return
+		}
+		replyText(bot, replyToken, tr(ctx, userID, "folders.reset"))
+		return
+	}
+
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	folder, err := srv.Files.Get(folderID).SupportsAllDrives(supportsAllDrives()).Fields("id, name, trashed").Context(ctx).Do()
+	if err != nil || folder.Trashed {
+		log.Printf("Failed to get folder %s for user %s: %v", folderID, userID, err)
+		if replyForError(ctx, bot, replyToken, userID, err) {
+			return
+		}
+		replyText(bot, replyToken, tr(ctx, userID, "folders.gone"))
+		return
+	}
+
+	if err := updateUserPrefs(ctx, userID, map[string]interface{}{
+		"destination_folder_id":   folder.Id,
+		"destination_folder_name": folder.Name,
+	}); err != nil {
+		log.Printf("Failed to save destination for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "folders.save_failed"))
+		return
+	}
+	replyText(bot, replyToken, trf(ctx, userID, "folders.set", folder.Name))
+}
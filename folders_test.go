@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"google.golang.org/api/drive/v3"
+)
+
+// TestFolderPickerItemsPaging tests that folders are split into pages with
+// a "more" item when needed.
+func TestFolderPickerItemsPaging(t *testing.T) {
+	var folders []*drive.File
+	for i := 0; i < 15; i++ {
+		folders = append(folders, &drive.File{Id: fmt.Sprintf("id%d", i), Name: fmt.Sprintf("folder%d", i)})
+	}
+
+	items := folderPickerItems(langZh, folders, 0)
+	// default + 11 folders + more
+	if len(items) != 13 {
+		t.Fatalf("Expected 13 items on the first page, but got: %d", len(items))
+	}
+	more := items[len(items)-1].Action.(*messaging_api.PostbackAction)
+	if more.Data != "action=choose_folder&page=1" {
+		t.Errorf("Expected next page postback, but got: '%s'", more.Data)
+	}
+
+	items = folderPickerItems(langZh, folders, 1)
+	// default + 4 remaining folders, no more
+	if len(items) != 5 {
+		t.Errorf("Expected 5 items on the second page, but got: %d", len(items))
+	}
+}
+
+// TestQuickReplyLabel tests that labels fit LINE's length limit.
+func TestQuickReplyLabel(t *testing.T) {
+	if got := quickReplyLabel("2024-03"); got != "2024-03" {
+		t.Errorf("Expected '2024-03', but got: '%s'", got)
+	}
+	got := quickReplyLabel("a very long folder name that overflows")
+	if len([]rune(got)) != 20 {
+		t.Errorf("Expected 20 characters, but got: %d", len([]rune(got)))
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// readinessTimeout bounds the dependency checks behind /readyz so a slow
+// backend reports not ready instead of hanging the probe.
+const readinessTimeout = 3 * time.Second
+
+// healthCollection holds no documents; /readyz reads from it only to
+// confirm Firestore answers.
+const healthCollection = "health"
+
+// healthzHandler is the liveness probe: the process is up and serving.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, "ok")
+}
+
+// readyzHandler is the readiness probe. It answers 503 while check fails,
+// so the load balancer stops routing traffic to this instance.
+func readyzHandler(check func(context.Context) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+		if err := check(ctx); err != nil {
+			log.Printf("Readiness check failed: %v", err)
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}
+}
+
+// checkFirestore performs a trivial read. A missing document still proves
+// Firestore is reachable.
+func checkFirestore(ctx context.Context) error {
+	_, err := firestoreClient.Collection(healthCollection).Doc("readyz").Get(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("firestore read failed: %w", err)
+	}
+	return nil
+}
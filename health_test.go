@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestHealthz tests that the liveness probe always succeeds.
+func TestHealthz(t *testing.T) {
+	rec := httptest.NewRecorder()
+	healthzHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, but got: %d", rec.Code)
+	}
+}
+
+// TestReadyz tests that the readiness probe reports a failing dependency
+// with 503.
+func TestReadyz(t *testing.T) {
+	failing := readyzHandler(func(ctx context.Context) error { return errors.New("firestore unavailable") })
+	rec := httptest.NewRecorder()
+	failing(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when Firestore fails, but got: %d", rec.Code)
+	}
+
+	healthy := readyzHandler(func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("Expected the check to run with a deadline")
+		}
+		return nil
+	})
+	rec = httptest.NewRecorder()
+	healthy(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 when Firestore answers, but got: %d", rec.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+)
+
+// sendContextualHelp answers a one-to-one message that isn't a command
+// with a hint that fits the user's connection state.
+func sendContextualHelp(ctx context.Context, bot botClient, replyToken, userID string) {
+	connected, err := isUserConnected(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to check connection for user %s: %v", userID, err)
+	}
+	if !connected {
+		sendConnectionPrompt(ctx, bot, replyToken, userID)
+		return
+	}
+
+	if err := sendReply(bot, replyToken, []messaging_api.MessageInterface{
+		&messaging_api.TextMessage{
+			Text: tr(ctx, userID, "help.hint"),
+			QuickReply: &messaging_api.QuickReply{
+				Items: []messaging_api.QuickReplyItem{
+					{
+						Action: &messaging_api.MessageAction{
+							Label: tr(ctx, userID, "label.recent_files"),
+							Text:  commandText("recent_files"),
+						},
+					},
+					{
+						Action: &messaging_api.MessageAction{
+							Label: tr(ctx, userID, "label.history"),
+							Text:  commandText("history"),
+						},
+					},
+				},
+			},
+		},
+	}); err != nil {
+		log.Print(err)
+	}
+}
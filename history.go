@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"google.golang.org/api/drive/v3"
+)
+
+// historyMaxMonths caps how many month folders /history shows.
+const historyMaxMonths = 12
+
+// monthSummary is the number of uploads stored in one month folder.
+type monthSummary struct {
+	Month    string
+	FolderID string
+	Count    int
+}
+
+func handleHistoryCommand(ctx context.Context, bot botClient, replyToken, userID string) {
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	months, err := getUploadHistory(ctx, srv, userRootFolderName(ctx, userID), historyMaxMonths)
+	if err != nil {
+		log.Printf("Failed to get upload history: %v", err)
+		replyForError(ctx, bot, replyToken, userID, err)
+		return
+	}
+
+	if len(months) == 0 {
+		replyText(bot, replyToken, tr(ctx, userID, "files.none"))
+		return
+	}
+
+	if err := sendReply(bot, replyToken, []messaging_api.MessageInterface{
+		&messaging_api.FlexMessage{
+			AltText:  tr(ctx, userID, "history.alt"),
+			Contents: buildHistoryBubble(userLanguage(ctx, userID), months),
+		},
+	}); err != nil {
+		log.Print(err)
+	}
+}
+
+// getUploadHistory lists the newest date folders under the rootName
+// folder and counts the files in each one. Only folders named with
+// FOLDER_DATE_LAYOUT, as uploads name them, are listed, so routed and
+// chosen folders are skipped. With date folders disabled, the root folder
+// is the only entry.
+func getUploadHistory(ctx context.Context, srv *drive.Service, rootName string, maxMonths int64) ([]monthSummary, error) {
+	mainFolderID, err := findOrCreateFolder(ctx, srv, rootName, "root")
+	if err != nil {
+		return nil, fmt.Errorf("could not find or create the main upload folder: %w", err)
+	}
+
+	if folderDateLayout == "" {
+		count, err := countFilesInFolder(ctx, srv, mainFolderID)
+		if err != nil || count == 0 {
+			return nil, err
+		}
+		return []monthSummary{{Month: rootName, FolderID: mainFolderID, Count: count}}, nil
+	}
+
+	query := fmt.Sprintf("mimeType='application/vnd.google-apps.folder' and trashed=false and '%s' in parents%s", mainFolderID, folderOwnerClause())
+	var months []monthSummary
+	pageToken := ""
+	for {
+		call := scopeFileList(srv.Files.List()).
+			Q(query).
+			PageSize(100).
+			OrderBy("name desc").
+			Fields("nextPageToken, files(id, name)")
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		r, err := call.Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list month folders: %w", err)
+		}
+		for _, folder := range r.Files {
+			if _, err := time.Parse(folderDateLayout, folder.Name); err != nil {
+				continue
+			}
+			count, err := countFilesInFolder(ctx, srv, folder.Id)
+			if err != nil {
+				return nil, err
+			}
+			months = append(months, monthSummary{Month: folder.Name, FolderID: folder.Id, Count: count})
+			if int64(len(months)) == maxMonths {
+				return months, nil
+			}
+		}
+		if r.NextPageToken == "" {
+			return months, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// countFilesInFolder counts the non-trashed files directly inside a folder,
+// following all result pages.
+func countFilesInFolder(ctx context.Context, srv *drive.Service, folderID string) (int, error) {
+	query := fmt.Sprintf("'%s' in parents and trashed=false", folderID)
+	count := 0
+	pageToken := ""
+	for {
+		call := scopeFileList(srv.Files.List()).Q(query).PageSize(1000).Fields("nextPageToken, files(id)")
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		r, err := call.Context(ctx).Do()
+		if err != nil {
+			return 0, fmt.Errorf("failed to count files in folder '%s': %w", folderID, err)
+		}
+		count += len(r.Files)
+		if r.NextPageToken == "" {
+			return count, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// buildHistoryBubble renders the month summaries as a timeline. Tapping a
+// row opens that month's folder in Drive.
+func buildHistoryBubble(lang string, months []monthSummary) *messaging_api.FlexBubble {
+	contents := []messaging_api.FlexComponentInterface{
+		&messaging_api.FlexText{
+			Text:   translate(lang, "history.title"),
+			Weight: "bold",
+			Size:   "xl",
+		},
+	}
+	for _, m := range months {
+		contents = append(contents,
+			&messaging_api.FlexSeparator{Margin: "md"},
+			&messaging_api.FlexBox{
+				Layout: "horizontal",
+				Margin: "md",
+				Action: &messaging_api.UriAction{
+					Label: m.Month,
+					Uri:   "https://drive.google.com/drive/folders/" + m.FolderID,
+				},
+				Contents: []messaging_api.FlexComponentInterface{
+					&messaging_api.FlexText{
+						Text:  m.Month,
+						Size:  "md",
+						Color: cardAccentColor,
+						Flex:  3,
+					},
+					&messaging_api.FlexText{
+						Text:  fmt.Sprintf(translate(lang, "history.count"), m.Count),
+						Size:  "md",
+						Align: "end",
+						Flex:  2,
+					},
+				},
+			},
+		)
+	}
+
+	return &messaging_api.FlexBubble{
+		Body: &messaging_api.FlexBox{
+			Layout:   "vertical",
+			Contents: contents,
+		},
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestCountFilesInFolder tests that countFilesInFolder follows page tokens.
+func TestCountFilesInFolder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("pageToken") == "" {
+			json.NewEncoder(w).Encode(&drive.FileList{
+				Files:         []*drive.File{{Id: "a"}, {Id: "b"}},
+				NextPageToken: "page2",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "c"}}})
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	count, err := countFilesInFolder(context.Background(), srv, "folder_id")
+	if err != nil {
+		t.Errorf("Expected no error, but got: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 files, but got: %d", count)
+	}
+}
+
+// TestBuildHistoryBubble tests that each month links to its Drive folder.
+func TestBuildHistoryBubble(t *testing.T) {
+	bubble := buildHistoryBubble(langEn, []monthSummary{
+		{Month: "2024-03", FolderID: "f1", Count: 4},
+	})
+	b, err := json.Marshal(bubble)
+	if err != nil {
+		t.Fatalf("Failed to marshal bubble: %v", err)
+	}
+	if !strings.Contains(string(b), "https://drive.google.com/drive/folders/f1") {
+		t.Errorf("Expected folder link in bubble, got: %s", b)
+	}
+	if !strings.Contains(string(b), "4 files") {
+		t.Errorf("Expected file count in bubble, got: %s", b)
+	}
+}
+
+// TestGetUploadHistory tests that only folders named with
+// FOLDER_DATE_LAYOUT are listed, and that the root folder stands in for
+// them when date folders are disabled.
+func TestGetUploadHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		q := r.URL.Query().Get("q")
+		switch {
+		case strings.Contains(q, "name='Receipts'"):
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "root_id"}}})
+		case strings.Contains(q, "mimeType='application/vnd.google-apps.folder'"):
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{
+				{Id: "photos_id", Name: "Photos"},
+				{Id: "march_id", Name: "2024-03"},
+				{Id: "feb_id", Name: "2024-02"},
+			}})
+		default:
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "a"}, {Id: "b"}}})
+		}
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	months, err := getUploadHistory(context.Background(), srv, "Receipts", historyMaxMonths)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if len(months) != 2 || months[0].Month != "2024-03" || months[1].Month != "2024-02" {
+		t.Errorf("Expected only the date folders, but got: %+v", months)
+	}
+
+	folderDateLayout = ""
+	defer func() { folderDateLayout = defaultFolderDateLayout }()
+	months, err = getUploadHistory(context.Background(), srv, "Receipts", historyMaxMonths)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if len(months) != 1 || months[0].FolderID != "root_id" || months[0].Count != 2 {
+		t.Errorf("Expected the root folder as the only entry, but got: %+v", months)
+	}
+}
@@ -0,0 +1,600 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Languages users can pick with /lang.
+const (
+	langZh = "zh"
+	langEn = "en"
+
+	// defaultLanguage matches the Chinese quick-reply labels and rich menu
+	// that users see before choosing.
+	defaultLanguage = langZh
+)
+
+// messageCatalogs holds every localized reply, keyed by language and then
+// by message key. Placeholders are filled in with fmt by the caller, and
+// commands are written with the default "/" prefix.
+var messageCatalogs = map[string]map[string]string{
+	langZh: {
+		"connect.prompt":          "請先連結您的 Google Drive 帳號。",
+		"connect.button":          "連結 Google Drive",
+		"connect.authorize":       "請點擊以下連結，授權機器人上傳檔案到您的 Google Drive：%s",
+		"reconnect.message":       "您的 Google Drive 授權似乎已失效。\n請執行 {command} 指令來重新連線。",
+		"reconnect.button":        "重新連線",
+		"reconnect.not_needed":    "您的連線正常，無需重新連線",
+		"reconnect.check_failed":  "檢查連線時發生錯誤，請稍後再試，或使用「%s」強制重新連線。",
+		"reconnect.failed":        "重新連線時發生錯誤，請手動執行「%s」。",
+		"reconnect.authorize":     "請點擊以下連結，重新授權機器人上傳檔案到您的 Google Drive：%s",
+		"reconnect.race":          "連線更新中，請重新傳送",
+		"disconnect.not_linked":   "您的帳號尚未連結 Google Drive。",
+		"disconnect.failed":       "中斷連線時發生錯誤，請稍後再試。",
+		"disconnect.done":         "已中斷與 Google Drive 的連線。",
+		"upload.success":          "檔案已上傳到 Google Drive：%s",
+		"upload.empty":            "檔案是空的，請重新傳送",
+		"upload.progress":         "已上傳 %d%%…",
+		"sticker.echo":            "貼圖訊息: sticker id is %s, stickerResourceType is %s",
+		"upload.too_large":        "檔案太大 (上限 %s)，無法上傳。",
+		"batch.uploaded":          "已上傳 %d 個檔案到 Google Drive",
+		"batch.failed":            "%d 個檔案未完成：",
+		"batch.alt":               "已上傳 %d 個檔案",
+		"batch.item":              "第 %d 個檔案",
+		"batch.pending":           "%d 個檔案仍在上傳中，完成後會另行通知",
+		"undo.done":               "已將「%s」移至垃圾桶。",
+		"undo.nothing":            "沒有可以復原的上傳。",
+		"undo.failed":             "復原時發生錯誤，請稍後再試。",
+		"cancel.done":             "已取消上傳。",
+		"cancel.nothing":          "目前沒有進行中的上傳。",
+		"whoami.connected":        "已連結的 Google 帳號：%s (%s)",
+		"whoami.not_connected":    "尚未連結 Google Drive，請輸入 %s 進行連結。",
+		"whoami.failed":           "查詢 Google 帳號時發生錯誤，請稍後再試。",
+		"error.quota":             "您的 Google Drive 空間已滿，請清出空間後再試一次。",
+		"error.rate_limited":      "Google Drive 目前忙碌中，請稍後再試。",
+		"error.too_large":         "檔案太大，無法上傳到 Google Drive。",
+		"error.scope":             "權限不足，請使用 %s 重新授權完整權限",
+		"error.timeout":           "服務回應較慢，請稍後再試一次。",
+		"label.recent_files":      "查詢最近檔案",
+		"label.disconnect":        "中斷連線",
+		"lang.usage":              "用法：%s en|zh",
+		"lang.set":                "已切換為中文。",
+		"lang.failed":             "儲存語言設定時發生錯誤，請稍後再試。",
+		"error.generic":           "發生錯誤，請稍後再試。",
+		"error.save_setting":      "儲存設定時發生錯誤，請稍後再試。",
+		"error.read_settings":     "讀取設定時發生錯誤，請稍後再試。",
+		"error.lookup_folders":    "查詢資料夾時發生錯誤，請稍後再試。",
+		"common.cancelled":        "已取消",
+		"common.cancel":           "取消",
+		"common.wait":             "請稍候…",
+		"common.throttled":        "請稍候再試。",
+		"common.direct_only":      "為了保護您的帳號，請在與機器人的一對一聊天中使用此指令。",
+		"common.unknown_sender":   "無法識別您的帳號，請先將機器人加為好友後再試一次。",
+		"common.not_allowed":      "此機器人僅供授權使用者使用",
+		"files.none":              "您還沒有上傳任何檔案。",
+		"accountlink.done":        "帳號連結成功！",
+		"upload.paused":           "已暫停上傳，傳送 /resume 即可恢復。",
+		"upload.external_blocked": "無法上傳來自此外部來源的檔案。",
+		"upload.started":          "上傳中，完成後會通知您…",
+		"upload.processing":       "處理中…",
+		"upload.duplicate":        "此檔案已於 %s 上傳\n%s",
+		"between.usage":           "用法：/between <YYYY-MM-DD> <YYYY-MM-DD>，例如 /between 2024-03-01 2024-03-31",
+		"between.none":            "%s 到 %s 之間沒有上傳的檔案。",
+		"between.alt":             "這段期間上傳的檔案",
+		"between.more":            "僅顯示最新的 %d 個檔案，請縮小日期範圍查看其餘檔案。",
+		"dedupe.usage":            "用法：/dedupe on|off",
+		"dedupe.on":               "已開啟重複檔案偵測，已上傳過的檔案不會再次儲存。",
+		"dedupe.off":              "已關閉重複檔案偵測。",
+		"delete.gone":             "此檔案已被刪除或移至垃圾桶。",
+		"delete.not_upload":       "只能刪除由 LINE Bot 上傳的檔案。",
+		"delete.failed":           "刪除檔案時發生錯誤，請稍後再試。",
+		"delete.done":             "已將「%s」移至垃圾桶，可使用 /trash 還原。",
+		"delete.folder":           "無法刪除資料夾。",
+		"delete.confirm_prompt":   "確定要將「%s」移至垃圾桶？",
+		"delete.confirm":          "確定刪除",
+		"description.usage":       "用法：/description <文字>，例如 /description 從 LINE 上傳於 {{.Date}}\n使用 /description clear 移除。",
+		"description.prompt":      "請傳送要用於上傳檔案的說明，例如：從 LINE 上傳於 {{.Date}}\n傳送「取消」即可取消。",
+		"description.set":         "之後上傳的檔案會使用此說明：%s",
+		"description.cleared":     "已清除上傳說明。",
+		"description.invalid":     "說明範本無效，只支援 {{.Date}} 這個欄位。",
+		"description.failed":      "儲存說明時發生錯誤，請稍後再試。",
+		"folders.prompt":          "請選擇新檔案要儲存的位置：",
+		"folders.save_failed":     "儲存選擇時發生錯誤，請稍後再試。",
+		"folders.reset":           "之後的檔案會再次儲存到月份資料夾。",
+		"folders.gone":            "此資料夾已不存在，請使用 /choose_folder 重新選擇。",
+		"folders.set":             "之後的檔案會儲存到：%s",
+		"history.alt":             "您的上傳紀錄",
+		"import.no_access":        "無法存取此檔案。本機器人只能管理透過它上傳的檔案，請直接將檔案傳送給我重新上傳。",
+		"import.not_owner":        "只能匯入您自己擁有的檔案。",
+		"import.failed":           "匯入檔案時發生錯誤，請稍後再試。",
+		"import.done":             "已將「%s」加入 %s: %s",
+		"manifest.usage":          "用法：/manifest [YYYY-MM]，例如 /manifest 2024-03",
+		"manifest.none":           "%s 沒有上傳的檔案。",
+		"manifest.build_failed":   "建立清單時發生錯誤，請稍後再試。",
+		"manifest.upload_failed":  "上傳清單時發生錯誤，請稍後再試。",
+		"manifest.done":           "%[2]s 的 %[1]d 個檔案清單：%[3]s",
+		"manual.usage":            "用法：/manual on|off",
+		"manual.on":               "已開啟手動模式，上傳每個檔案前都會先詢問您。",
+		"manual.off":              "已關閉手動模式，檔案會自動上傳。",
+		"manual.save_failed":      "發生錯誤，請重新傳送檔案。",
+		"manual.ask":              "上傳此檔案？",
+		"manual.declined":         "好的，不上傳此檔案",
+		"manual.unavailable":      "無法取得檔案內容，請重新傳送檔案。",
+		"manual.confirm":          "上傳",
+		"manual.decline":          "不用了",
+		"upload.onboarding":       "🎉 這是您的第一個檔案！\n• 輸入 /recent_files 查看最近上傳的檔案\n• 檔案會依月份整理在「LINE Bot Uploads」資料夾，也可用 /choose_folder 指定資料夾\n• 輸入 /help 查看所有指令",
+		"oauth.html_lang":         "zh-Hant",
+		"oauth.back":              "回到 LINE",
+		"oauth.failed_title":      "授權失敗",
+		"oauth.denied_title":      "已取消授權",
+		"oauth.denied":            "您沒有授權存取 Google Drive。如需上傳檔案，請回到 LINE 重新輸入 /connect_drive。",
+		"oauth.error_code":        "錯誤代碼：%s",
+		"oauth.missing_code":      "缺少授權碼。請回到 LINE 重新輸入 /connect_drive 取得新的連結。",
+		"oauth.invalid_state":     "授權連結無效，請回到 LINE 重新輸入 /connect_drive 取得新的連結。",
+		"oauth.server_error":      "伺服器發生錯誤，請稍後再試。",
+		"oauth.expired":           "連結已過期，請重新輸入 /connect_drive 取得新的連結。",
+		"oauth.exchange_failed":   "無法完成授權，請回到 LINE 重新輸入 /connect_drive。",
+		"oauth.scopes":            "權限不足：請重新授權並勾選所有要求的 Google Drive 權限。",
+		"oauth.save_failed":       "無法儲存授權資料，請稍後再試。",
+		"oauth.success_title":     "授權成功！",
+		"oauth.success":           "您現在可以回到 LINE 傳送檔案了。",
+		"notes.usage":             "用法：/note <文字> 新增筆記，/note show 查看筆記。",
+		"notes.read_failed":       "讀取筆記時發生錯誤，請稍後再試。",
+		"notes.empty":             "您還沒有任何筆記。",
+		"notes.save_failed":       "儲存筆記時發生錯誤，請稍後再試。",
+		"notes.saved":             "筆記已儲存到 %s",
+		"pause.usage":             "用法：/pause <時間>，例如 /pause 30m、/pause 2h 或 /pause 1d",
+		"pause.invalid":           "時間格式錯誤，例如：30m、2h、1d (最長 30d)。",
+		"pause.failed":            "暫停上傳時發生錯誤，請稍後再試。",
+		"pause.done":              "已暫停上傳至 %s，傳送 /resume 可提前恢復。",
+		"resume.failed":           "恢復上傳時發生錯誤，請稍後再試。",
+		"resume.done":             "已恢復上傳。",
+		"quota.failed":            "查詢儲存空間時發生錯誤，請稍後再試。",
+		"quota.unlimited":         "儲存空間無上限",
+		"quota.unlimited_usage":   "儲存空間無上限\n(已使用 %s，Drive 中 %s)",
+		"quota.usage":             "已使用 %s / %s\n(Drive 中 %s，剩餘 %s)",
+		"recent.no_more":          "沒有更多檔案了。",
+		"recent.alt":              "您最近的檔案",
+		"rename.usage":            "請輸入新的檔名，例如：/rename 收據.jpg",
+		"rename.failed":           "重新命名檔案時發生錯誤，請稍後再試。",
+		"rename.done":             "已重新命名為「%s」\n%s",
+		"menu.usage":              "用法：/menu connect|main",
+		"menu.failed":             "更新選單時發生錯誤，請稍後再試。",
+		"menu.not_configured":     "此機器人尚未設定圖文選單。",
+		"menu.link_failed":        "無法更新選單，請稍後再試。",
+		"menu.restored":           "已恢復您的選單。",
+		"setfolder.usage":         "用法：/setfolder <資料夾名稱> 或 /setfolder reset",
+		"setfolder.too_long":      "資料夾名稱不可超過 %d 個字元。",
+		"setfolder.done":          "之後的檔案會上傳到「%s」。",
+		"currentfolder.name":      "目前的上傳資料夾：%s",
+		"currentfolder.missing":   "(資料夾尚未建立，下次上傳時會自動建立)",
+		"route.usage":             "用法：/route <images|videos|audio|files> <資料夾名稱>，或 /route <類型> clear",
+		"route.set":               "之後的 %s 會儲存到：%s/%s",
+		"route.cleared":           "之後的 %s 會儲存到預設資料夾。",
+		"route.save_failed":       "儲存分流設定時發生錯誤，請稍後再試。",
+		"route.load_failed":       "讀取分流設定時發生錯誤，請稍後再試。",
+		"route.none":              "尚未設定分流，所有檔案都會上傳到預設資料夾。",
+		"route.list":              "目前的分流設定：",
+		"sandbox.usage":           "用法：/sandbox on|off|clear",
+		"sandbox.on":              "🧪 沙盒模式已開啟：之後的檔案都會上傳到「%s」。\n使用 /sandbox clear 清空，/sandbox off 關閉。",
+		"sandbox.off":             "沙盒模式已關閉，檔案會上傳到原本的資料夾。",
+		"sandbox.clear_failed":    "清空沙盒時發生錯誤，請稍後再試。",
+		"sandbox.cleared":         "已將沙盒中的 %d 個檔案移至垃圾桶。",
+		"sandbox.tip":             "🧪 沙盒模式中：檔案已存到「%s」，使用 /sandbox off 關閉。",
+		"search.usage":            "用法：/search <關鍵字>，例如 /search 發票",
+		"search.none":             "找不到符合「%s」的檔案。",
+		"search.alt":              "符合「%s」的檔案",
+		"selftest.results":        "自我檢測結果：",
+		"selftest.resolve_folder": "找到主資料夾",
+		"selftest.upload":         "上傳測試檔案",
+		"selftest.read":           "讀取測試檔案",
+		"selftest.delete":         "刪除測試檔案",
+		"stats.failed":            "統計檔案時發生錯誤，請稍後再試。",
+		"stats.alt":               "已上傳 %d 個檔案，共 %s",
+		"trash.failed":            "列出垃圾桶時發生錯誤，請稍後再試。",
+		"trash.empty":             "垃圾桶中沒有上傳的檔案。",
+		"trash.alt":               "垃圾桶中的檔案",
+		"trash.next_page":         "下一頁",
+		"trash.restored":          "已還原「%s」。",
+		"trash.confirm_purge":     "確定要永久刪除「%s」？此動作無法復原。",
+		"trash.confirm":           "確定刪除",
+		"trash.purged":            "已永久刪除「%s」。",
+		"trash.gone":              "此檔案已不在垃圾桶中。",
+		"trash.not_upload":        "只能處理由 LINE Bot 上傳的檔案。",
+		"usage.alt":               "每月使用的儲存空間",
+		"where.header":            "新檔案將上傳至：",
+		"where.folder_gone":       "(您選擇的資料夾已不存在，改用預設資料夾)",
+		"where.routes":            "依類型分流：",
+		"help.hint":               "傳送照片、影片或檔案給我，就會自動存到您的 Google Drive。",
+		"label.history":           "上傳紀錄",
+		"members.left":            "有成員離開了群組。",
+		"card.open":               "在 Drive 中開啟",
+		"card.delete":             "刪除",
+		"card.delete_display":     "刪除「%s」",
+		"card.set_folder":         "設為預設資料夾",
+		"card.private":            "🔒 私人",
+		"card.anyone":             "🌐 知道連結的任何人",
+		"card.shared":             "👥 已共用",
+		"card.created":            "建立時間：%s",
+		"card.modified":           "修改時間：%s",
+		"card.size":               "大小：%d 位元組",
+		"card.type":               "類型：%s",
+		"card.owner":              "擁有者：%s",
+		"history.title":           "上傳紀錄",
+		"history.count":           "%d 個檔案",
+		"stats.title":             "上傳統計",
+		"stats.files":             "檔案數",
+		"stats.total_size":        "總大小",
+		"stats.image":             "🖼 圖片",
+		"stats.video":             "🎬 影片",
+		"stats.audio":             "🎵 音訊",
+		"stats.other":             "📄 其他",
+		"usage.title":             "空間用量",
+		"trash.trashed":           "刪除時間：%s",
+		"trash.restore":           "還原",
+		"trash.restore_display":   "還原「%s」",
+		"trash.purge":             "永久刪除",
+		"trash.purge_display":     "永久刪除「%s」",
+		"folders.default":         "預設 (依月份)",
+		"folders.more":            "更多…",
+		"recent.show_more":        "顯示更多",
+		"help.commands": `可用指令：
+/connect_drive - 連結 Google Drive
+/recent_files - 最近上傳的檔案
+/history - 每月上傳紀錄
+/search <關鍵字> - 依檔名搜尋
+/between <開始日期> <結束日期> - 依日期查詢檔案
+/where - 查看上傳位置
+/choose_folder - 選擇上傳資料夾
+/setfolder <名稱> - 設定上傳的主資料夾
+/currentfolder - 查看目前的主資料夾
+/route <類型> <資料夾> - 依類型分流
+/pause <時間> /resume - 暫停或恢復上傳
+/description <文字> - 設定檔案說明
+/note <文字> - 新增筆記
+/rename <新檔名> - 重新命名最近上傳的檔案
+/undo - 將最近上傳的檔案移至垃圾桶
+/cancel - 取消進行中的上傳
+/lang en|zh - 切換回覆語言
+/trash - 管理垃圾桶中的檔案
+/sandbox on|off|clear - 沙盒模式
+/usage - 每月空間用量
+/stats - 上傳檔案統計
+/quota - Google Drive 剩餘空間
+/manifest [YYYY-MM] - 匯出當月檔案清單 (CSV)
+/whoami - 查看已連結的 Google 帳號
+/reconnect - 重新連線
+/disconnect_drive - 中斷連線`,
+	},
+	langEn: {
+		"connect.prompt":          "Please connect your Google Drive account first.",
+		"connect.button":          "Connect Google Drive",
+		"connect.authorize":       "Please authorize this app to upload files to your Google Drive: %s",
+		"reconnect.message":       "Your Google Drive authorization seems to have expired.\nPlease run {command} to reconnect.",
+		"reconnect.button":        "Reconnect",
+		"reconnect.not_needed":    "Your connection is working. There's no need to reconnect.",
+		"reconnect.check_failed":  "An error occurred while checking your connection. Please try again later, or use '%s'.",
+		"reconnect.failed":        "An error occurred while trying to reconnect. Please try '%s' manually.",
+		"reconnect.authorize":     "Please re-authorize this app to upload files to your Google Drive: %s",
+		"reconnect.race":          "Your connection is being updated. Please send the file again.",
+		"disconnect.not_linked":   "Your account is not connected to Google Drive.",
+		"disconnect.failed":       "An error occurred while disconnecting. Please try again later.",
+		"disconnect.done":         "Successfully disconnected from Google Drive.",
+		"upload.success":          "File uploaded to Google Drive: %s",
+		"upload.empty":            "The file is empty. Please send it again.",
+		"upload.progress":         "Uploaded %d%%…",
+		"sticker.echo":            "Sticker message: sticker id is %s, stickerResourceType is %s",
+		"upload.too_large":        "File too large (max %s).",
+		"batch.uploaded":          "Uploaded %d files to Google Drive",
+		"batch.failed":            "%d files were not uploaded:",
+		"batch.alt":               "Uploaded %d files",
+		"batch.item":              "File %d",
+		"batch.pending":           "%d files are still uploading; you'll be notified when they're done",
+		"undo.done":               "Moved \"%s\" to the trash.",
+		"undo.nothing":            "Nothing to undo.",
+		"undo.failed":             "An error occurred while undoing the upload. Please try again later.",
+		"cancel.done":             "Upload cancelled.",
+		"cancel.nothing":          "Nothing in progress.",
+		"whoami.connected":        "Connected Google account: %s (%s)",
+		"whoami.not_connected":    "Not connected. Send %s to connect Google Drive.",
+		"whoami.failed":           "An error occurred while looking up your Google account. Please try again later.",
+		"error.quota":             "Your Google Drive storage is full. Please free up some space and try again.",
+		"error.rate_limited":      "Google Drive is busy right now. Please try again in a moment.",
+		"error.too_large":         "This file is too large to upload to Google Drive.",
+		"error.scope":             "Some permissions are missing. Please use %s to grant full access again.",
+		"error.timeout":           "The service is slow to respond right now. Please try again.",
+		"label.recent_files":      "Recent files",
+		"label.disconnect":        "Disconnect",
+		"lang.usage":              "Usage: %s en|zh",
+		"lang.set":                "Language set to English.",
+		"lang.failed":             "An error occurred while saving your language. Please try again later.",
+		"error.generic":           "An error occurred. Please try again later.",
+		"error.save_setting":      "An error occurred while saving your setting. Please try again later.",
+		"error.read_settings":     "An error occurred while reading your settings. Please try again later.",
+		"error.lookup_folders":    "An error occurred while looking up your folders. Please try again later.",
+		"common.cancelled":        "Cancelled.",
+		"common.cancel":           "Cancel",
+		"common.wait":             "Please wait…",
+		"common.throttled":        "Please wait a moment before trying again.",
+		"common.direct_only":      "To protect your account, please use this command in a one-on-one chat with the bot.",
+		"common.unknown_sender":   "I couldn't identify your account. Please add the bot as a friend and try again.",
+		"common.not_allowed":      "This bot is only available to authorized users.",
+		"files.none":              "You haven't uploaded any files yet.",
+		"accountlink.done":        "Your account has been linked!",
+		"upload.paused":           "Uploads are paused. Send /resume to start saving files again.",
+		"upload.external_blocked": "Files from this external source can't be uploaded.",
+		"upload.started":          "Uploading. I'll let you know when it's done…",
+		"upload.processing":       "Processing…",
+		"upload.duplicate":        "This file was already uploaded in %s\n%s",
+		"between.usage":           "Usage: /between <YYYY-MM-DD> <YYYY-MM-DD>, e.g. /between 2024-03-01 2024-03-31",
+		"between.none":            "No files were uploaded between %s and %s.",
+		"between.alt":             "Here are the files you uploaded in that period",
+		"between.more":            "Showing the newest %d files. Narrow the date range to see the rest.",
+		"dedupe.usage":            "Usage: /dedupe on|off",
+		"dedupe.on":               "Duplicate detection on. Files you've already uploaded won't be saved again.",
+		"dedupe.off":              "Duplicate detection off.",
+		"delete.gone":             "This file has already been deleted or moved to the trash.",
+		"delete.not_upload":       "Only files uploaded by the LINE Bot can be deleted.",
+		"delete.failed":           "An error occurred while deleting the file. Please try again later.",
+		"delete.done":             "Moved \"%s\" to the trash. Use /trash to restore it.",
+		"delete.folder":           "Folders can't be deleted.",
+		"delete.confirm_prompt":   "Move \"%s\" to the trash?",
+		"delete.confirm":          "Delete",
+		"description.usage":       "Usage: /description <text>, e.g. /description Uploaded from LINE on {{.Date}}\nUse /description clear to remove it.",
+		"description.prompt":      "Send the description to use for uploads, e.g. Uploaded from LINE on {{.Date}}\nSend cancel to cancel.",
+		"description.set":         "Uploads will now use the description: %s",
+		"description.cleared":     "Upload description cleared.",
+		"description.invalid":     "Invalid description template. The only placeholder supported is {{.Date}}.",
+		"description.failed":      "An error occurred while saving the description. Please try again later.",
+		"folders.prompt":          "Choose where new uploads should be saved:",
+		"folders.save_failed":     "An error occurred while saving your choice. Please try again later.",
+		"folders.reset":           "Uploads will be saved to the monthly folders again.",
+		"folders.gone":            "That folder no longer exists. Please choose again with /choose_folder.",
+		"folders.set":             "New uploads will be saved to: %s",
+		"history.alt":             "Here is your upload history",
+		"import.no_access":        "I can't access this file. The bot can only manage files uploaded through it, so please send me the file to upload it again.",
+		"import.not_owner":        "You can only import files you own.",
+		"import.failed":           "An error occurred while importing the file. Please try again later.",
+		"import.done":             "Added \"%s\" to %s: %s",
+		"manifest.usage":          "Usage: /manifest [YYYY-MM], e.g. /manifest 2024-03",
+		"manifest.none":           "No files were uploaded in %s.",
+		"manifest.build_failed":   "An error occurred while creating the manifest. Please try again later.",
+		"manifest.upload_failed":  "An error occurred while uploading the manifest. Please try again later.",
+		"manifest.done":           "Manifest of %d files for %s: %s",
+		"manual.usage":            "Usage: /manual on|off",
+		"manual.on":               "Manual mode on. I'll ask before uploading each file.",
+		"manual.off":              "Manual mode off. Files will be uploaded automatically.",
+		"manual.save_failed":      "An error occurred. Please send the file again.",
+		"manual.ask":              "Upload this file?",
+		"manual.declined":         "OK, this file won't be uploaded.",
+		"manual.unavailable":      "Couldn't get the file's content. Please send the file again.",
+		"manual.confirm":          "Upload",
+		"manual.decline":          "No thanks",
+		"upload.onboarding":       "🎉 That's your first file!\n• Send /recent_files to see your latest uploads\n• Files are sorted by month in the \"LINE Bot Uploads\" folder; use /choose_folder to pick another folder\n• Send /help to see every command",
+		"oauth.html_lang":         "en",
+		"oauth.back":              "Back to LINE",
+		"oauth.failed_title":      "Authorization failed",
+		"oauth.denied_title":      "Authorization cancelled",
+		"oauth.denied":            "You didn't allow access to Google Drive. To upload files, go back to LINE and send /connect_drive again.",
+		"oauth.error_code":        "Error code: %s",
+		"oauth.missing_code":      "The authorization code is missing. Go back to LINE and send /connect_drive for a new link.",
+		"oauth.invalid_state":     "This authorization link is invalid. Go back to LINE and send /connect_drive for a new link.",
+		"oauth.server_error":      "A server error occurred. Please try again later.",
+		"oauth.expired":           "This link has expired. Send /connect_drive for a new link.",
+		"oauth.exchange_failed":   "Authorization couldn't be completed. Go back to LINE and send /connect_drive again.",
+		"oauth.scopes":            "Missing permissions: please authorize again and tick every Google Drive permission requested.",
+		"oauth.save_failed":       "Your authorization couldn't be saved. Please try again later.",
+		"oauth.success_title":     "Authorized!",
+		"oauth.success":           "You can go back to LINE and send files now.",
+		"notes.usage":             "Usage: /note <text> to add a note, /note show to read your notes.",
+		"notes.read_failed":       "An error occurred while reading your notes. Please try again later.",
+		"notes.empty":             "You don't have any notes yet.",
+		"notes.save_failed":       "An error occurred while saving your note. Please try again later.",
+		"notes.saved":             "Note saved to %s",
+		"pause.usage":             "Usage: /pause <duration>, e.g. /pause 30m, /pause 2h or /pause 1d",
+		"pause.invalid":           "Invalid duration. Examples: 30m, 2h, 1d (max 30d).",
+		"pause.failed":            "An error occurred while pausing uploads. Please try again later.",
+		"pause.done":              "Uploads paused until %s. Send /resume to resume early.",
+		"resume.failed":           "An error occurred while resuming uploads. Please try again later.",
+		"resume.done":             "Uploads resumed.",
+		"quota.failed":            "An error occurred while checking your storage. Please try again later.",
+		"quota.unlimited":         "Unlimited storage",
+		"quota.unlimited_usage":   "Unlimited storage\n(%s used, %s in Drive)",
+		"quota.usage":             "Used %s of %s\n(%s in Drive, %s free)",
+		"recent.no_more":          "No more files.",
+		"recent.alt":              "Here are your recent files",
+		"rename.usage":            "Please enter the new file name, e.g. /rename receipt.jpg",
+		"rename.failed":           "An error occurred while renaming the file. Please try again later.",
+		"rename.done":             "Renamed to \"%s\"\n%s",
+		"menu.usage":              "Usage: /menu connect|main",
+		"menu.failed":             "An error occurred while updating your menu. Please try again later.",
+		"menu.not_configured":     "This bot has no rich menus set up.",
+		"menu.link_failed":        "Failed to update your menu. Please try again later.",
+		"menu.restored":           "Your menu has been restored.",
+		"setfolder.usage":         "Usage: /setfolder <folder name> or /setfolder reset",
+		"setfolder.too_long":      "Folder names can be at most %d characters long.",
+		"setfolder.done":          "New files will be uploaded to \"%s\".",
+		"currentfolder.name":      "Current upload folder: %s",
+		"currentfolder.missing":   "(The folder doesn't exist yet. It will be created with your next upload.)",
+		"route.usage":             "Usage: /route <images|videos|audio|files> <folder name>, or /route <type> clear",
+		"route.set":               "New %s will be saved to: %s/%s",
+		"route.cleared":           "New %s will be saved to the default folder.",
+		"route.save_failed":       "An error occurred while saving the route. Please try again later.",
+		"route.load_failed":       "An error occurred while loading your routes. Please try again later.",
+		"route.none":              "No routes set. All uploads go to the default folder.",
+		"route.list":              "Current routes:",
+		"sandbox.usage":           "Usage: /sandbox on|off|clear",
+		"sandbox.on":              "🧪 Sandbox mode on: new files will be uploaded to \"%s\".\nUse /sandbox clear to empty it and /sandbox off to turn it off.",
+		"sandbox.off":             "Sandbox mode off. Files will be uploaded to the usual folders again.",
+		"sandbox.clear_failed":    "An error occurred while clearing the sandbox. Please try again later.",
+		"sandbox.cleared":         "Moved %d files in the sandbox to the trash.",
+		"sandbox.tip":             "🧪 Sandbox mode: the file was saved to \"%s\". Use /sandbox off to turn it off.",
+		"search.usage":            "Usage: /search <keyword>, e.g. /search invoice",
+		"search.none":             "No files matched \"%s\".",
+		"search.alt":              "Here are the files matching %s",
+		"selftest.results":        "Self-test results:",
+		"selftest.resolve_folder": "Resolve main folder",
+		"selftest.upload":         "Upload test file",
+		"selftest.read":           "Read test file",
+		"selftest.delete":         "Delete test file",
+		"stats.failed":            "An error occurred while counting your files. Please try again later.",
+		"stats.alt":               "Uploaded %d files, %s in total",
+		"trash.failed":            "An error occurred while listing the trash. Please try again later.",
+		"trash.empty":             "There are no uploaded files in the trash.",
+		"trash.alt":               "Files in the trash",
+		"trash.next_page":         "Next page",
+		"trash.restored":          "Restored \"%s\".",
+		"trash.confirm_purge":     "Permanently delete \"%s\"? This can't be undone.",
+		"trash.confirm":           "Delete",
+		"trash.purged":            "Permanently deleted \"%s\".",
+		"trash.gone":              "This file is no longer in the trash.",
+		"trash.not_upload":        "Only files uploaded by the LINE Bot can be managed.",
+		"usage.alt":               "Here is your storage usage per month",
+		"where.header":            "New files will be uploaded to:",
+		"where.folder_gone":       "(The folder you chose no longer exists, so the default folder is used)",
+		"where.routes":            "Routed by type:",
+		"help.hint":               "Send me photos, videos or files and they'll be saved to your Google Drive.",
+		"label.history":           "Upload history",
+		"members.left":            "A member left the group.",
+		"card.open":               "Open in Drive",
+		"card.delete":             "Delete",
+		"card.delete_display":     "Delete \"%s\"",
+		"card.set_folder":         "Set as default folder",
+		"card.private":            "🔒 Private",
+		"card.anyone":             "🌐 Anyone with the link",
+		"card.shared":             "👥 Shared",
+		"card.created":            "Created: %s",
+		"card.modified":           "Modified: %s",
+		"card.size":               "Size: %d bytes",
+		"card.type":               "Type: %s",
+		"card.owner":              "Owner: %s",
+		"history.title":           "Upload History",
+		"history.count":           "%d files",
+		"stats.title":             "Upload Stats",
+		"stats.files":             "Files",
+		"stats.total_size":        "Total size",
+		"stats.image":             "🖼 Images",
+		"stats.video":             "🎬 Videos",
+		"stats.audio":             "🎵 Audio",
+		"stats.other":             "📄 Other",
+		"usage.title":             "Storage Usage",
+		"trash.trashed":           "Trashed: %s",
+		"trash.restore":           "Restore",
+		"trash.restore_display":   "Restore \"%s\"",
+		"trash.purge":             "Delete forever",
+		"trash.purge_display":     "Delete \"%s\" forever",
+		"folders.default":         "Default (by month)",
+		"folders.more":            "More…",
+		"recent.show_more":        "Show more",
+		"help.commands": `Commands:
+/connect_drive - Connect Google Drive
+/recent_files - Recently uploaded files
+/history - Monthly upload history
+/search <keyword> - Search by file name
+/between <start date> <end date> - Files by date
+/where - Show where uploads go
+/choose_folder - Choose the upload folder
+/setfolder <name> - Set the main upload folder
+/currentfolder - Show the current main folder
+/route <type> <folder> - Route uploads by type
+/pause <duration> /resume - Pause or resume uploads
+/description <text> - Set the file description
+/note <text> - Add a note
+/rename <new name> - Rename the latest upload
+/undo - Move the latest upload to the trash
+/cancel - Cancel an upload in progress
+/lang en|zh - Switch the reply language
+/trash - Manage files in the trash
+/sandbox on|off|clear - Sandbox mode
+/usage - Monthly storage usage
+/stats - Upload statistics
+/quota - Google Drive free space
+/manifest [YYYY-MM] - Export the month's file list (CSV)
+/whoami - Show the linked Google account
+/reconnect - Reconnect
+/disconnect_drive - Disconnect`,
+	},
+}
+
+// languageCache remembers each user's language so replies don't need a
+// Firestore read. /lang updates it.
+var languageCache sync.Map
+
+// tr returns the message for key in the user's language, falling back to
+// the default language and then to the key itself. "/command" mentions
+// use the configured command prefix.
+func tr(ctx context.Context, userID, key string) string {
+	return withCommandPrefix(translate(userLanguage(ctx, userID), key))
+}
+
+// trf is tr with fmt placeholders filled in.
+func trf(ctx context.Context, userID, key string, args ...interface{}) string {
+	return fmt.Sprintf(tr(ctx, userID, key), args...)
+}
+
+func translate(lang, key string) string {
+	if msg, ok := messageCatalogs[lang][key]; ok {
+		return msg
+	}
+	if msg, ok := messageCatalogs[defaultLanguage][key]; ok {
+		return msg
+	}
+	return key
+}
+
+// isCatalogText reports whether text is the key's message in any language.
+// Quick reply buttons send their label back, and the user may have
+// switched languages since the buttons were shown.
+func isCatalogText(key, text string) bool {
+	for _, catalog := range messageCatalogs {
+		if catalog[key] != "" && catalog[key] == text {
+			return true
+		}
+	}
+	return false
+}
+
+// userLanguage returns the language the user chose with /lang, or the
+// default when they never chose or it can't be loaded.
+func userLanguage(ctx context.Context, userID string) string {
+	if userID == "" || firestoreClient == nil {
+		return defaultLanguage
+	}
+	if lang, ok := languageCache.Load(userID); ok {
+		return lang.(string)
+	}
+	prefs, err := getUserPrefs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load language for user %s: %v", userID, err)
+		return defaultLanguage
+	}
+	lang := prefs.Language
+	if _, ok := messageCatalogs[lang]; !ok {
+		lang = defaultLanguage
+	}
+	languageCache.Store(userID, lang)
+	return lang
+}
+
+// handleLangCommand stores the user's reply language.
+func handleLangCommand(ctx context.Context, bot botClient, replyToken, userID string, args []string) {
+	if len(args) != 1 {
+		replyText(bot, replyToken, trf(ctx, userID, "lang.usage", commandText("lang")))
+		return
+	}
+	lang := args[0]
+	if _, ok := messageCatalogs[lang]; !ok {
+		replyText(bot, replyToken, trf(ctx, userID, "lang.usage", commandText("lang")))
+		return
+	}
+	if err := updateUserPrefs(ctx, userID, map[string]interface{}{"language": lang}); err != nil {
+		log.Printf("Failed to set language for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "lang.failed"))
+		return
+	}
+	languageCache.Store(userID, lang)
+	replyText(bot, replyToken, translate(lang, "lang.set"))
+}
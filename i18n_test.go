@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// TestTranslate tests that the same key returns different text per
+// language.
+func TestTranslate(t *testing.T) {
+	for _, key := range []string{"connect.prompt", "reconnect.message", "upload.success", "error.quota"} {
+		zh, en := translate(langZh, key), translate(langEn, key)
+		if zh == key || en == key {
+			t.Errorf("Expected %s to be in both catalogs", key)
+		}
+		if zh == en {
+			t.Errorf("Expected %s to differ between languages, but both are '%s'", key, zh)
+		}
+	}
+}
+
+// TestTranslateFallback tests unknown languages and keys.
+func TestTranslateFallback(t *testing.T) {
+	if got := translate("fr", "connect.button"); got != translate(defaultLanguage, "connect.button") {
+		t.Errorf("Expected unknown language to use the default, but got: '%s'", got)
+	}
+	if got := translate(langEn, "no.such.key"); got != "no.such.key" {
+		t.Errorf("Expected unknown key to be returned as is, but got: '%s'", got)
+	}
+}
+
+// TestMessageCatalogsComplete tests that every language has every key.
+func TestMessageCatalogsComplete(t *testing.T) {
+	for lang, catalog := range messageCatalogs {
+		for key := range messageCatalogs[defaultLanguage] {
+			if _, ok := catalog[key]; !ok {
+				t.Errorf("Expected %s catalog to have %s", lang, key)
+			}
+		}
+	}
+}
+
+// TestTrWithoutFirestore tests that tr uses the default language when
+// preferences can't be loaded.
+func TestTrWithoutFirestore(t *testing.T) {
+	old := firestoreClient
+	defer func() { firestoreClient = old }()
+	firestoreClient = nil
+	if got := tr(context.Background(), "U1", "connect.button"); got != translate(defaultLanguage, "connect.button") {
+		t.Errorf("Expected default language without Firestore, but got: '%s'", got)
+	}
+}
+
+// TestIsCatalogText tests that the confirm button text of every language
+// is recognized.
+func TestIsCatalogText(t *testing.T) {
+	for _, text := range []string{"上傳", "Upload"} {
+		if !isCatalogText("manual.confirm", text) {
+			t.Errorf("Expected %q to confirm", text)
+		}
+	}
+	if isCatalogText("manual.confirm", "不用了") {
+		t.Errorf("Expected the decline text not to confirm")
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// uploadMarkerKey is the appProperties key set on every file the bot
+// manages, whether uploaded through LINE or adopted from a share link.
+const uploadMarkerKey = "lineBotUpload"
+
+// driveLinkPattern matches Drive file share links in both the
+// /file/d/<id> and open?id=<id> forms.
+var driveLinkPattern = regexp.MustCompile(`https://drive\.google\.com/(?:file/d/|open\?id=)([A-Za-z0-9_-]+)`)
+
+// parseDriveLink extracts the file ID from a Drive share link in text.
+func parseDriveLink(text string) (string, bool) {
+	m := driveLinkPattern.FindStringSubmatch(text)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// handleImportLink adopts a file the user shared a link to: it is marked
+// as managed by the bot and moved into the upload folder.
+func handleImportLink(ctx context.Context, bot botClient, replyToken, userID, fileID string) {
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	prefs, err := getUserPrefs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load prefs for user %s, using defaults: %v", userID, err)
+		prefs = &userPrefs{}
+	}
+
+	file, err := adoptDriveFile(ctx, srv, prefs, fileID)
+	if err != nil {
+		log.Printf("Failed to import file %s for user %s: %v", fileID, userID, err)
+		var apiErr *googleapi.Error
+		switch {
+		case errors.As(err, &apiErr) && (apiErr.Code == http.StatusNotFound || apiErr.Code == http.StatusForbidden):
+			// drive.file only grants access to files this app created, so
+			// links to anything else look like missing files.
+			replyText(bot, replyToken, tr(ctx, userID, "import.no_access"))
+		case errors.Is(err, errNotOwner):
+			replyText(bot, replyToken, tr(ctx, userID, "import.not_owner"))
+		default:
+			if !replyForError(ctx, bot, replyToken, userID, err) {
+				replyText(bot, replyToken, tr(ctx, userID, "import.failed"))
+			}
+		}
+		return
+	}
+	folderName := rootFolderName(prefs)
+	if prefs.Sandbox {
+		folderName = sandboxFolderName
+	}
+	replyText(bot, replyToken, trf(ctx, userID, "import.done", file.Name, folderName, file.WebViewLink))
+}
+
+var errNotOwner = errors.New("file is not owned by the user")
+
+// adoptDriveFile marks fileID as a bot upload and moves it into the
+// folder an upload of the same type would go to, honouring /sandbox and
+// /route.
+func adoptDriveFile(ctx context.Context, srv *drive.Service, prefs *userPrefs, fileID string) (*drive.File, error) {
+	file, err := srv.Files.Get(fileID).SupportsAllDrives(supportsAllDrives()).Fields("id, name, mimeType, parents, ownedByMe").Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	if !file.OwnedByMe {
+		return nil, errNotOwner
+	}
+
+	folderID, err := resolveDestination(ctx, srv, prefs, uploadOptions{
+		Folder: prefs.Routes[mediaTypeForMime(file.MimeType)],
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	call := srv.Files.Update(fileID, &drive.File{
+		AppProperties: map[string]string{uploadMarkerKey: "true"},
+	}).SupportsAllDrives(supportsAllDrives()).Fields("id, name, webViewLink")
+	if !containsString(file.Parents, folderID) {
+		call = call.AddParents(folderID).RemoveParents(strings.Join(file.Parents, ","))
+	}
+	return call.Context(ctx).Do()
+}
+
+// mediaTypeForMime maps a Drive file's MIME type to the LINE media type
+// /route uses for it.
+func mediaTypeForMime(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return mediaTypeImage
+	case strings.HasPrefix(mimeType, "video/"):
+		return mediaTypeVideo
+	case strings.HasPrefix(mimeType, "audio/"):
+		return mediaTypeAudio
+	default:
+		return mediaTypeFile
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestParseDriveLink tests extracting file IDs from share links.
+func TestParseDriveLink(t *testing.T) {
+	tests := []struct {
+		text   string
+		wantID string
+		wantOK bool
+	}{
+		{"https://drive.google.com/file/d/1AbC_d-E/view?usp=sharing", "1AbC_d-E", true},
+		{"看這個 https://drive.google.com/open?id=xyz123", "xyz123", true},
+		{"https://drive.google.com/drive/folders/abc", "", false},
+		{"hello", "", false},
+	}
+	for _, tt := range tests {
+		id, ok := parseDriveLink(tt.text)
+		if id != tt.wantID || ok != tt.wantOK {
+			t.Errorf("%q: expected (%q, %v), but got: (%q, %v)", tt.text, tt.wantID, tt.wantOK, id, ok)
+		}
+	}
+}
+
+// TestAdoptDriveFileDestination tests that an imported file goes where an
+// upload would: the sandbox while it's on, otherwise the routed folder.
+func TestAdoptDriveFileDestination(t *testing.T) {
+	tests := []struct {
+		name       string
+		prefs      *userPrefs
+		wantParent string
+	}{
+		{"sandbox", &userPrefs{Sandbox: true, Routes: map[string]string{mediaTypeImage: "Photos"}}, "sandbox_id"},
+		{"route", &userPrefs{Routes: map[string]string{mediaTypeImage: "Photos"}}, "photos_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var added string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				q := r.URL.Query().Get("q")
+				switch {
+				case r.Method == http.MethodPatch:
+					added = r.URL.Query().Get("addParents")
+					json.NewEncoder(w).Encode(&drive.File{Id: "f1", Name: "cat.png"})
+				case strings.Contains(q, sandboxFolderName):
+					json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "sandbox_id"}}})
+				case strings.Contains(q, "'Photos'"):
+					json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "photos_id"}}})
+				case q != "":
+					json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "main_id"}}})
+				default:
+					json.NewEncoder(w).Encode(&drive.File{Id: "f1", Name: "cat.png", MimeType: "image/png", Parents: []string{"old_id"}, OwnedByMe: true})
+				}
+			}))
+			defer server.Close()
+
+			srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+			if err != nil {
+				t.Fatalf("Failed to create mock drive service: %v", err)
+			}
+			if _, err := adoptDriveFile(context.Background(), srv, tt.prefs, "f1"); err != nil {
+				t.Fatalf("Expected no error, but got: %v", err)
+			}
+			if added != tt.wantParent {
+				t.Errorf("Expected the file moved to %s, but got: %q", tt.wantParent, added)
+			}
+		})
+	}
+}
+
+// TestMediaTypeForMime tests mapping Drive MIME types to /route types.
+func TestMediaTypeForMime(t *testing.T) {
+	tests := map[string]string{
+		"image/png":       mediaTypeImage,
+		"video/mp4":       mediaTypeVideo,
+		"audio/m4a":       mediaTypeAudio,
+		"application/pdf": mediaTypeFile,
+		"":                mediaTypeFile,
+	}
+	for mimeType, want := range tests {
+		if got := mediaTypeForMime(mimeType); got != want {
+			t.Errorf("%q: expected %q, but got: %q", mimeType, want, got)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+)
+
+// botClient is the part of the LINE Messaging API the bot uses.
+// *messaging_api.MessagingApiAPI implements it; tests can substitute a
+// fake that records what was sent.
+type botClient interface {
+	ReplyMessage(req *messaging_api.ReplyMessageRequest) (*messaging_api.ReplyMessageResponse, error)
+	PushMessage(req *messaging_api.PushMessageRequest, xLineRetryKey string) (*messaging_api.PushMessageResponse, error)
+	LinkRichMenuIdToUser(userID, richMenuID string) (struct{}, error)
+	GetRichMenuIdOfUser(userID string) (*messaging_api.RichMenuIdResponse, error)
+}
+
+// blobClient fetches message content, as *messaging_api.MessagingApiBlobAPI
+// does.
+type blobClient interface {
+	GetMessageContent(messageID string) (*http.Response, error)
+}
+
+var (
+	_ botClient  = (*messaging_api.MessagingApiAPI)(nil)
+	_ blobClient = (*messaging_api.MessagingApiBlobAPI)(nil)
+)
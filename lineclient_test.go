@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+)
+
+// fakeBot is a botClient that records what was sent instead of calling
+// LINE.
+type fakeBot struct {
+	replies []fakeMessage
+	pushes  []fakeMessage
+	menus   map[string]string
+}
+
+// fakeMessage is one reply or push: the reply token or recipient, and the
+// text of each message.
+type fakeMessage struct {
+	to    string
+	texts []string
+}
+
+func newFakeBot() *fakeBot {
+	return &fakeBot{menus: map[string]string{}}
+}
+
+func (b *fakeBot) ReplyMessage(req *messaging_api.ReplyMessageRequest) (*messaging_api.ReplyMessageResponse, error) {
+	b.replies = append(b.replies, fakeMessage{to: req.ReplyToken, texts: messageTexts(req.Messages)})
+	return &messaging_api.ReplyMessageResponse{}, nil
+}
+
+func (b *fakeBot) PushMessage(req *messaging_api.PushMessageRequest, xLineRetryKey string) (*messaging_api.PushMessageResponse, error) {
+	b.pushes = append(b.pushes, fakeMessage{to: req.To, texts: messageTexts(req.Messages)})
+	return &messaging_api.PushMessageResponse{}, nil
+}
+
+func (b *fakeBot) LinkRichMenuIdToUser(userID, richMenuID string) (struct{}, error) {
+	b.menus[userID] = richMenuID
+	return struct{}{}, nil
+}
+
+func (b *fakeBot) GetRichMenuIdOfUser(userID string) (*messaging_api.RichMenuIdResponse, error) {
+	return &messaging_api.RichMenuIdResponse{RichMenuId: b.menus[userID]}, nil
+}
+
+// messageTexts returns the text of text messages and the alt text of
+// Flex messages.
+func messageTexts(messages []messaging_api.MessageInterface) []string {
+	var texts []string
+	for _, m := range messages {
+		switch m := m.(type) {
+		case *messaging_api.TextMessage:
+			texts = append(texts, m.Text)
+		case *messaging_api.FlexMessage:
+			texts = append(texts, m.AltText)
+		default:
+			texts = append(texts, fmt.Sprintf("%T", m))
+		}
+	}
+	return texts
+}
+
+// TestSendUploadSuccessReply tests the success reply, with and without a
+// tip, and that a deferred token pushes it instead.
+func TestSendUploadSuccessReply(t *testing.T) {
+	link := "https://drive.google.com/file/d/file_1/view"
+	success := uploadIcon(mediaTypeImage) + fmt.Sprintf(translate(langZh, "upload.success"), link)
+
+	bot := newFakeBot()
+	sendUploadSuccessReply(context.Background(), bot, "token1", "U1", link, mediaTypeImage, "")
+	sendUploadSuccessReply(context.Background(), bot, "token2", "U1", link, mediaTypeImage, "tip")
+	if len(bot.replies) != 2 {
+		t.Fatalf("Expected 2 replies, but got: %+v", bot.replies)
+	}
+	if got := bot.replies[0]; got.to != "token1" || len(got.texts) != 1 || got.texts[0] != success {
+		t.Errorf("Unexpected success reply: %+v", got)
+	}
+	if got := bot.replies[1].texts; len(got) != 2 || got[0] != success || got[1] != "tip" {
+		t.Errorf("Expected the tip after the success message, but got: %q", got)
+	}
+
+	sendUploadSuccessReply(context.Background(), bot, deferredReplyToken("U1"), "U1", link, mediaTypeImage, "")
+	if len(bot.pushes) != 1 || bot.pushes[0].to != "U1" || bot.pushes[0].texts[0] != success {
+		t.Errorf("Expected the result to be pushed, but got: %+v", bot.pushes)
+	}
+}
+
+// TestReplyForErrorMessages tests the reply sent for each typed error.
+func TestReplyForErrorMessages(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{ErrTokenNotFound, translate(langZh, "connect.prompt")},
+		{ErrTokenInvalid, reconnectionMessage(context.Background(), "U1").Text},
+		{ErrQuotaExceeded, translate(langZh, "error.quota")},
+		{ErrRateLimited, translate(langZh, "error.rate_limited")},
+		{ErrFileTooLarge, translate(langZh, "error.too_large")},
+		{ErrInsufficientScope, fmt.Sprintf(translate(langZh, "error.scope"), reconnectCommand)},
+		{ErrTimeout, translate(langZh, "error.timeout")},
+	}
+	for _, tt := range tests {
+		bot := newFakeBot()
+		if !replyForError(context.Background(), bot, "token", "U1", fmt.Errorf("wrapped: %w", tt.err)) {
+			t.Errorf("%v: expected a reply to be sent", tt.err)
+			continue
+		}
+		if len(bot.replies) != 1 || len(bot.replies[0].texts) != 1 || bot.replies[0].texts[0] != tt.want {
+			t.Errorf("%v: expected %q, but got: %+v", tt.err, tt.want, bot.replies)
+		}
+	}
+
+	bot := newFakeBot()
+	if replyForError(context.Background(), bot, "token", "U1", errors.New("connection reset")) || len(bot.replies) != 0 {
+		t.Errorf("Expected unknown errors to be left to the caller, but got: %+v", bot.replies)
+	}
+}
+
+// TestSetUserMenuWithFakeBot tests linking through the botClient
+// interface.
+func TestSetUserMenuWithFakeBot(t *testing.T) {
+	bot := newFakeBot()
+	if err := setUserMenu(bot, "U1", "richmenu-main"); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if bot.menus["U1"] != "richmenu-main" {
+		t.Errorf("Expected richmenu-main to be linked, but got: %q", bot.menus["U1"])
+	}
+}
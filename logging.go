@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+)
+
+// logger writes one JSON object per line, which Cloud Logging parses into
+// structured entries. Inside event handling use loggerFrom(ctx) so entries
+// carry the event's user and message IDs.
+var logger = newLogger(os.Stderr, false)
+
+func newLogger(w io.Writer, debug bool) *slog.Logger {
+	level := slog.LevelInfo
+	if debug {
+		level = slog.LevelDebug
+	}
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: cloudLoggingAttr,
+	}))
+}
+
+// setupLogging installs logger as the default, so plain log.Printf calls
+// are written as JSON entries too.
+func setupLogging(debug bool) {
+	logger = newLogger(os.Stderr, debug)
+	slog.SetDefault(logger)
+}
+
+// cloudLoggingAttr renames slog's level and message keys to the ones Cloud
+// Logging recognizes.
+func cloudLoggingAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.LevelKey:
+		a.Key = "severity"
+		if level, ok := a.Value.Any().(slog.Level); ok && level == slog.LevelWarn {
+			a.Value = slog.StringValue("WARNING")
+		}
+	case slog.MessageKey:
+		a.Key = "message"
+	}
+	return a
+}
+
+type loggerKey struct{}
+
+// withLogger returns a context whose loggerFrom is l.
+func withLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// loggerFrom returns the logger attached to ctx, or the default logger.
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return logger
+}
+
+// eventLogger returns a logger tagged with the event's type, ID, user and
+// message. Reply tokens and message contents are left out.
+func eventLogger(event webhook.EventInterface) *slog.Logger {
+	attrs := []any{slog.String("eventType", event.GetType())}
+	if id := webhookEventID(event); id != "" {
+		attrs = append(attrs, slog.String("eventID", id))
+	}
+	if isRedelivery(event) {
+		attrs = append(attrs, slog.Bool("redelivered", true))
+	}
+	var src webhook.SourceInterface
+	switch e := event.(type) {
+	case webhook.MessageEvent:
+		src = e.Source
+		if id := messageContentID(e.Message); id != "" {
+			attrs = append(attrs, slog.String("messageID", id))
+		}
+	case webhook.PostbackEvent:
+		src = e.Source
+	case webhook.FollowEvent:
+		src = e.Source
+	}
+	if userID, ok := extractUserID(src); ok {
+		attrs = append(attrs, slog.String("userID", userID))
+	}
+	return logger.With(attrs...)
+}
+
+// messageContentID returns the ID of a message, or "" for message types
+// the bot does not handle.
+func messageContentID(m webhook.MessageContentInterface) string {
+	switch m := m.(type) {
+	case webhook.TextMessageContent:
+		return m.Id
+	case webhook.ImageMessageContent:
+		return m.Id
+	case webhook.VideoMessageContent:
+		return m.Id
+	case webhook.AudioMessageContent:
+		return m.Id
+	case webhook.FileMessageContent:
+		return m.Id
+	}
+	return ""
+}
+
+// sensitiveQueryParams are URL query parameters that grant access on their
+// own and must never reach the logs.
+var sensitiveQueryParams = []string{"code", "state", "token", "access_token", "refresh_token", "id_token", "client_secret"}
+
+// redactURL returns raw with the values of token-like query parameters
+// replaced. Unparseable URLs are dropped entirely.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<unparseable url>"
+	}
+	q := u.Query()
+	redacted := false
+	for key := range q {
+		for _, sensitive := range sensitiveQueryParams {
+			if strings.EqualFold(key, sensitive) {
+				q.Set(key, "REDACTED")
+				redacted = true
+			}
+		}
+	}
+	if redacted {
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
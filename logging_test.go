@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+)
+
+// TestRedactURL tests that token-like query parameters are hidden and
+// everything else is kept.
+func TestRedactURL(t *testing.T) {
+	got := redactURL("https://example.com/oauth/callback?state=abc123&code=4/secret&scope=drive")
+	if strings.Contains(got, "abc123") || strings.Contains(got, "secret") {
+		t.Errorf("Expected state and code to be redacted, but got: %s", got)
+	}
+	if !strings.Contains(got, "scope=drive") || !strings.HasPrefix(got, "https://example.com/oauth/callback?") {
+		t.Errorf("Expected the rest of the URL to be kept, but got: %s", got)
+	}
+
+	plain := "https://example.com/path?page=2"
+	if got := redactURL(plain); got != plain {
+		t.Errorf("Expected %s unchanged, but got: %s", plain, got)
+	}
+	if got := redactURL("https://example.com/?Access_Token=xyz"); strings.Contains(got, "xyz") {
+		t.Errorf("Expected parameter names to match case-insensitively, but got: %s", got)
+	}
+}
+
+// TestEventLogger tests that event entries carry the user, message and
+// event type in Cloud Logging's format without the reply token.
+func TestEventLogger(t *testing.T) {
+	var buf bytes.Buffer
+	original := logger
+	logger = newLogger(&buf, false)
+	defer func() { logger = original }()
+
+	event := webhook.MessageEvent{
+		Event:          webhook.Event{Type: "message"},
+		Source:         webhook.UserSource{UserId: "U123"},
+		WebhookEventId: "01EVENT",
+		ReplyToken:     "reply-secret",
+		Message:        webhook.ImageMessageContent{Id: "M456"},
+	}
+	ctx := withLogger(context.Background(), eventLogger(event))
+	loggerFrom(ctx).Info("Upload started")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a JSON entry, but got: %s", buf.String())
+	}
+	want := map[string]string{
+		"severity":  "INFO",
+		"message":   "Upload started",
+		"eventType": "message",
+		"eventID":   "01EVENT",
+		"userID":    "U123",
+		"messageID": "M456",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("Expected %s=%q, but got: %v", k, v, entry[k])
+		}
+	}
+	if strings.Contains(buf.String(), "reply-secret") {
+		t.Errorf("Expected the reply token not to be logged, but got: %s", buf.String())
+	}
+
+	if loggerFrom(context.Background()) != logger {
+		t.Error("Expected the default logger without an attached one")
+	}
+}
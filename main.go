@@ -13,6 +13,7 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
@@ -20,8 +21,13 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"cloud.google.com/go/firestore"
@@ -30,23 +36,19 @@ import (
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/drive/v3"
-	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 var (
-	googleOauthConfig      *oauth2.Config
-	firestoreClient        *firestore.Client
-	ErrOauth2TokenNotFound = errors.New("oauth2 token not found")
+	googleOauthConfig *oauth2.Config
+	oauthRedirectURLs []string
+	firestoreClient   *firestore.Client
 )
 
 const (
 	stateCollection = "oauth_states"
-	tokenCollection        = "user_tokens"
-	richMenuConnect        = "richmenu-8360de4ffc27c9eba7849980675ae7f3"
-	richMenuMain           = "richmenu-94cf1a33f7ddd92e65d40d5964070806"
+	tokenCollection = "user_tokens"
+	mainFolderName  = "LINE Bot Uploads"
 )
 
 func main() {
@@ -61,10 +63,29 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create Firestore client: %v", err)
 	}
-	defer firestoreClient.Close()
+
+	loadConfig()
+	setupLogging(debugLogging)
+
+	tokenEncryptionKey, err = parseTokenEncryptionKey(os.Getenv("TOKEN_ENCRYPTION_KEY"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// GOOGLE_REDIRECT_URL may list several comma-separated URLs so one
+	// deployment can serve e.g. staging and production hosts.
+	for _, u := range strings.Split(os.Getenv("GOOGLE_REDIRECT_URL"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			oauthRedirectURLs = append(oauthRedirectURLs, u)
+		}
+	}
+	var defaultRedirectURL string
+	if len(oauthRedirectURLs) > 0 {
+		defaultRedirectURL = oauthRedirectURLs[0]
+	}
 
 	googleOauthConfig = &oauth2.Config{
-		RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URL"),
+		RedirectURL:  defaultRedirectURL,
 		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
 		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
 		Scopes:       []string{drive.DriveFileScope},
@@ -74,6 +95,7 @@ func main() {
 	channelSecret := os.Getenv("ChannelSecret")
 	bot, err := messaging_api.NewMessagingApiAPI(
 		os.Getenv("ChannelAccessToken"),
+		messaging_api.WithHTTPClient(lineHTTPClient()),
 	)
 	if err != nil {
 		log.Fatal(err)
@@ -96,6 +118,17 @@ func main() {
 
 		log.Println("Webhook handler called...")
 
+		// ParseRequest consumes the body, so keep a copy for DEBUG_WEBHOOK.
+		var rawBody []byte
+		if debugWebhook {
+			var err error
+			if rawBody, err = bufferRequestBody(req); err != nil {
+				log.Printf("Cannot read request body: %v", err)
+				w.WriteHeader(500)
+				return
+			}
+		}
+
 		cb, err := webhook.ParseRequest(channelSecret, req)
 		if err != nil {
 			log.Printf("Cannot parse request: %+v\n", err)
@@ -106,326 +139,32 @@ func main() {
 			}
 			return
 		}
+		if debugWebhook {
+			log.Printf("[DEBUG] Webhook payload: %s", redactWebhookPayload(rawBody))
+		}
 
 		log.Println("Handling events...")
-		for _, event := range cb.Events {
-			log.Printf("/callback called%+v...\n", event)
-
-			switch e := event.(type) {
-			case webhook.MessageEvent:
-				switch message := e.Message.(type) {
-				case webhook.TextMessageContent:
-					if message.Text == "/connect_drive" {
-						// Generate a random state string to prevent CSRF attacks
-						userID := e.Source.(webhook.UserSource).UserId
-						state := generateState()
-
-						// Store state and user ID in Firestore with a short expiration
-						_, err := firestoreClient.Collection(stateCollection).Doc(state).Set(ctx, map[string]interface{}{
-							"user_id":    userID,
-							"created_at": time.Now(),
-						})
-						if err != nil {
-							log.Printf("Failed to save state to firestore: %v", err)
-							// Optionally reply to user about the error
-							return
-						}
-
-						// Generate authorization URL
-						url := googleOauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
-						if _, err = bot.ReplyMessage(
-							&messaging_api.ReplyMessageRequest{
-								ReplyToken: e.ReplyToken,
-								Messages: []messaging_api.MessageInterface{
-									&messaging_api.TextMessage{
-										Text: "Please authorize this app to upload files to your Google Drive: " + url,
-									},
-								},
-							},
-						); err != nil {
-							log.Print(err)
-						}
-						return
-					} else if message.Text == "/recent_files" {
-						userID := e.Source.(webhook.UserSource).UserId
-						srv, err := getGoogleDriveService(userID)
-						if err != nil {
-							// Handle not connected error
-							if errors.Is(err, ErrOauth2TokenNotFound) {
-								if _, err = bot.ReplyMessage(
-									&messaging_api.ReplyMessageRequest{
-										ReplyToken: e.ReplyToken,
-										Messages: []messaging_api.MessageInterface{
-											&messaging_api.TextMessage{
-												Text: "Please connect your Google Drive account first.",
-												QuickReply: &messaging_api.QuickReply{
-													Items: []messaging_api.QuickReplyItem{
-														{
-															Action: &messaging_api.MessageAction{
-																Label: "Connect Google Drive",
-																Text:  "/connect_drive",
-															},
-														},
-													},
-												},
-											},
-										},
-									},
-								); err != nil {
-									log.Print(err)
-								}
-							} else if isGoogleAuthError(err) {
-								sendReconnectionPrompt(bot, e.ReplyToken)
-							} else {
-								log.Printf("Failed to get drive service: %v", err)
-							}
-							return
-						}
-
-						files, err := getRecentFiles(srv, 5)
-						if err != nil {
-							log.Printf("Failed to get recent files: %v", err)
-							if isGoogleAuthError(err) {
-								sendReconnectionPrompt(bot, e.ReplyToken)
-							}
-							// Optionally reply with an error message
-							return
-						}
-
-						if len(files) == 0 {
-							if _, err = bot.ReplyMessage(
-								&messaging_api.ReplyMessageRequest{
-									ReplyToken: e.ReplyToken,
-									Messages: []messaging_api.MessageInterface{
-										&messaging_api.TextMessage{
-											Text: "You haven't uploaded any files yet.",
-										},
-									},
-								},
-							); err != nil {
-								log.Print(err)
-							}
-							return
-						}
-
-						var bubbles []messaging_api.FlexBubble
-						for _, file := range files {
-							bubble := messaging_api.FlexBubble{
-								Body: &messaging_api.FlexBox{
-									Layout: "vertical",
-									Contents: []messaging_api.FlexComponentInterface{
-										&messaging_api.FlexText{
-											Text:   "Recent Upload",
-											Weight: "bold",
-											Size:   "sm",
-											Color:  "#1DB446",
-										},
-										&messaging_api.FlexText{
-											Text:   file.Name,
-											Weight: "bold",
-											Size:   "xl",
-											Margin: "md",
-											Wrap:   true,
-										},
-									},
-								},
-								Footer: &messaging_api.FlexBox{
-									Layout:  "vertical",
-									Spacing: "sm",
-									Contents: []messaging_api.FlexComponentInterface{
-										&messaging_api.FlexButton{
-											Style:  "link",
-											Height: "sm",
-											Action: &messaging_api.UriAction{
-												Label: "Open in Drive",
-												Uri:   file.WebViewLink,
-											},
-										},
-									},
-								},
-							}
-							bubbles = append(bubbles, bubble)
-						}
-
-						carousel := &messaging_api.FlexCarousel{
-							Contents: bubbles,
-						}
-
-						if _, err = bot.ReplyMessage(
-							&messaging_api.ReplyMessageRequest{
-								ReplyToken: e.ReplyToken,
-								Messages: []messaging_api.MessageInterface{
-									&messaging_api.FlexMessage{
-										AltText:  "Here are your recent files",
-										Contents: carousel,
-										QuickReply: &messaging_api.QuickReply{
-											Items: []messaging_api.QuickReplyItem{
-												{
-													Action: &messaging_api.MessageAction{
-														Label: "查詢最近檔案",
-														Text:  "/recent_files",
-													},
-												},
-												{
-													Action: &messaging_api.MessageAction{
-														Label: "中斷連線",
-														Text:  "/disconnect_drive",
-													},
-												},
-											},
-										},
-									},
-								},
-							},
-						); err != nil {
-							log.Print(err)
-						}
-						return
-					} else if message.Text == "/disconnect_drive" {
-						userID := e.Source.(webhook.UserSource).UserId
-						err := revokeGoogleToken(ctx, userID)
-						var replyText string
-						if err != nil {
-							if errors.Is(err, ErrOauth2TokenNotFound) {
-								replyText = "Your account is not connected to Google Drive."
-							} else {
-								replyText = "An error occurred while disconnecting. Please try again later."
-								log.Printf("Failed to revoke token for user %s: %v", userID, err)
-							}
-						} else {
-							replyText = "Successfully disconnected from Google Drive."
-						}
-
-						if _, err = bot.ReplyMessage(
-							&messaging_api.ReplyMessageRequest{
-								ReplyToken: e.ReplyToken,
-								Messages: []messaging_api.MessageInterface{
-									&messaging_api.TextMessage{
-										Text: replyText,
-									},
-								},
-							},
-						); err != nil {
-							log.Print(err)
-						}
-						return
-					} else if message.Text == "/reconnect" {
-						userID := e.Source.(webhook.UserSource).UserId
-
-						// 1. Revoke existing token. We log errors but proceed anyway.
-						err := revokeGoogleToken(ctx, userID)
-						if err != nil && !errors.Is(err, ErrOauth2TokenNotFound) {
-							log.Printf("Error during token revocation in /reconnect for user %s: %v", userID, err)
-						}
-
-						// 2. Start new connection flow (same as /connect_drive)
-						state := generateState()
-						_, err = firestoreClient.Collection(stateCollection).Doc(state).Set(ctx, map[string]interface{}{
-							"user_id":    userID,
-							"created_at": time.Now(),
-						})
-						if err != nil {
-							log.Printf("Failed to save state to firestore for reconnect: %v", err)
-							// Reply with an error message
-							if _, err = bot.ReplyMessage(
-								&messaging_api.ReplyMessageRequest{
-									ReplyToken: e.ReplyToken,
-									Messages: []messaging_api.MessageInterface{
-										&messaging_api.TextMessage{
-											Text: "An error occurred while trying to reconnect. Please try '/connect_drive' manually.",
-										},
-									},
-								},
-							); err != nil {
-								log.Print(err)
-							}
-							return
-						}
-
-						url := googleOauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
-						if _, err = bot.ReplyMessage(
-							&messaging_api.ReplyMessageRequest{
-								ReplyToken: e.ReplyToken,
-								Messages: []messaging_api.MessageInterface{
-									&messaging_api.TextMessage{
-										Text: "Please re-authorize this app to upload files to your Google Drive: " + url,
-									},
-								},
-							},
-						); err != nil {
-							log.Print(err)
-						}
-						return
-					}
-
-					if _, err = bot.ReplyMessage(
-						&messaging_api.ReplyMessageRequest{
-							ReplyToken: e.ReplyToken,
-							Messages: []messaging_api.MessageInterface{
-								&messaging_api.TextMessage{
-									Text: message.Text,
-								},
-							},
-						},
-					); err != nil {
-						log.Print(err)
-					} else {
-						log.Println("Sent text reply.")
-					}
-				case webhook.StickerMessageContent:
-					replyMessage := fmt.Sprintf(
-						"貼圖訊息: sticker id is %s, stickerResourceType is %s", message.StickerId, message.StickerResourceType)
-					if _, err = bot.ReplyMessage(
-						&messaging_api.ReplyMessageRequest{
-							ReplyToken: e.ReplyToken,
-							Messages: []messaging_api.MessageInterface{
-								&messaging_api.TextMessage{
-									Text: replyMessage,
-								},
-							},
-						}); err != nil {
-						log.Print(err)
-					} else {
-						log.Println("Sent sticker reply.")
-					}
-				case webhook.ImageMessageContent:
-					handleMediaUpload(bot, blob, e.ReplyToken, e.Source.(webhook.UserSource).UserId, message.Id, "line-bot-upload-"+message.Id+".jpg")
-				case webhook.VideoMessageContent:
-					handleMediaUpload(bot, blob, e.ReplyToken, e.Source.(webhook.UserSource).UserId, message.Id, "line-bot-upload-"+message.Id+".mp4")
-				case webhook.AudioMessageContent:
-					handleMediaUpload(bot, blob, e.ReplyToken, e.Source.(webhook.UserSource).UserId, message.Id, "line-bot-upload-"+message.Id+".m4a")
-				case webhook.FileMessageContent:
-					handleMediaUpload(bot, blob, e.ReplyToken, e.Source.(webhook.UserSource).UserId, message.Id, message.FileName)
-				case webhook.MemberJoinedEvent:
-					if s, ok := e.Source.(*webhook.GroupSource); ok {
-						log.Printf("Member joined: %s\n", s.UserId)
-					}
-				case webhook.MemberLeftEvent:
-					if s, ok := e.Source.(*webhook.GroupSource); ok {
-						log.Printf("Member left: %s\n", s.UserId)
-					}
-				case webhook.FollowEvent:
-					if s, ok := e.Source.(*webhook.UserSource); ok {
-						log.Printf("Follow event for user: %s", s.UserId)
-						if _, err := bot.LinkRichMenuIdToUser(s.UserId, richMenuConnect); err != nil {
-							log.Printf("Failed to link rich menu for new user %s: %v", s.UserId, err)
-						}
-					}
-                case webhook.BeaconEvent:
-                    if s, ok := e.Source.(*webhook.UserSource); ok {
-                        log.Printf("Beacon event: %s\n", s.UserId)
-                    }
-				default:
-					log.Printf("Unsupported message content: %T\n", e.Message)
-				}
-			default:
-				log.Printf("Unsupported message: %T\n", event)
-			}
+		inline, queued := splitEventBatch(cb.Events, webhookInlineEvents)
+		if len(queued) > 0 {
+			log.Printf("Webhook batch of %d events exceeds the inline cap of %d; handling %d in the background", len(cb.Events), webhookInlineEvents, len(queued))
+		}
+		if deferred := handleInlineEvents(ctx, bot, blob, req.Host, inline); len(deferred) > 0 {
+			log.Printf("Event deadline reached; handling the remaining %d events in the background", len(deferred))
+			queued = append(deferred, queued...)
+		}
+		if len(queued) > 0 {
+			go handleQueuedEvents(ctx, bot, blob, req.Host, queued)
 		}
 		w.WriteHeader(http.StatusOK)
 	})
 
 	http.HandleFunc("/oauth/callback", oauthCallbackHandler)
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc("/readyz", readyzHandler(checkFirestore))
+	http.Handle("/metrics", metricsHandler)
+	http.HandleFunc("/tasks/check_tokens", requireTasksSecret(tokenCheckHandler(bot)))
+	http.HandleFunc("/tasks/token_health", requireTasksSecret(tokenHealthHandler(bot)))
+	http.HandleFunc("/tasks/cleanup_states", requireTasksSecret(stateCleanupHandler))
 
 	// This is just sample code.
 	// For actual use, you must support HTTPS by using `ListenAndServeTLS`, a reverse proxy or something else.
@@ -434,9 +173,294 @@ func main() {
 		port = "5000"
 	}
 	fmt.Println("http://localhost:" + port + "/")
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
+	// Uploads run on the webhook request goroutine, so WriteTimeout must
+	// cover the slowest upload; keep SERVER_WRITE_TIMEOUT generous.
+	server := &http.Server{
+		Addr:              ":" + port,
+		ReadHeaderTimeout: serverReadHeaderTimeout,
+		ReadTimeout:       serverReadTimeout,
+		WriteTimeout:      serverWriteTimeout,
+		IdleTimeout:       serverIdleTimeout,
+	}
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
 		log.Fatal(err)
 	}
+
+	// Cloud Run sends SIGTERM before stopping the container; let running
+	// uploads finish instead of cutting them off.
+	sigCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := runServer(sigCtx, server, ln); err != nil {
+		log.Printf("Server stopped with error: %v", err)
+	}
+	if err := firestoreClient.Close(); err != nil {
+		log.Printf("Failed to close Firestore client: %v", err)
+	}
+}
+
+// handleEvent dispatches a single webhook event. host is the webhook
+// request's host, used to pick the OAuth redirect URL. The error is
+// non-nil when the event wasn't fully handled and should be handled again
+// if LINE redelivers it.
+func handleEvent(ctx context.Context, bot botClient, blob blobClient, host string, event webhook.EventInterface) error {
+	var err error
+	switch e := event.(type) {
+	case webhook.MessageEvent:
+		// In groups and rooms the sender acts on their own account.
+		userID, hasUser := extractUserID(e.Source)
+		direct := isDirectChat(e.Source)
+		chat, _ := chatID(e.Source)
+		redelivered := isRedelivery(e)
+		switch message := e.Message.(type) {
+		case webhook.TextMessageContent:
+			if hasUser && handleConversationReply(ctx, bot, blob, e.ReplyToken, userID, message.Text) {
+				return nil
+			}
+			command, args := parseCommand(message.Text)
+			if command != "" && !hasUser {
+				replyText(bot, e.ReplyToken, tr(ctx, userID, "common.unknown_sender"))
+				return nil
+			}
+			if command != "" {
+				if dup, notify := commandDebounce.check(userID, message.Text, commandDebounceWindow, time.Now()); dup {
+					if notify {
+						replyText(bot, e.ReplyToken, tr(ctx, userID, "common.wait"))
+					}
+					return nil
+				}
+			}
+			// Authorization links must not be posted where others can
+			// open them.
+			if (command == "connect_drive" || command == "reconnect") && !direct {
+				replyText(bot, e.ReplyToken, tr(ctx, userID, "common.direct_only"))
+				return nil
+			}
+			if (command == "connect_drive" || command == "reconnect") && !connectLimiter.allow(userID, time.Now()) {
+				log.Printf("Throttled %s for user %s", command, userID)
+				replyText(bot, e.ReplyToken, tr(ctx, userID, "common.throttled"))
+				return nil
+			}
+			if handler := commandHandlers[command]; handler != nil {
+				handler(ctx, bot, commandRequest{ReplyToken: e.ReplyToken, UserID: userID, Args: args, Host: host})
+				return nil
+			}
+
+			if direct {
+				if fileID, ok := parseDriveLink(message.Text); ok {
+					handleImportLink(ctx, bot, e.ReplyToken, userID, fileID)
+					return nil
+				}
+			}
+
+			if direct && defaultReply == "help" {
+				sendContextualHelp(ctx, bot, e.ReplyToken, userID)
+				return nil
+			}
+
+			if _, err = bot.ReplyMessage(
+				&messaging_api.ReplyMessageRequest{
+					ReplyToken: e.ReplyToken,
+					Messages: []messaging_api.MessageInterface{
+						&messaging_api.TextMessage{
+							Text: message.Text,
+						},
+					},
+				},
+			); err != nil {
+				log.Print(err)
+			} else {
+				log.Println("Sent text reply.")
+			}
+		case webhook.StickerMessageContent:
+			replyMessage := trf(ctx, userID, "sticker.echo", message.StickerId, message.StickerResourceType)
+			if _, err = bot.ReplyMessage(
+				&messaging_api.ReplyMessageRequest{
+					ReplyToken: e.ReplyToken,
+					Messages: []messaging_api.MessageInterface{
+						&messaging_api.TextMessage{
+							Text: replyMessage,
+						},
+					},
+				}); err != nil {
+				log.Print(err)
+			} else {
+				log.Println("Sent sticker reply.")
+			}
+		case webhook.ImageMessageContent:
+			err = handleMediaUpload(ctx, bot, blob, e.ReplyToken, userID, mediaMessage{
+				ID:          message.Id,
+				FileName:    "line-bot-upload-" + message.Id + ".jpg",
+				Type:        mediaTypeImage,
+				ExternalURL: externalContentURL(message.Id, message.ContentProvider),
+				Direct:      direct,
+				ChatID:      chat,
+				Redelivered: redelivered,
+			})
+		case webhook.VideoMessageContent:
+			err = handleMediaUpload(ctx, bot, blob, e.ReplyToken, userID, mediaMessage{
+				ID:          message.Id,
+				FileName:    "line-bot-upload-" + message.Id + ".mp4",
+				Type:        mediaTypeVideo,
+				ExternalURL: externalContentURL(message.Id, message.ContentProvider),
+				Large:       time.Duration(message.Duration)*time.Millisecond >= largeVideoDuration,
+				Direct:      direct,
+				ChatID:      chat,
+				Redelivered: redelivered,
+			})
+		case webhook.AudioMessageContent:
+			err = handleMediaUpload(ctx, bot, blob, e.ReplyToken, userID, mediaMessage{
+				ID:          message.Id,
+				FileName:    "line-bot-upload-" + message.Id + ".m4a",
+				Type:        mediaTypeAudio,
+				ExternalURL: externalContentURL(message.Id, message.ContentProvider),
+				Direct:      direct,
+				ChatID:      chat,
+				Redelivered: redelivered,
+			})
+		case webhook.FileMessageContent:
+			err = handleMediaUpload(ctx, bot, blob, e.ReplyToken, userID, mediaMessage{
+				ID:          message.Id,
+				FileName:    message.FileName,
+				Type:        mediaTypeFile,
+				Large:       int64(message.FileSize) >= largeMediaBytes,
+				Direct:      direct,
+				ChatID:      chat,
+				Redelivered: redelivered,
+			})
+		case webhook.BeaconEvent:
+			if s, ok := e.Source.(*webhook.UserSource); ok {
+				log.Printf("Beacon event: %s\n", s.UserId)
+			}
+		default:
+			log.Printf("Unsupported message content: %T\n", e.Message)
+		}
+	case webhook.PostbackEvent:
+		userID, ok := extractUserID(e.Source)
+		if !ok || e.Postback == nil {
+			break
+		}
+		if aliasID := e.Postback.Params["newRichMenuAliasId"]; aliasID != "" {
+			handleRichMenuSwitch(ctx, bot, e.ReplyToken, userID, aliasID, e.Postback.Params["status"])
+			break
+		}
+		data, err := parsePostback(e.Postback.Data)
+		if err != nil {
+			log.Printf("Invalid postback data %q: %v", e.Postback.Data, err)
+			break
+		}
+		handlePostbackAction(ctx, bot, e.ReplyToken, userID, data)
+	case webhook.VideoPlayCompleteEvent:
+		// Sent when a user finishes watching a video message that
+		// carries a trackingId. Nothing to do beyond noting it.
+		if e.VideoPlayComplete != nil {
+			debugf("Video play complete: tracking_id=%s", e.VideoPlayComplete.TrackingId)
+		}
+	case webhook.MemberJoinedEvent:
+		handleMemberJoined(ctx, e)
+	case webhook.MemberLeftEvent:
+		handleMemberLeft(ctx, bot, e)
+	case webhook.AccountLinkEvent:
+		handleAccountLinkEvent(ctx, bot, e)
+	case webhook.FollowEvent:
+		if s, ok := e.Source.(webhook.UserSource); ok {
+			log.Printf("Follow event for user: %s", s.UserId)
+			if err := setUserMenu(bot, s.UserId, richMenuConnect); err != nil {
+				log.Printf("Failed to link rich menu for new user %s: %v", s.UserId, err)
+			}
+		}
+	default:
+		log.Printf("Unsupported message: %T\n", event)
+	}
+	return err
+}
+
+// handleConnectDriveCommand replies with a Google authorization link.
+func handleConnectDriveCommand(ctx context.Context, bot botClient, req commandRequest) {
+	// Generate a random state string to prevent CSRF attacks
+	state := generateState()
+
+	// Store state and user ID in Firestore with a short expiration
+	_, err := firestoreClient.Collection(stateCollection).Doc(state).Set(ctx, map[string]interface{}{
+		"user_id":      req.UserID,
+		"created_at":   time.Now(),
+		"redirect_url": oauthConfigForHost(req.Host).RedirectURL,
+	})
+	if err != nil {
+		log.Printf("Failed to save state to firestore: %v", err)
+		// Optionally reply to user about the error
+		return
+	}
+
+	// Generate authorization URL
+	url := authCodeURL(req.Host, state, oauthForceApproval)
+	replyText(bot, req.ReplyToken, trf(ctx, req.UserID, "connect.authorize", url))
+}
+
+// handleDisconnectDriveCommand revokes and deletes the user's token.
+func handleDisconnectDriveCommand(ctx context.Context, bot botClient, req commandRequest) {
+	userID := req.UserID
+	err := revokeGoogleToken(ctx, userID)
+	var text string
+	if err != nil {
+		if errors.Is(err, ErrTokenNotFound) {
+			text = tr(ctx, userID, "disconnect.not_linked")
+		} else {
+			text = tr(ctx, userID, "disconnect.failed")
+			log.Printf("Failed to revoke token for user %s: %v", userID, err)
+		}
+	} else {
+		oauthEventsTotal.WithLabelValues("disconnect").Inc()
+		text = tr(ctx, userID, "disconnect.done")
+	}
+	replyText(bot, req.ReplyToken, text)
+}
+
+// handleReconnectCommand revokes the user's token and replies with a new
+// authorization link. Unless the user asked for "/reconnect force", a
+// token that still works is kept.
+func handleReconnectCommand(ctx context.Context, bot botClient, req commandRequest) {
+	userID := req.UserID
+	// 0. Skip the flow when the current token still works,
+	// unless the user asked for "/reconnect force".
+	force := len(req.Args) > 0 && req.Args[0] == "force"
+	if !force {
+		err := validateDriveConnection(ctx, userID)
+		if err == nil {
+			replyText(bot, req.ReplyToken, tr(ctx, userID, "reconnect.not_needed"))
+			return
+		}
+		if c := classify(err); c != ErrTokenNotFound && c != ErrTokenInvalid && c != ErrInsufficientScope {
+			log.Printf("Failed to validate connection for user %s: %v", userID, err)
+			replyText(bot, req.ReplyToken, trf(ctx, userID, "reconnect.check_failed", commandText("reconnect")+" force"))
+			return
+		}
+	}
+
+	// 1. Revoke existing token. We log errors but proceed anyway.
+	markReconnecting(ctx, userID)
+	err := revokeGoogleToken(ctx, userID)
+	if err != nil && !errors.Is(err, ErrTokenNotFound) {
+		log.Printf("Error during token revocation in /reconnect for user %s: %v", userID, err)
+	}
+
+	// 2. Start new connection flow (same as /connect_drive)
+	state := generateState()
+	_, err = firestoreClient.Collection(stateCollection).Doc(state).Set(ctx, map[string]interface{}{
+		"user_id":      userID,
+		"created_at":   time.Now(),
+		"redirect_url": oauthConfigForHost(req.Host).RedirectURL,
+	})
+	if err != nil {
+		log.Printf("Failed to save state to firestore for reconnect: %v", err)
+		replyText(bot, req.ReplyToken, trf(ctx, userID, "reconnect.failed", commandText("connect_drive")))
+		return
+	}
+
+	// Always force the consent screen on reconnect so Google
+	// issues a fresh refresh token.
+	url := authCodeURL(req.Host, state, true)
+	replyText(bot, req.ReplyToken, trf(ctx, userID, "reconnect.authorize", url))
 }
 
 func generateState() string {
@@ -445,128 +469,351 @@ func generateState() string {
 	return base64.URLEncoding.EncodeToString(b)
 }
 
+// authCodeURL builds the Google consent URL. Offline access is always
+// requested so we receive a refresh token; forcing the approval prompt is
+// optional because it makes returning users click through consent again.
+func authCodeURL(host, state string, forceApproval bool) string {
+	opts := []oauth2.AuthCodeOption{oauth2.AccessTypeOffline}
+	if forceApproval {
+		opts = append(opts, oauth2.ApprovalForce)
+	}
+	return oauthConfigForHost(host).AuthCodeURL(state, opts...)
+}
+
+// oauthConfigForHost returns a copy of googleOauthConfig whose redirect URL
+// matches the given request host, falling back to the first configured URL.
+// A copy is returned so concurrent requests never share a mutated config.
+func oauthConfigForHost(host string) *oauth2.Config {
+	cfg := *googleOauthConfig
+	for _, u := range oauthRedirectURLs {
+		parsed, err := url.Parse(u)
+		if err == nil && strings.EqualFold(parsed.Host, host) {
+			cfg.RedirectURL = u
+			break
+		}
+	}
+	return &cfg
+}
+
+// oauthConfigForState returns the config to exchange a code with. Google
+// requires the exact redirect URL used for the consent request, so the one
+// saved with the state wins over the current configuration; states saved
+// before the URL was recorded fall back to matching the host.
+func oauthConfigForState(host, redirectURL string) *oauth2.Config {
+	cfg := oauthConfigForHost(host)
+	if redirectURL != "" {
+		cfg.RedirectURL = redirectURL
+	}
+	return cfg
+}
+
 func oauthCallbackHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
+	if oauthCallbackDenied(w, r) {
+		return
+	}
 	state := r.FormValue("state")
 	code := r.FormValue("code")
 
 	// 1. Validate state and get user ID from Firestore
 	doc, err := firestoreClient.Collection(stateCollection).Doc(state).Get(ctx)
 	if err != nil {
-		log.Printf("Invalid oauth google state: %s, error: %v", state, err)
-		http.Error(w, "Invalid state parameter. Please try again.", http.StatusBadRequest)
+		logger.Warn("Invalid OAuth state", "url", redactURL(r.URL.String()), "error", err)
+		renderOAuthError(w, oauthPageLanguage(r), http.StatusBadRequest, "oauth.invalid_state")
 		return
 	}
 	// Delete state after use to prevent replay attacks
 	defer doc.Ref.Delete(ctx)
 
 	var stateData struct {
-		UserID string `firestore:"user_id"`
+		UserID      string    `firestore:"user_id"`
+		RedirectURL string    `firestore:"redirect_url"`
+		CreatedAt   time.Time `firestore:"created_at"`
 	}
 	if err := doc.DataTo(&stateData); err != nil {
 		log.Printf("Failed to parse state data: %v", err)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		renderOAuthError(w, oauthPageLanguage(r), http.StatusInternalServerError, "oauth.server_error")
 		return
 	}
 	userID := stateData.UserID
+	lang := userLanguage(ctx, userID)
+	if stateExpired(stateData.CreatedAt, time.Now()) {
+		logger.Warn("Expired OAuth state", "userID", userID)
+		renderOAuthError(w, lang, http.StatusBadRequest, "oauth.expired")
+		return
+	}
 
 	// 2. Exchange authorization code for a token
-	token, err := googleOauthConfig.Exchange(ctx, code)
+	token, err := oauthConfigForState(r.Host, stateData.RedirectURL).Exchange(ctx, code)
 	if err != nil {
-		log.Printf("Failed to exchange token: %v", err)
-		http.Error(w, "Failed to exchange token.", http.StatusInternalServerError)
+		logger.Error("Failed to exchange OAuth code", "userID", userID, "error", err)
+		renderOAuthError(w, lang, http.StatusInternalServerError, "oauth.exchange_failed")
+		return
+	}
+
+	// Google lets users untick scopes on the consent screen; a token
+	// without Drive access would only fail later with a confusing error.
+	if missing := missingScopes(token, googleOauthConfig.Scopes); len(missing) > 0 {
+		logger.Warn("OAuth scopes not granted", "userID", userID, "missing", missing)
+		renderOAuthError(w, lang, http.StatusForbidden, "oauth.scopes")
 		return
 	}
 
 	// 3. Store the token in Firestore, using the userID as the document ID
-	_, err = firestoreClient.Collection(tokenCollection).Doc(userID).Set(ctx, token)
-	if err != nil {
-		log.Printf("Failed to save token to firestore: %v", err)
-		http.Error(w, "Failed to save token.", http.StatusInternalServerError)
+	if err := saveToken(ctx, userID, newStoredToken(token, time.Now())); err != nil {
+		logger.Error("Failed to save token", "userID", userID, "error", err)
+		renderOAuthError(w, lang, http.StatusInternalServerError, "oauth.save_failed")
 		return
 	}
+	driveServices.invalidate(userID)
+	forgetDriveUser(userID)
+	oauthEventsTotal.WithLabelValues("connect").Inc()
+
+	clearReconnecting(ctx, userID)
 
 	// 4. Link the main rich menu to the user
-	richMenuSwitcher, err := messaging_api.NewMessagingApiAPI(os.Getenv("ChannelAccessToken"))
+	richMenuSwitcher, err := messaging_api.NewMessagingApiAPI(os.Getenv("ChannelAccessToken"), messaging_api.WithHTTPClient(lineHTTPClient()))
 	if err != nil {
 		log.Printf("Failed to create messaging api client for rich menu linking: %v", err)
 	} else {
-		if _, err := richMenuSwitcher.LinkRichMenuIdToUser(userID, richMenuMain); err != nil {
+		if err := setUserMenu(richMenuSwitcher, userID, richMenuMain); err != nil {
 			log.Printf("Failed to link rich menu for user %s: %v", userID, err)
 		}
 	}
 
-	log.Printf("Successfully saved token for user %s", userID)
-	fmt.Fprintf(w, "授權成功！您現在可以回到 LINE 傳送檔案了。")
+	logger.Info("Saved OAuth token", "userID", userID)
+	renderOAuthSuccess(w, r, lang)
 }
 
-func getGoogleDriveService(userID string) (*drive.Service, error) {
-	doc, err := firestoreClient.Collection(tokenCollection).Doc(userID).Get(context.Background())
-	if err != nil {
-		if status.Code(err) == codes.NotFound {
-			return nil, ErrOauth2TokenNotFound
+// renderOAuthSuccess shows the page users land on after authorizing. It
+// redirects to SUCCESS_REDIRECT_URL or renders SUCCESS_TEMPLATE when
+// configured, and falls back to the default page in lang otherwise.
+func renderOAuthSuccess(w http.ResponseWriter, r *http.Request, lang string) {
+	if successRedirectURL != "" {
+		http.Redirect(w, r, successRedirectURL, http.StatusFound)
+		return
+	}
+	if successTemplate != nil {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		data := struct{ LineDeepLink string }{LineDeepLink: lineDeepLink()}
+		if err := successTemplate.Execute(w, data); err != nil {
+			log.Printf("Failed to render success template: %v", err)
 		}
-		return nil, fmt.Errorf("failed to get token from firestore: %w", err)
+		return
+	}
+	renderOAuthPage(w, lang, http.StatusOK, oauthPage{
+		Title:   translate(lang, "oauth.success_title"),
+		Message: translate(lang, "oauth.success"),
+		Success: true,
+	})
+}
+
+// lineDeepLink returns a link that opens the chat with the bot in the LINE
+// app, or an empty string when LINE_BOT_ID is not configured.
+func lineDeepLink() string {
+	if lineBotID == "" {
+		return ""
+	}
+	return "https://line.me/R/ti/p/" + url.PathEscape(lineBotID)
+}
+
+func getGoogleDriveService(ctx context.Context, userID string) (*drive.Service, error) {
+	return driveServices.get(ctx, userID, time.Now())
+}
+
+// newDriveService builds a Drive client that refreshes the given token as
+// needed.
+func newDriveService(ctx context.Context, token *oauth2.Token) (*drive.Service, error) {
+	return drive.NewService(ctx, option.WithTokenSource(googleOauthConfig.TokenSource(ctx, token)))
+}
+
+// validateDriveConnection performs a cheap About.Get call to confirm that
+// the user's stored token still works.
+func validateDriveConnection(ctx context.Context, userID string) error {
+	srv, err := getGoogleDriveService(ctx, userID)
+	if err != nil {
+		return err
 	}
+	_, err = srv.About.Get().Fields("user").Context(ctx).Do()
+	driveServices.invalidateOnAuthError(userID, err)
+	return err
+}
 
-	var token oauth2.Token
-	if err := doc.DataTo(&token); err != nil {
-		return nil, fmt.Errorf("failed to parse token data: %w", err)
+// getDriveServiceOrPrompt returns the user's Drive service. If the user is
+// not connected or the token is no longer valid, it replies with the
+// matching prompt and returns false.
+func getDriveServiceOrPrompt(ctx context.Context, bot botClient, replyToken, userID string) (*drive.Service, bool) {
+	srv, err := getGoogleDriveService(ctx, userID)
+	if err != nil {
+		if !replyForError(ctx, bot, replyToken, userID, err) {
+			logger.Error("Failed to get drive service", "userID", userID, "error", err)
+		}
+		return nil, false
 	}
+	return srv, true
+}
+
+// uploadOptions customizes where uploadToDrive stores a file.
+type uploadOptions struct {
+	// Folder is the name of a folder under the main folder that overrides
+	// the user's default destination.
+	Folder string
 
-	return drive.NewService(context.Background(), option.WithTokenSource(googleOauthConfig.TokenSource(context.Background(), &token)))
+	// ContentHash, when set, is checked against earlier uploads and
+	// recorded for later ones.
+	ContentHash string
+
+	// MimeType, when set, is stored as the Drive file's MIME type instead
+	// of letting Drive guess it.
+	MimeType string
 }
 
-func uploadToDrive(content io.Reader, filename string, userID string) (*drive.File, error) {
-	srv, err := getGoogleDriveService(userID)
+// uploadToDrive stores content in the user's destination folder. ctx
+// bounds the token and preference lookups as well as the upload itself.
+func uploadToDrive(ctx context.Context, content io.Reader, filename string, userID string, opts uploadOptions) (*drive.File, error) {
+	srv, err := getGoogleDriveService(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 1. Find or create the main folder "LINE Bot Uploads"
-	mainFolderID, err := findOrCreateFolder(srv, "LINE Bot Uploads", "root")
+	prefs, err := getUserPrefs(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find or create main folder: %w", err)
+		log.Printf("Failed to load prefs for user %s, using defaults: %v", userID, err)
+		prefs = &userPrefs{}
 	}
 
-	// 2. Find or create the subfolder for the current month "YYYY-MM"
-	monthFolderName := time.Now().Format("2006-01")
-	monthFolderID, err := findOrCreateFolder(srv, monthFolderName, mainFolderID)
+	// Check for a duplicate before resolving the destination, which may
+	// create folders.
+	if opts.ContentHash != "" {
+		prev, err := findPreviousUpload(ctx, srv, userID, opts.ContentHash)
+		if err != nil {
+			log.Printf("Failed to check for duplicate upload for user %s: %v", userID, err)
+		} else if prev != nil {
+			return nil, &duplicateUploadError{FileID: prev.FileID, Month: prev.Month}
+		}
+	}
+
+	folderID, err := resolveDestination(ctx, srv, prefs, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find or create month subfolder: %w", err)
+		return nil, err
 	}
 
-	// 3. Upload the file to the month-specific subfolder
+	// 3. Upload the file to the destination folder
 	file := &drive.File{
-		Name:    filename,
-		Parents: []string{monthFolderID},
+		Name:          filename,
+		Parents:       []string{folderID},
+		MimeType:      opts.MimeType,
+		Description:   renderDescription(prefs.Description, time.Now()),
+		AppProperties: map[string]string{uploadMarkerKey: "true"},
+	}
+
+	created, err := createWithRetry(ctx, srv, file, content)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ContentHash != "" {
+		if err := recordUploadHash(context.WithoutCancel(ctx), userID, opts.ContentHash, created.Id, time.Now()); err != nil {
+			log.Printf("Failed to record upload hash for user %s: %v", userID, err)
+		}
+	}
+	return created, nil
+}
+
+// resolveUploadFolder returns the user's chosen destination folder if it
+// still exists, otherwise the date subfolder under the main folder, or the
+// main folder itself when date subfolders are disabled.
+func resolveUploadFolder(ctx context.Context, srv *drive.Service, prefs *userPrefs, mainFolderID string) (string, error) {
+	if prefs.DestinationFolderID != "" {
+		folder, err := srv.Files.Get(prefs.DestinationFolderID).SupportsAllDrives(supportsAllDrives()).Fields("id, trashed").Context(ctx).Do()
+		if err == nil && !folder.Trashed {
+			return folder.Id, nil
+		}
+		log.Printf("Destination folder %s is unavailable, using default folder: %v", prefs.DestinationFolderID, err)
+	}
+
+	dateFolder := dateFolderName(time.Now())
+	if dateFolder == "" {
+		return mainFolderID, nil
+	}
+	dateFolderID, err := findOrCreateFolder(ctx, srv, dateFolder, mainFolderID)
+	if err != nil {
+		return "", fmt.Errorf("failed to find or create date subfolder: %w", err)
+	}
+	return dateFolderID, nil
+}
+
+// dateFolderName is the name of the subfolder uploads made at now go to,
+// in BOT_TIMEZONE, or "" when FOLDER_DATE_LAYOUT disables date subfolders.
+func dateFolderName(now time.Time) string {
+	if folderDateLayout == "" {
+		return ""
+	}
+	return now.In(botLocation).Format(folderDateLayout)
+}
+
+// resolveDestination returns the folder an upload goes to: the sandbox
+// folder, the folder the media type is routed to, the folder picked with
+// /choose_folder, or the current date folder under the root folder.
+func resolveDestination(ctx context.Context, srv *drive.Service, prefs *userPrefs, opts uploadOptions) (string, error) {
+	if prefs.Sandbox {
+		// Sandbox mode overrides every other destination setting.
+		folderID, err := findOrCreateFolder(ctx, srv, sandboxFolderName, "root")
+		if err != nil {
+			return "", fmt.Errorf("failed to find or create sandbox folder: %w", err)
+		}
+		return folderID, nil
+	}
+
+	// The root folder is recreated here if the user deleted it.
+	rootName := rootFolderName(prefs)
+	mainFolderID, err := findOrCreateFolder(ctx, srv, rootName, "root")
+	if err != nil {
+		return "", fmt.Errorf("failed to find or create main folder '%s': %w", rootName, err)
 	}
 
-	return srv.Files.Create(file).Media(content).Do()
+	if opts.Folder != "" {
+		folderID, err := findOrCreateFolder(ctx, srv, opts.Folder, mainFolderID)
+		if err != nil {
+			return "", fmt.Errorf("failed to find or create folder '%s': %w", opts.Folder, err)
+		}
+		return folderID, nil
+	}
+	return resolveUploadFolder(ctx, srv, prefs, mainFolderID)
 }
 
 // findOrCreateFolder searches for a folder with a given name and parent.
 // If not found, it creates the folder. It returns the folder ID.
-func findOrCreateFolder(srv *drive.Service, name string, parentID string) (string, error) {
-	query := fmt.Sprintf("mimeType='application/vnd.google-apps.folder' and trashed=false and name='%s' and '%s' in parents", name, parentID)
-	r, err := srv.Files.List().Q(query).PageSize(1).Fields("files(id)").Do()
+func findOrCreateFolder(ctx context.Context, srv *drive.Service, name string, parentID string) (string, error) {
+	folderID, err := findFolder(ctx, srv, name, parentID)
 	if err != nil {
-		return "", fmt.Errorf("failed to search for folder '%s': %w", name, err)
+		return "", err
 	}
 
-	if len(r.Files) > 0 {
+	if folderID != "" {
 		// Folder found
-		return r.Files[0].Id, nil
+		return folderID, nil
+	}
+
+	// Folder not found, create it. With Firestore available the creation
+	// is claimed first so other instances don't create it too.
+	if firestoreClient != nil {
+		return createFolderOnce(ctx, srv, name, parentID)
 	}
+	return createFolder(ctx, srv, name, parentID)
+}
+
+// folderMimeType is the MIME type Drive gives folders.
+const folderMimeType = "application/vnd.google-apps.folder"
 
-	// Folder not found, create it
+// createFolder creates a folder with the given name and parent.
+func createFolder(ctx context.Context, srv *drive.Service, name string, parentID string) (string, error) {
 	folder := &drive.File{
 		Name:     name,
-		MimeType: "application/vnd.google-apps.folder",
-		Parents:  []string{parentID},
+		MimeType: folderMimeType,
+		Parents:  []string{driveParent(parentID)},
 	}
 
-	createdFolder, err := srv.Files.Create(folder).Fields("id").Do()
+	createdFolder, err := srv.Files.Create(folder).SupportsAllDrives(supportsAllDrives()).Fields("id").Context(ctx).Do()
 	if err != nil {
 		return "", fmt.Errorf("failed to create folder '%s': %w", name, err)
 	}
@@ -574,44 +821,33 @@ func findOrCreateFolder(srv *drive.Service, name string, parentID string) (strin
 	return createdFolder.Id, nil
 }
 
-func getRecentFiles(srv *drive.Service, count int64) ([]*drive.File, error) {
-	// First, find the main folder. If it doesn't exist, there are no files to list.
-	mainFolderID, err := findOrCreateFolder(srv, "LINE Bot Uploads", "root")
+// findFolder returns the ID of the named folder under parentID, or "" if
+// there is none. Only folders the user owns in My Drive match, so a folder
+// of the same name shared by someone else is never mistaken for ours. With
+// SHARED_DRIVE_ID set, "root" is the shared drive instead.
+func findFolder(ctx context.Context, srv *drive.Service, name string, parentID string) (string, error) {
+	query := fmt.Sprintf("mimeType='application/vnd.google-apps.folder' and trashed=false and name='%s' and '%s' in parents%s", escapeQueryValue(name), driveParent(parentID), folderOwnerClause())
+	r, err := scopeFileList(srv.Files.List()).Q(query).PageSize(1).Fields("files(id)").Context(ctx).Do()
 	if err != nil {
-		// If findOrCreateFolder returns an error, we wrap it.
-		return nil, fmt.Errorf("could not find or create the main upload folder: %w", err)
+		return "", fmt.Errorf("failed to search for folder '%s': %w", name, err)
 	}
-
-	// Search for files within the main folder, ordering by creation date.
-	query := fmt.Sprintf("'%s' in parents and trashed=false", mainFolderID)
-	r, err := srv.Files.List().
-		Q(query).
-		PageSize(count).
-		OrderBy("createdTime desc").
-		Fields("files(id, name, webViewLink)").
-		Do()
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve files: %w", err)
+	if len(r.Files) == 0 {
+		return "", nil
 	}
+	return r.Files[0].Id, nil
+}
 
-	return r.Files, nil
+// escapeQueryValue escapes a string for use inside a quoted Drive query
+// value.
+func escapeQueryValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v)
 }
 
 func revokeGoogleToken(ctx context.Context, userID string) error {
 	// 1. Get token from Firestore
-	docRef := firestoreClient.Collection(tokenCollection).Doc(userID)
-	doc, err := docRef.Get(ctx)
+	token, err := loadToken(ctx, userID)
 	if err != nil {
-		if status.Code(err) == codes.NotFound {
-			return ErrOauth2TokenNotFound
-		}
-		return fmt.Errorf("failed to get token from firestore: %w", err)
-	}
-
-	var token oauth2.Token
-	if err := doc.DataTo(&token); err != nil {
-		return fmt.Errorf("failed to parse token data: %w", err)
+		return err
 	}
 
 	// Token to revoke - prefer refresh token as it invalidates all derived access tokens
@@ -620,9 +856,14 @@ func revokeGoogleToken(ctx context.Context, userID string) error {
 		tokenToRevoke = token.RefreshToken
 	}
 
-	// 2. Revoke token with Google
-	revokeURL := "https://oauth2.googleapis.com/revoke?token=" + tokenToRevoke
-	resp, err := http.Post(revokeURL, "application/x-www-form-urlencoded", nil)
+	// 2. Revoke token with Google. The token goes in the body rather than
+	// the URL so it cannot show up in a logged error.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://oauth2.googleapis.com/revoke", strings.NewReader(url.Values{"token": {tokenToRevoke}}.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to build revocation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send revocation request to google: %w", err)
 	}
@@ -635,17 +876,19 @@ func revokeGoogleToken(ctx context.Context, userID string) error {
 	}
 
 	// 3. Delete token from Firestore regardless of revocation status
-	if _, err := docRef.Delete(ctx); err != nil {
+	driveServices.invalidate(userID)
+	forgetDriveUser(userID)
+	if _, err := firestoreClient.Collection(tokenCollection).Doc(userID).Delete(ctx); err != nil {
 		log.Printf("CRITICAL: Failed to delete token for user %s from Firestore after revocation attempt: %v", userID, err)
 		return fmt.Errorf("failed to delete token from firestore: %w", err)
 	}
 
 	// 4. Link the connect rich menu back to the user
-	richMenuSwitcher, err := messaging_api.NewMessagingApiAPI(os.Getenv("ChannelAccessToken"))
+	richMenuSwitcher, err := messaging_api.NewMessagingApiAPI(os.Getenv("ChannelAccessToken"), messaging_api.WithHTTPClient(lineHTTPClient()))
 	if err != nil {
 		log.Printf("Failed to create messaging api client for rich menu linking: %v", err)
 	} else {
-		if _, err := richMenuSwitcher.LinkRichMenuIdToUser(userID, richMenuConnect); err != nil {
+		if err := setUserMenu(richMenuSwitcher, userID, richMenuConnect); err != nil {
 			log.Printf("Failed to link rich menu for user %s: %v", userID, err)
 		}
 	}
@@ -654,131 +897,493 @@ func revokeGoogleToken(ctx context.Context, userID string) error {
 	return nil
 }
 
-func handleMediaUpload(bot *messaging_api.MessagingApiAPI, blob *messaging_api.MessagingApiBlobAPI, replyToken, userID, messageID, fileName string) {
-	content, err := blob.GetMessageContent(messageID)
+func handleMediaUpload(ctx context.Context, bot botClient, blob blobClient, replyToken, userID string, msg mediaMessage) error {
+	if userID == "" {
+		replyText(bot, replyToken, tr(ctx, userID, "common.unknown_sender"))
+		return nil
+	}
+
+	if paused, notify := checkUploadsPaused(ctx, userID); paused {
+		if notify {
+			replyText(bot, replyToken, tr(ctx, userID, "upload.paused"))
+		}
+		return nil
+	}
+
+	if isManualUpload(ctx, userID) {
+		askToUpload(ctx, bot, replyToken, userID, msg)
+		return nil
+	}
+
+	return claimAndUpload(ctx, bot, blob, replyToken, userID, msg)
+}
+
+// claimAndUpload uploads msg unless another delivery of it has already
+// claimed the upload.
+func claimAndUpload(ctx context.Context, bot botClient, blob blobClient, replyToken, userID string, msg mediaMessage) error {
+	// LINE redelivers webhooks it thinks timed out, possibly while the
+	// first upload is still running.
+	if claimed, err := claimMessage(ctx, firestoreClient, msg.ID, time.Now()); err != nil {
+		if msg.Redelivered {
+			loggerFrom(ctx).Warn("Failed to claim redelivered message, skipping it", "messageID", msg.ID, "error", err)
+			return nil
+		}
+		loggerFrom(ctx).Warn("Failed to claim message, uploading anyway", "messageID", msg.ID, "error", err)
+	} else if !claimed {
+		loggerFrom(ctx).Info("Skipping message that was already uploaded", "messageID", msg.ID)
+		return nil
+	}
+
+	return uploadMedia(ctx, bot, blob, replyToken, userID, msg)
+}
+
+// errContentUnavailable marks upload failures that happened before the
+// user was told anything, because the content couldn't be read.
+var errContentUnavailable = errors.New("message content unavailable")
+
+// uploadMedia downloads a media message from LINE, uploads it to Drive and
+// replies with the result. If ctx's deadline passes first, the upload is
+// restarted in the background and its result pushed. The user can abort
+// it with /cancel. The error is non-nil when the message should be
+// handled again if LINE redelivers it.
+func uploadMedia(ctx context.Context, bot botClient, blob blobClient, replyToken, userID string, msg mediaMessage) error {
+	messageID, fileName, mediaType := msg.ID, msg.FileName, msg.Type
+	logger := loggerFrom(ctx)
+	ctx, done := activeUploads.start(ctx, userID)
+	defer done()
+	logger.Info("Upload started", "userID", userID, "messageID", messageID, "mediaType", mediaType, "external", msg.ExternalURL != "")
+	start := time.Now()
+
+	// Let the user know a large upload has started instead of leaving
+	// them waiting; the result is pushed once it's done.
+	if msg.Large && msg.Direct {
+		replyToken = acknowledgeUpload(bot, replyToken, userID, tr(ctx, userID, "upload.started"))
+	}
+
+	// Large media can take long enough to fetch that the reply token
+	// expires, so answer early and push the result instead.
+	fetch := func() (*http.Response, error) { return blob.GetMessageContent(messageID) }
+	if msg.ExternalURL != "" {
+		fetch = func() (*http.Response, error) { return fetchExternalContent(msg.ExternalURL) }
+	}
+	content, err := fetchWithTimeout(fetch, messageID, contentFetchTimeout, func() {
+		if msg.Direct {
+			replyToken = acknowledgeUpload(bot, replyToken, userID, tr(ctx, userID, "upload.processing"))
+		}
+	})
 	if err != nil {
 		log.Printf("Failed to get message content: %v", err)
-		return
+		releaseMessage(context.WithoutCancel(ctx), firestoreClient, messageID)
+		if errors.Is(err, errExternalContentBlocked) {
+			replyText(bot, replyToken, tr(ctx, userID, "upload.external_blocked"))
+			return err
+		}
+		return fmt.Errorf("%w: %w", errContentUnavailable, err)
 	}
 	defer content.Body.Close()
+	if err := checkUploadSize(content.ContentLength); err != nil {
+		logger.Warn("Upload rejected", "userID", userID, "messageID", messageID, "bytes", content.ContentLength, "error", err)
+		replyUploadTooLarge(ctx, bot, replyToken, userID)
+		return nil
+	}
+	if pastEventDeadline(ctx, nil) {
+		finishUploadInBackground(ctx, bot, blob, replyToken, userID, msg)
+		return nil
+	}
 
-	file, err := uploadToDrive(content.Body, fileName, userID)
+	limited := limitUploadBody(content.Body)
+	data, empty, err := checkEmptyContent(limited)
 	if err != nil {
-		log.Printf("Failed to upload to drive: %v", err)
-		if errors.Is(err, ErrOauth2TokenNotFound) {
-			sendConnectionPrompt(bot, replyToken)
-		} else if isGoogleAuthError(err) {
-			sendReconnectionPrompt(bot, replyToken)
+		log.Printf("Failed to read message content: %v", err)
+		releaseMessage(context.WithoutCancel(ctx), firestoreClient, messageID)
+		if !replyForError(ctx, bot, replyToken, userID, err) {
+			return fmt.Errorf("%w: %w", errContentUnavailable, err)
 		}
-		// Optionally, handle other upload errors with a generic message
-		return
+		return err
+	}
+	if empty {
+		log.Printf("Skipping empty content for message %s", messageID)
+		replyText(bot, replyToken, tr(ctx, userID, "upload.empty"))
+		return nil
+	}
+
+	if mediaType == mediaTypeFile {
+		fileName, data, err = ensureExtension(fileName, data)
+		if err != nil {
+			log.Printf("Failed to read message content: %v", err)
+			if limited.exceeded {
+				replyUploadTooLarge(ctx, bot, replyToken, userID)
+				return nil
+			}
+			return fmt.Errorf("%w: %w", errContentUnavailable, err)
+		}
+	}
+
+	opts := uploadOptions{Folder: routeFolderFor(ctx, userID, mediaType)}
+	if mediaType == mediaTypeImage {
+		// LINE images may be PNG or GIF as well as JPEG.
+		fileName, opts.MimeType, data = imageFileType(fileName, content.Header.Get("Content-Type"), data)
+	}
+	if !dedupeFits(content.ContentLength) {
+		debugf("Skipping duplicate check for message %s of %d bytes", messageID, content.ContentLength)
+	} else if isDedupeEnabled(ctx, userID) {
+		spooled, hash, err := spoolContent(data)
+		if err != nil {
+			log.Printf("Failed to read message content: %v", err)
+			if limited.exceeded {
+				replyUploadTooLarge(ctx, bot, replyToken, userID)
+				return nil
+			}
+			return fmt.Errorf("%w: %w", errContentUnavailable, err)
+		}
+		defer os.Remove(spooled.Name())
+		defer spooled.Close()
+		data, opts.ContentHash = spooled, hash
+	}
+
+	progressTo := userID
+	if msg.ChatID != "" {
+		progressTo = msg.ChatID
+	}
+	data, stopProgress := withUploadProgress(bot, progressTo, userLanguage(ctx, userID), data, content.ContentLength)
+	body := &countingReader{r: data}
+	file, err := uploadToDrive(ctx, body, sanitizeFilename(fileName), userID, opts)
+	stopProgress()
+	if err != nil {
+		if limited.exceeded {
+			logger.Warn("Upload rejected", "userID", userID, "messageID", messageID, "bytes", limited.n, "error", errUploadTooLarge)
+			recordUploadMetrics(mediaType, start, errUploadTooLarge)
+			replyUploadTooLarge(ctx, bot, replyToken, userID)
+			return nil
+		}
+		if pastEventDeadline(ctx, err) {
+			finishUploadInBackground(ctx, bot, blob, replyToken, userID, msg)
+			return nil
+		}
+		if errors.Is(ctx.Err(), context.Canceled) {
+			// /cancel already answered the user.
+			logger.Info("Upload cancelled", "userID", userID, "messageID", messageID)
+			return nil
+		}
+		driveServices.invalidateOnAuthError(userID, err)
+		var dup *duplicateUploadError
+		if errors.As(err, &dup) {
+			replyText(bot, replyToken, trf(ctx, userID, "upload.duplicate", dup.Month, driveFileURL(dup.FileID)))
+			return nil
+		}
+		logger.Error("Upload failed", "userID", userID, "messageID", messageID, "error", err)
+		recordUploadMetrics(mediaType, start, err)
+		releaseMessage(context.WithoutCancel(ctx), firestoreClient, messageID)
+		if isReconnectRace(ctx, userID, err) {
+			replyText(bot, replyToken, tr(ctx, userID, "reconnect.race"))
+			return err
+		}
+		replyForError(ctx, bot, replyToken, userID, err)
+		return err
+	}
+
+	logger.Info("Upload finished", "userID", userID, "messageID", messageID, "fileID", file.Id, "bytes", body.n)
+	recordUploadMetrics(mediaType, start, nil)
+
+	// The file is already in Drive; record it even if the event deadline
+	// passed during the upload.
+	ctx = context.WithoutCancel(ctx)
+	tip := ""
+	if text := onboardingText(ctx, userID); text != "" {
+		first, err := claimOnboarding(ctx, firestoreClient, userID)
+		if err != nil {
+			log.Printf("Failed to check onboarding for user %s: %v", userID, err)
+		} else if first {
+			tip = text
+		}
+	}
+
+	if err := recordUpload(ctx, firestoreClient, userID, body.n); err != nil {
+		log.Printf("Failed to record upload for user %s: %v", userID, err)
+	}
+	if err := rememberLastUpload(ctx, firestoreClient, userID, file.Id); err != nil {
+		log.Printf("Failed to remember upload for user %s: %v", userID, err)
 	}
 
-	sendUploadSuccessReply(bot, replyToken, file.WebViewLink)
+	if isSandboxEnabled(ctx, userID) {
+		tip = strings.TrimSpace(trf(ctx, userID, "sandbox.tip", sandboxFolderName) + "\n\n" + tip)
+	}
+	if recordBatchUpload(replyToken, file, tip) {
+		return nil
+	}
+	sendUploadSuccessReply(ctx, bot, replyToken, userID, file.WebViewLink, mediaType, tip)
+	return nil
 }
 
-func sendUploadSuccessReply(bot *messaging_api.MessagingApiAPI, replyToken, fileURL string) {
-	if _, err := bot.ReplyMessage(
-		&messaging_api.ReplyMessageRequest{
-			ReplyToken: replyToken,
-			Messages: []messaging_api.MessageInterface{
-				&messaging_api.TextMessage{
-					Text: "File uploaded to Google Drive: " + fileURL,
-					QuickReply: &messaging_api.QuickReply{
-						Items: []messaging_api.QuickReplyItem{
-							{
-								Action: &messaging_api.MessageAction{
-									Label: "查詢最近檔案",
-									Text:  "/recent_files",
-								},
-							},
-							{
-								Action: &messaging_api.MessageAction{
-									Label: "中斷連線",
-									Text:  "/disconnect_drive",
-								},
-							},
-						},
-					},
+// acknowledgeUpload answers the reply token with text and returns the token
+// to use for the upload result, which is pushed to the user. A token that
+// was already deferred is returned unchanged without sending anything, and
+// an item of an open batch is marked pending so the summary doesn't list
+// the acknowledgement as its result.
+func acknowledgeUpload(bot botClient, replyToken, userID, text string) string {
+	if strings.HasPrefix(replyToken, deferredReplyPrefix) {
+		return replyToken
+	}
+	if token, ok := markBatchItemPending(replyToken, userID); ok {
+		return token
+	}
+	replyText(bot, replyToken, text)
+	return deferredReplyToken(userID)
+}
+
+// fetchMessageContent downloads a message's content. If that takes longer
+// than timeout, onSlow is called once before continuing to wait. A zero
+// timeout disables the check.
+func fetchMessageContent(blob blobClient, messageID string, timeout time.Duration, onSlow func()) (*http.Response, error) {
+	return fetchWithTimeout(func() (*http.Response, error) { return blob.GetMessageContent(messageID) }, messageID, timeout, onSlow)
+}
+
+// fetchWithTimeout runs fetch, calling onSlow once if it takes longer than
+// timeout before continuing to wait for it.
+func fetchWithTimeout(fetch func() (*http.Response, error), messageID string, timeout time.Duration, onSlow func()) (*http.Response, error) {
+	if timeout <= 0 {
+		return fetch()
+	}
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := fetch()
+		done <- result{resp, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.resp, r.err
+	case <-timer.C:
+		debugf("Fetching content for message %s exceeded %s", messageID, timeout)
+		onSlow()
+	}
+	r := <-done
+	return r.resp, r.err
+}
+
+// checkEmptyContent peeks at the first byte of r to tell whether it holds
+// any data. The returned reader yields the full content, including the
+// peeked byte.
+func checkEmptyContent(r io.Reader) (io.Reader, bool, error) {
+	br := bufio.NewReader(r)
+	if _, err := br.Peek(1); err != nil {
+		if err == io.EOF {
+			return br, true, nil
+		}
+		return nil, false, err
+	}
+	return br, false, nil
+}
+
+// onboardingText is the tip added to the user's first upload: the
+// ONBOARDING_TIP override when set, otherwise the catalog message in the
+// user's language. It is "" when ONBOARDING_TIP turned the tip off.
+func onboardingText(ctx context.Context, userID string) string {
+	if onboardingTipSet {
+		return withCommandPrefix(onboardingTip)
+	}
+	return tr(ctx, userID, "upload.onboarding")
+}
+
+// mediaMessage identifies the content of a media message to upload.
+type mediaMessage struct {
+	ID       string
+	FileName string
+	Type     string
+
+	// ExternalURL is set for content hosted outside LINE; it is fetched
+	// instead of the LINE content API.
+	ExternalURL string
+
+	// Large marks media known to be big from the webhook, so the upload
+	// is acknowledged right away and its result pushed.
+	Large bool
+
+	// Direct is set for one-to-one chats, where results can be pushed.
+	Direct bool
+
+	// ChatID is the group or room the message was sent in, and "" in
+	// one-to-one chats. Progress updates are pushed there.
+	ChatID string
+
+	// Redelivered is set when LINE resent the webhook, so an earlier
+	// delivery may already have uploaded the message.
+	Redelivered bool
+}
+
+// Thresholds above which a media upload counts as large.
+const (
+	largeMediaBytes    = 20 << 20
+	largeVideoDuration = 30 * time.Second
+)
+
+// Media types passed to handleMediaUpload, matching the LINE message types.
+const (
+	mediaTypeImage = "image"
+	mediaTypeVideo = "video"
+	mediaTypeAudio = "audio"
+	mediaTypeFile  = "file"
+)
+
+// uploadIcon returns the emoji prefix for an upload confirmation, or an
+// empty string when icons are disabled.
+func uploadIcon(mediaType string) string {
+	if !uploadIcons {
+		return ""
+	}
+	switch mediaType {
+	case mediaTypeImage:
+		return "🖼 "
+	case mediaTypeVideo:
+		return "🎬 "
+	case mediaTypeAudio:
+		return "🎵 "
+	default:
+		return "📄 "
+	}
+}
+
+// sendUploadSuccessReply confirms an upload. A non-empty tip is sent as a
+// second message.
+func sendUploadSuccessReply(ctx context.Context, bot botClient, replyToken, userID, fileURL, mediaType, tip string) {
+	quickReply := &messaging_api.QuickReply{
+		Items: []messaging_api.QuickReplyItem{
+			{
+				Action: &messaging_api.MessageAction{
+					Label: tr(ctx, userID, "label.recent_files"),
+					Text:  commandText("recent_files"),
+				},
+			},
+			{
+				Action: &messaging_api.MessageAction{
+					Label: tr(ctx, userID, "label.disconnect"),
+					Text:  commandText("disconnect_drive"),
 				},
 			},
 		},
-	); err != nil {
+	}
+	// LINE only shows the quick reply of the last message.
+	messages := []messaging_api.MessageInterface{
+		&messaging_api.TextMessage{
+			Text:       uploadIcon(mediaType) + trf(ctx, userID, "upload.success", fileURL),
+			QuickReply: quickReply,
+		},
+	}
+	if tip != "" {
+		messages[0].(*messaging_api.TextMessage).QuickReply = nil
+		messages = append(messages, &messaging_api.TextMessage{Text: tip, QuickReply: quickReply})
+	}
+	if err := sendReply(bot, replyToken, messages); err != nil {
 		log.Print(err)
 	}
 }
 
-func sendConnectionPrompt(bot *messaging_api.MessagingApiAPI, replyToken string) {
-	if _, err := bot.ReplyMessage(
-		&messaging_api.ReplyMessageRequest{
-			ReplyToken: replyToken,
-			Messages: []messaging_api.MessageInterface{
-				&messaging_api.TextMessage{
-					Text: "Please connect your Google Drive account first.",
-					QuickReply: &messaging_api.QuickReply{
-						Items: []messaging_api.QuickReplyItem{
-							{
-								Action: &messaging_api.MessageAction{
-									Label: "Connect Google Drive",
-									Text:  "/connect_drive",
-								},
-							},
+// deferredReplyPrefix marks a reply token that was already spent on an
+// interim reply. Replies sent with it are pushed to the user instead.
+const deferredReplyPrefix = "push:"
+
+// deferredReplyToken returns a token that makes the reply helpers push to
+// userID, for handlers that answered early and keep working.
+func deferredReplyToken(userID string) string {
+	return deferredReplyPrefix + userID
+}
+
+// sendReply replies with messages, or pushes them for a token made by
+// deferredReplyToken.
+func sendReply(bot botClient, replyToken string, messages []messaging_api.MessageInterface) error {
+	if strings.HasPrefix(replyToken, batchReplyPrefix) {
+		if replyToken = collectBatchReply(replyToken, messages); replyToken == "" {
+			return nil
+		}
+	}
+	if userID, ok := strings.CutPrefix(replyToken, deferredReplyPrefix); ok {
+		_, err := bot.PushMessage(&messaging_api.PushMessageRequest{To: userID, Messages: messages}, "")
+		return err
+	}
+	_, err := bot.ReplyMessage(&messaging_api.ReplyMessageRequest{ReplyToken: replyToken, Messages: messages})
+	return err
+}
+
+// replyText sends a plain text reply.
+func replyText(bot botClient, replyToken, text string) {
+	if err := sendReply(bot, replyToken, []messaging_api.MessageInterface{
+		&messaging_api.TextMessage{
+			Text: text,
+		},
+	}); err != nil {
+		log.Print(err)
+	}
+}
+
+func sendConnectionPrompt(ctx context.Context, bot botClient, replyToken, userID string) {
+	if err := sendReply(bot, replyToken, []messaging_api.MessageInterface{
+		&messaging_api.TextMessage{
+			Text: tr(ctx, userID, "connect.prompt"),
+			QuickReply: &messaging_api.QuickReply{
+				Items: []messaging_api.QuickReplyItem{
+					{
+						Action: &messaging_api.MessageAction{
+							Label: tr(ctx, userID, "connect.button"),
+							Text:  commandText("connect_drive"),
 						},
 					},
 				},
 			},
 		},
-	); err != nil {
+	}); err != nil {
 		log.Print(err)
 	}
 }
 
-// isGoogleAuthError checks if the error from a Google API call is due to
-// an authentication/authorization issue (e.g., expired or revoked token).
-func isGoogleAuthError(err error) bool {
-	var apiErr *googleapi.Error
-	if errors.As(err, &apiErr) {
-		// 401 Unauthorized or 403 Forbidden are strong indicators of a token issue.
-		return apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden
-	}
-
-	// The oauth2 library can return an error containing "invalid_grant"
-	// when the refresh token is expired, revoked, or otherwise invalid.
-	if err != nil {
-		errorStr := err.Error()
-		// Basic substring check to avoid importing "strings"
-		for i := 0; i <= len(errorStr)-13; i++ {
-			if errorStr[i:i+13] == "invalid_grant" {
-				return true
-			}
-		}
+func sendReconnectionPrompt(ctx context.Context, bot botClient, replyToken, userID string) {
+	if err := sendReply(bot, replyToken, []messaging_api.MessageInterface{
+		reconnectionMessage(ctx, userID),
+	}); err != nil {
+		log.Print(err)
 	}
-
-	return false
 }
 
-func sendReconnectionPrompt(bot *messaging_api.MessagingApiAPI, replyToken string) {
-	message := "您的 Google Drive 授權似乎已失效。\n請執行 /reconnect 指令來重新連線。"
-	if _, err := bot.ReplyMessage(
-		&messaging_api.ReplyMessageRequest{
-			ReplyToken: replyToken,
+// pushReconnectionPrompt sends the reconnection prompt outside of a reply
+// context, e.g. from a scheduled token check.
+func pushReconnectionPrompt(ctx context.Context, bot botClient, userID string) error {
+	_, err := bot.PushMessage(
+		&messaging_api.PushMessageRequest{
+			To: userID,
 			Messages: []messaging_api.MessageInterface{
-				&messaging_api.TextMessage{
-					Text: message,
-					QuickReply: &messaging_api.QuickReply{
-						Items: []messaging_api.QuickReplyItem{
-							{
-								Action: &messaging_api.MessageAction{
-									Label: "重新連線",
-									Text:  "/reconnect",
-								},
-							},
-						},
+				reconnectionMessage(ctx, userID),
+			},
+		},
+		"",
+	)
+	return err
+}
+
+// reconnectionMessage builds the reconnection prompt in the user's
+// language, or from RECONNECT_MESSAGE when set. The "{command}"
+// placeholder in the message is replaced by the command.
+func reconnectionMessage(ctx context.Context, userID string) *messaging_api.TextMessage {
+	text := reconnectMessage
+	if text == "" {
+		text = tr(ctx, userID, "reconnect.message")
+	}
+	return &messaging_api.TextMessage{
+		Text: strings.ReplaceAll(text, "{command}", reconnectCommand),
+		QuickReply: &messaging_api.QuickReply{
+			Items: []messaging_api.QuickReplyItem{
+				{
+					Action: &messaging_api.MessageAction{
+						Label: tr(ctx, userID, "reconnect.button"),
+						Text:  reconnectCommand,
 					},
 				},
 			},
 		},
-	); err != nil {
-		log.Print(err)
 	}
 }
@@ -3,10 +3,16 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"html/template"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"golang.org/x/oauth2"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/option"
 )
@@ -40,7 +46,7 @@ func TestFindOrCreateFolder(t *testing.T) {
 	}
 
 	// Run the function
-	folderID, err := findOrCreateFolder(driveService, "Test Folder", "root")
+	folderID, err := findOrCreateFolder(context.Background(), driveService, "Test Folder", "root")
 	if err != nil {
 		t.Errorf("Expected no error, but got: %v", err)
 	}
@@ -75,7 +81,7 @@ func TestFindOrCreateFolder(t *testing.T) {
 	}
 
 	// Run the function
-	folderID2, err := findOrCreateFolder(driveService2, "New Folder", "root")
+	folderID2, err := findOrCreateFolder(context.Background(), driveService2, "New Folder", "root")
 	if err != nil {
 		t.Errorf("Expected no error, but got: %v", err)
 	}
@@ -86,3 +92,329 @@ func TestFindOrCreateFolder(t *testing.T) {
 		t.Errorf("Expected folder ID 'new_folder_id', but got: '%s'", folderID2)
 	}
 }
+
+// TestCheckEmptyContent tests that empty readers are detected and that
+// non-empty readers keep their full content.
+func TestCheckEmptyContent(t *testing.T) {
+	_, empty, err := checkEmptyContent(strings.NewReader(""))
+	if err != nil {
+		t.Errorf("Expected no error, but got: %v", err)
+	}
+	if !empty {
+		t.Error("Expected empty reader to be reported as empty")
+	}
+
+	r, empty, err := checkEmptyContent(strings.NewReader("hello"))
+	if err != nil {
+		t.Errorf("Expected no error, but got: %v", err)
+	}
+	if empty {
+		t.Error("Expected non-empty reader to be reported as non-empty")
+	}
+	b, _ := io.ReadAll(r)
+	if string(b) != "hello" {
+		t.Errorf("Expected content 'hello', but got: '%s'", b)
+	}
+}
+
+// TestAuthCodeURL tests that the consent prompt is only forced on request.
+func TestAuthCodeURL(t *testing.T) {
+	googleOauthConfig = &oauth2.Config{
+		ClientID: "client",
+		Endpoint: oauth2.Endpoint{AuthURL: "https://example.com/auth"},
+	}
+
+	url := authCodeURL("example.com", "state", false)
+	if !strings.Contains(url, "access_type=offline") {
+		t.Errorf("Expected offline access in URL, got: %s", url)
+	}
+	if strings.Contains(url, "prompt=consent") {
+		t.Errorf("Expected no forced consent in URL, got: %s", url)
+	}
+
+	url = authCodeURL("example.com", "state", true)
+	if !strings.Contains(url, "prompt=consent") {
+		t.Errorf("Expected forced consent in URL, got: %s", url)
+	}
+}
+
+// TestUploadIcon tests the per-type icons and disabling them.
+func TestUploadIcon(t *testing.T) {
+	uploadIcons = true
+	if got := uploadIcon(mediaTypeVideo); got != "🎬 " {
+		t.Errorf("Expected video icon, but got: '%s'", got)
+	}
+	if got := uploadIcon(mediaTypeFile); got != "📄 " {
+		t.Errorf("Expected file icon, but got: '%s'", got)
+	}
+
+	uploadIcons = false
+	defer func() { uploadIcons = true }()
+	if got := uploadIcon(mediaTypeImage); got != "" {
+		t.Errorf("Expected no icon when disabled, but got: '%s'", got)
+	}
+}
+
+// TestOauthConfigForHost tests that the redirect URL matching the request
+// host is chosen, without mutating the shared config.
+func TestOauthConfigForHost(t *testing.T) {
+	googleOauthConfig = &oauth2.Config{RedirectURL: "https://prod.example.com/oauth/callback"}
+	oauthRedirectURLs = []string{
+		"https://prod.example.com/oauth/callback",
+		"https://staging.example.com/oauth/callback",
+	}
+	defer func() { oauthRedirectURLs = nil }()
+
+	cfg := oauthConfigForHost("staging.example.com")
+	if cfg.RedirectURL != "https://staging.example.com/oauth/callback" {
+		t.Errorf("Expected staging redirect URL, but got: '%s'", cfg.RedirectURL)
+	}
+	if googleOauthConfig.RedirectURL != "https://prod.example.com/oauth/callback" {
+		t.Errorf("Expected shared config to be unchanged, but got: '%s'", googleOauthConfig.RedirectURL)
+	}
+
+	cfg = oauthConfigForHost("unknown.example.com")
+	if cfg.RedirectURL != "https://prod.example.com/oauth/callback" {
+		t.Errorf("Expected default redirect URL, but got: '%s'", cfg.RedirectURL)
+	}
+}
+
+// TestOauthConfigForState tests that the redirect URL saved with the state
+// is used for the exchange.
+func TestOauthConfigForState(t *testing.T) {
+	googleOauthConfig = &oauth2.Config{RedirectURL: "https://new.example.com/oauth/callback"}
+
+	cfg := oauthConfigForState("new.example.com", "https://old.example.com/oauth/callback")
+	if cfg.RedirectURL != "https://old.example.com/oauth/callback" {
+		t.Errorf("Expected saved redirect URL, but got: '%s'", cfg.RedirectURL)
+	}
+
+	cfg = oauthConfigForState("new.example.com", "")
+	if cfg.RedirectURL != "https://new.example.com/oauth/callback" {
+		t.Errorf("Expected current redirect URL, but got: '%s'", cfg.RedirectURL)
+	}
+}
+
+// TestRenderOAuthSuccess tests the configurable success page.
+func TestRenderOAuthSuccess(t *testing.T) {
+	defer func() {
+		successRedirectURL = ""
+		successTemplate = nil
+		lineBotID = ""
+	}()
+
+	rec := httptest.NewRecorder()
+	renderOAuthSuccess(rec, httptest.NewRequest("GET", "/oauth/callback", nil), langZh)
+	if !strings.Contains(rec.Body.String(), "授權成功") {
+		t.Errorf("Expected default message, but got: '%s'", rec.Body.String())
+	}
+
+	lineBotID = "@linebot"
+	successTemplate = template.Must(template.New("success").Parse(`<a href="{{.LineDeepLink}}">Back</a>`))
+	rec = httptest.NewRecorder()
+	renderOAuthSuccess(rec, httptest.NewRequest("GET", "/oauth/callback", nil), langZh)
+	if !strings.Contains(rec.Body.String(), "https://line.me/R/ti/p/@linebot") {
+		t.Errorf("Expected deep link in template, but got: '%s'", rec.Body.String())
+	}
+
+	successRedirectURL = "https://example.com/success"
+	rec = httptest.NewRecorder()
+	renderOAuthSuccess(rec, httptest.NewRequest("GET", "/oauth/callback", nil), langZh)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != successRedirectURL {
+		t.Errorf("Expected redirect to '%s', but got: %d '%s'", successRedirectURL, rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+// TestEscapeQueryValue tests escaping of Drive query values.
+func TestEscapeQueryValue(t *testing.T) {
+	if got := escapeQueryValue(`Bob's \ files`); got != `Bob\'s \\ files` {
+		t.Errorf("Unexpected escaped value: '%s'", got)
+	}
+}
+
+// TestFetchMessageContent tests that onSlow fires only when the content
+// download exceeds the timeout, and that the content is still returned.
+func TestFetchMessageContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "slow") {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	blob, err := messaging_api.NewMessagingApiBlobAPI("token", messaging_api.WithBlobEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create blob client: %v", err)
+	}
+
+	for _, tt := range []struct {
+		messageID string
+		wantSlow  bool
+	}{
+		{"fast", false},
+		{"slow", true},
+	} {
+		slow := false
+		resp, err := fetchMessageContent(blob, tt.messageID, 20*time.Millisecond, func() { slow = true })
+		if err != nil {
+			t.Fatalf("%s: expected no error, but got: %v", tt.messageID, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "content" {
+			t.Errorf("%s: expected 'content', but got: '%s'", tt.messageID, body)
+		}
+		if slow != tt.wantSlow {
+			t.Errorf("%s: expected onSlow called %v, but got: %v", tt.messageID, tt.wantSlow, slow)
+		}
+	}
+}
+
+// TestAcknowledgeUpload tests that a large upload is acknowledged with the
+// reply token once and its result is pushed afterwards.
+func TestAcknowledgeUpload(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	bot, err := messaging_api.NewMessagingApiAPI("token", messaging_api.WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create mock bot: %v", err)
+	}
+
+	token := acknowledgeUpload(bot, "reply-token", "U1", "上傳中…")
+	if token != deferredReplyToken("U1") {
+		t.Errorf("Expected a deferred token, but got: %s", token)
+	}
+	if again := acknowledgeUpload(bot, token, "U1", "處理中…"); again != token {
+		t.Errorf("Expected the deferred token to be kept, but got: %s", again)
+	}
+	replyText(bot, token, "done")
+
+	want := []string{"/v2/bot/message/reply", "/v2/bot/message/push"}
+	if len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("Expected %v, but got: %v", want, paths)
+	}
+}
+
+// TestFindOrCreateFolderIgnoresSharedFolder tests that a folder named like
+// ours but owned by someone else is ignored and our own folder is created.
+func TestFindOrCreateFolderIgnoresSharedFolder(t *testing.T) {
+	var created bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("supportsAllDrives") != "false" {
+			t.Errorf("Expected supportsAllDrives=false, but got: %q", r.URL.Query().Get("supportsAllDrives"))
+		}
+		if r.Method == "GET" && r.URL.Path == "/files" {
+			// Only the shared folder exists; Drive returns it unless the
+			// query is restricted to files the user owns.
+			files := []*drive.File{}
+			if !strings.Contains(r.URL.Query().Get("q"), "'me' in owners") {
+				files = append(files, &drive.File{Id: "shared_folder_id", Name: mainFolderName})
+			}
+			json.NewEncoder(w).Encode(&drive.FileList{Files: files})
+			return
+		}
+		if r.Method == "POST" && r.URL.Path == "/files" {
+			created = true
+			json.NewEncoder(w).Encode(&drive.File{Id: "own_folder_id"})
+			return
+		}
+		t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	folderID, err := findOrCreateFolder(context.Background(), srv, mainFolderName, "root")
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if !created || folderID != "own_folder_id" {
+		t.Errorf("Expected our own folder to be created, but got created=%v id=%q", created, folderID)
+	}
+}
+
+// TestResolveUploadFolderWithoutDateFolders tests that uploads go straight
+// to the main folder when FOLDER_DATE_LAYOUT is empty.
+func TestResolveUploadFolderWithoutDateFolders(t *testing.T) {
+	original := folderDateLayout
+	folderDateLayout = ""
+	defer func() { folderDateLayout = original }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+	}))
+	defer server.Close()
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	folderID, err := resolveUploadFolder(context.Background(), srv, &userPrefs{}, "main_folder_id")
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if folderID != "main_folder_id" {
+		t.Errorf("Expected the main folder, but got: %q", folderID)
+	}
+}
+
+// TestDateFolderName tests the supported layouts and the fallback for
+// invalid ones.
+func TestDateFolderName(t *testing.T) {
+	original := folderDateLayout
+	defer func() { folderDateLayout = original }()
+
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		layout, want string
+	}{
+		{"2006-01", "2024-03"},
+		{"2006-01-02", "2024-03-05"},
+		{"2006", "2024"},
+		{"", ""},
+		{"01/02/2006", "2024-03"},
+		{"Jan 2006", "2024-03"},
+	}
+	for _, tt := range tests {
+		folderDateLayout = parseFolderDateLayout(tt.layout)
+		if got := dateFolderName(now); got != tt.want {
+			t.Errorf("Layout %q: expected %q, but got: %q", tt.layout, tt.want, got)
+		}
+	}
+}
+
+// TestDateFolderNameTimezone tests that an instant near a month boundary
+// lands in the month of BOT_TIMEZONE.
+func TestDateFolderNameTimezone(t *testing.T) {
+	originalLayout, originalLocation := folderDateLayout, botLocation
+	defer func() { folderDateLayout, botLocation = originalLayout, originalLocation }()
+	folderDateLayout = defaultFolderDateLayout
+
+	// 2024-03-31 17:30 UTC is already April 1st in Taipei.
+	now := time.Date(2024, 3, 31, 17, 30, 0, 0, time.UTC)
+	tests := []struct {
+		zone, want string
+	}{
+		{"", "2024-03"},
+		{"UTC", "2024-03"},
+		{"Asia/Taipei", "2024-04"},
+		{"America/Los_Angeles", "2024-03"},
+		{"Not/AZone", "2024-03"},
+	}
+	for _, tt := range tests {
+		botLocation = parseBotTimezone(tt.zone)
+		if got := dateFolderName(now); got != tt.want {
+			t.Errorf("Zone %q: expected %q, but got: %q", tt.zone, tt.want, got)
+		}
+	}
+}
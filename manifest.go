@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// manifestFolderName keeps manifests out of the month folders they
+// describe, so they don't show up in later manifests.
+const manifestFolderName = "Manifests"
+
+// handleManifestCommand writes a CSV listing of a month's uploads to Drive.
+// The month defaults to the current one.
+func handleManifestCommand(ctx context.Context, bot botClient, replyToken, userID string, args []string) {
+	month := time.Now().In(botLocation).Format("2006-01")
+	if len(args) > 0 {
+		if _, err := time.Parse("2006-01", args[0]); err != nil {
+			replyText(bot, replyToken, tr(ctx, userID, "manifest.usage"))
+			return
+		}
+		month = args[0]
+	}
+
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	files, err := listMonthFiles(ctx, srv, userRootFolderName(ctx, userID), month)
+	if err != nil {
+		log.Printf("Failed to list files for manifest: %v", err)
+		replyForError(ctx, bot, replyToken, userID, err)
+		return
+	}
+	if len(files) == 0 {
+		replyText(bot, replyToken, trf(ctx, userID, "manifest.none", month))
+		return
+	}
+
+	data, err := buildManifestCSV(files)
+	if err != nil {
+		log.Printf("Failed to build manifest: %v", err)
+		replyText(bot, replyToken, tr(ctx, userID, "manifest.build_failed"))
+		return
+	}
+
+	file, err := uploadToDrive(ctx, bytes.NewReader(data), "manifest-"+month+".csv", userID, uploadOptions{Folder: manifestFolderName})
+	if err != nil {
+		log.Printf("Failed to upload manifest: %v", err)
+		if !replyForError(ctx, bot, replyToken, userID, err) {
+			replyText(bot, replyToken, tr(ctx, userID, "manifest.upload_failed"))
+		}
+		return
+	}
+	replyText(bot, replyToken, trf(ctx, userID, "manifest.done", len(files), month, file.WebViewLink))
+}
+
+// listMonthFiles returns every file in the given month folder, following
+// all result pages. A missing folder yields no files.
+func listMonthFiles(ctx context.Context, srv *drive.Service, rootName, month string) ([]*drive.File, error) {
+	mainFolderID, err := findFolder(ctx, srv, rootName, "root")
+	if err != nil || mainFolderID == "" {
+		return nil, err
+	}
+	monthFolderID, err := findFolder(ctx, srv, month, mainFolderID)
+	if err != nil || monthFolderID == "" {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("'%s' in parents and trashed=false", monthFolderID)
+	var files []*drive.File
+	pageToken := ""
+	for {
+		call := scopeFileList(srv.Files.List()).Q(query).PageSize(1000).OrderBy("createdTime").
+			Fields("nextPageToken, files(name, createdTime, size, webViewLink)")
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		r, err := call.Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files in '%s': %w", month, err)
+		}
+		files = append(files, r.Files...)
+		if r.NextPageToken == "" {
+			return files, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// buildManifestCSV renders files as CSV with a header row.
+func buildManifestCSV(files []*drive.File) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"filename", "created_time", "size", "link"})
+	for _, f := range files {
+		w.Write([]string{f.Name, f.CreatedTime, strconv.FormatInt(f.Size, 10), f.WebViewLink})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// TestBuildManifestCSV tests the header and quoting of file names.
+func TestBuildManifestCSV(t *testing.T) {
+	data, err := buildManifestCSV([]*drive.File{
+		{Name: "a,b.jpg", CreatedTime: "2024-03-01T10:00:00Z", Size: 42, WebViewLink: "https://drive/a"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	want := "filename,created_time,size,link\n\"a,b.jpg\",2024-03-01T10:00:00Z,42,https://drive/a\n"
+	if string(data) != want {
+		t.Errorf("Expected %q, but got: %q", want, data)
+	}
+}
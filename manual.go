@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+)
+
+// Conversation data keys for a media message awaiting confirmation.
+const (
+	pendingMessageID = "message_id"
+	pendingFileName  = "file_name"
+	pendingMediaType = "media_type"
+	pendingURL       = "external_url"
+	pendingChatID    = "chat_id"
+	pendingDirect    = "direct"
+	pendingLarge     = "large"
+)
+
+// handleManualCommand switches between automatic uploads and uploads that
+// wait for the user's confirmation.
+func handleManualCommand(ctx context.Context, bot botClient, replyToken, userID string, args []string) {
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		replyText(bot, replyToken, tr(ctx, userID, "manual.usage"))
+		return
+	}
+	manual := args[0] == "on"
+	if err := updateUserPrefs(ctx, userID, map[string]interface{}{"manual_upload": manual}); err != nil {
+		log.Printf("Failed to set manual mode for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "error.save_setting"))
+		return
+	}
+	if manual {
+		replyText(bot, replyToken, tr(ctx, userID, "manual.on"))
+	} else {
+		replyText(bot, replyToken, tr(ctx, userID, "manual.off"))
+	}
+}
+
+// isManualUpload reports whether the user asked to confirm each upload.
+func isManualUpload(ctx context.Context, userID string) bool {
+	prefs, err := getUserPrefs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load prefs for user %s, uploading automatically: %v", userID, err)
+		return false
+	}
+	return prefs.ManualUpload
+}
+
+// askToUpload remembers the media message and asks the user to confirm.
+// Only the latest message is kept; sending another file replaces it.
+func askToUpload(ctx context.Context, bot botClient, replyToken, userID string, msg mediaMessage) {
+	err := setConversationState(ctx, userID, stateAwaitingUploadConfirm, map[string]string{
+		pendingMessageID: msg.ID,
+		pendingFileName:  msg.FileName,
+		pendingMediaType: msg.Type,
+		pendingURL:       msg.ExternalURL,
+		pendingChatID:    msg.ChatID,
+		pendingDirect:    strconv.FormatBool(msg.Direct),
+		pendingLarge:     strconv.FormatBool(msg.Large),
+	})
+	if err != nil {
+		log.Printf("Failed to save pending upload for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "manual.save_failed"))
+		return
+	}
+
+	if err := sendReply(bot, replyToken, []messaging_api.MessageInterface{
+		&messaging_api.TextMessage{
+			Text: tr(ctx, userID, "manual.ask"),
+			QuickReply: &messaging_api.QuickReply{
+				Items: []messaging_api.QuickReplyItem{
+					{Action: &messaging_api.MessageAction{Label: tr(ctx, userID, "manual.confirm"), Text: tr(ctx, userID, "manual.confirm")}},
+					{Action: &messaging_api.MessageAction{Label: tr(ctx, userID, "manual.decline"), Text: tr(ctx, userID, "manual.decline")}},
+				},
+			},
+		},
+	}); err != nil {
+		log.Print(err)
+	}
+}
+
+// confirmPendingUpload uploads the message saved by askToUpload if the
+// user confirmed it. The upload goes through the same pause check and
+// claim as an automatic one.
+func confirmPendingUpload(ctx context.Context, bot botClient, blob blobClient, replyToken, userID, text string, data map[string]string) {
+	if !isCatalogText("manual.confirm", text) {
+		replyText(bot, replyToken, tr(ctx, userID, "manual.declined"))
+		return
+	}
+	if paused, _ := checkUploadsPaused(ctx, userID); paused {
+		replyText(bot, replyToken, tr(ctx, userID, "upload.paused"))
+		return
+	}
+
+	direct, _ := strconv.ParseBool(data[pendingDirect])
+	large, _ := strconv.ParseBool(data[pendingLarge])
+	err := claimAndUpload(ctx, bot, blob, replyToken, userID, mediaMessage{
+		ID:          data[pendingMessageID],
+		FileName:    data[pendingFileName],
+		Type:        data[pendingMediaType],
+		ExternalURL: data[pendingURL],
+		ChatID:      data[pendingChatID],
+		Direct:      direct,
+		Large:       large,
+	})
+	if err != nil {
+		log.Printf("Failed to upload confirmed message %s for user %s: %v", data[pendingMessageID], userID, err)
+		// Other failures were already reported by uploadMedia.
+		if errors.Is(err, errContentUnavailable) {
+			replyText(bot, replyToken, tr(ctx, userID, "manual.unavailable"))
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+)
+
+// chatMembersCollection records which members joined a group or room while
+// the bot was in it, keyed by chatMemberDocID.
+const chatMembersCollection = "chat_members"
+
+// Values for MEMBER_LEFT_ACTION.
+const (
+	memberLeftLog     = "log"
+	memberLeftNotify  = "notify"
+	memberLeftCleanup = "cleanup"
+)
+
+func chatMemberDocID(chatID, userID string) string {
+	return chatID + "_" + userID
+}
+
+// chatID returns the group or room ID of an event source.
+func chatID(src webhook.SourceInterface) (string, bool) {
+	switch s := src.(type) {
+	case webhook.GroupSource:
+		return s.GroupId, true
+	case webhook.RoomSource:
+		return s.RoomId, true
+	}
+	return "", false
+}
+
+// handleMemberJoined records new members when cleanup is enabled, so
+// there is something to clean up when they leave.
+func handleMemberJoined(ctx context.Context, e webhook.MemberJoinedEvent) {
+	id, ok := chatID(e.Source)
+	if !ok || e.Joined == nil {
+		return
+	}
+	for _, m := range e.Joined.Members {
+		log.Printf("Member joined: chat_id=%s user_id=%s", id, m.UserId)
+		if memberLeftAction != memberLeftCleanup {
+			continue
+		}
+		if _, err := firestoreClient.Collection(chatMembersCollection).Doc(chatMemberDocID(id, m.UserId)).Set(ctx, map[string]interface{}{
+			"chat_id":   id,
+			"user_id":   m.UserId,
+			"joined_at": time.Now(),
+		}); err != nil {
+			log.Printf("Failed to record member %s in chat %s: %v", m.UserId, id, err)
+		}
+	}
+}
+
+// handleMemberLeft applies MEMBER_LEFT_ACTION for members leaving a group
+// or room: log only, notify the chat, or delete the member's chat record.
+func handleMemberLeft(ctx context.Context, bot botClient, e webhook.MemberLeftEvent) {
+	id, ok := chatID(e.Source)
+	if !ok || e.Left == nil {
+		return
+	}
+	for _, m := range e.Left.Members {
+		log.Printf("Member left: chat_id=%s user_id=%s action=%s", id, m.UserId, memberLeftAction)
+		switch memberLeftAction {
+		case memberLeftCleanup:
+			if _, err := firestoreClient.Collection(chatMembersCollection).Doc(chatMemberDocID(id, m.UserId)).Delete(ctx); err != nil {
+				log.Printf("Failed to clean up member %s in chat %s: %v", m.UserId, id, err)
+			}
+		case memberLeftNotify:
+			// The reply token isn't available for leave events, so push.
+			if _, err := bot.PushMessage(&messaging_api.PushMessageRequest{
+				To:       id,
+				Messages: []messaging_api.MessageInterface{&messaging_api.TextMessage{Text: tr(ctx, "", "members.left")}},
+			}, ""); err != nil {
+				log.Printf("Failed to notify chat %s: %v", id, err)
+			}
+		}
+	}
+}
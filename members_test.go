@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+)
+
+// TestChatID tests that groups and rooms are both recognized.
+func TestChatID(t *testing.T) {
+	tests := []struct {
+		src    webhook.SourceInterface
+		want   string
+		wantOK bool
+	}{
+		{webhook.GroupSource{GroupId: "G1"}, "G1", true},
+		{webhook.RoomSource{RoomId: "R1"}, "R1", true},
+		{webhook.UserSource{UserId: "U1"}, "", false},
+	}
+	for _, tt := range tests {
+		id, ok := chatID(tt.src)
+		if id != tt.want || ok != tt.wantOK {
+			t.Errorf("%T: expected (%q, %v), but got: (%q, %v)", tt.src, tt.want, tt.wantOK, id, ok)
+		}
+	}
+}
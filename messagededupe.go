@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// processedMessageCollection holds a claim per media message ID, taken
+// before the upload starts. Unlike processed_events, which is written
+// after an event was handled, it also stops a redelivery that arrives
+// while the first upload is still running. Documents carry expires_at for
+// a Firestore TTL policy.
+const processedMessageCollection = "processed_messages"
+
+// processedMessageTTL is how long a message ID stays claimed.
+const processedMessageTTL = 24 * time.Hour
+
+type processedMessage struct {
+	ExpiresAt time.Time `firestore:"expires_at"`
+}
+
+// claimMessage records that messageID is being uploaded. It returns false
+// when an unexpired claim already exists, i.e. the message was delivered
+// before and must not be uploaded again.
+func claimMessage(ctx context.Context, client *firestore.Client, messageID string, now time.Time) (bool, error) {
+	ref := client.Collection(processedMessageCollection).Doc(messageID)
+	_, err := ref.Create(ctx, processedMessage{ExpiresAt: now.Add(processedMessageTTL)})
+	if err == nil {
+		return true, nil
+	}
+	if status.Code(err) != codes.AlreadyExists {
+		return false, fmt.Errorf("failed to claim message: %w", err)
+	}
+
+	// Firestore TTL deletion can lag, so an expired claim may still be
+	// around. Take it over unless another delivery just did.
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get message claim: %w", err)
+	}
+	var pm processedMessage
+	if err := doc.DataTo(&pm); err != nil {
+		return false, fmt.Errorf("failed to parse message claim: %w", err)
+	}
+	if now.Before(pm.ExpiresAt) {
+		return false, nil
+	}
+	_, err = ref.Update(ctx, []firestore.Update{{Path: "expires_at", Value: now.Add(processedMessageTTL)}}, firestore.LastUpdateTime(doc.UpdateTime))
+	if status.Code(err) == codes.FailedPrecondition {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to renew message claim: %w", err)
+	}
+	return true, nil
+}
+
+// releaseMessage drops the claim after a failed upload so a redelivery of
+// the message can try again.
+func releaseMessage(ctx context.Context, client *firestore.Client, messageID string) {
+	if _, err := client.Collection(processedMessageCollection).Doc(messageID).Delete(ctx); err != nil {
+		loggerFrom(ctx).Error("Failed to release message claim", "messageID", messageID, "error", err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// TestClaimMessage tests that the first delivery of a message proceeds,
+// a redelivery is skipped, and the claim can be taken again once it
+// expired or was released.
+func TestClaimMessage(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST not set")
+	}
+
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("Failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	messageID := "test_message_claim"
+	defer client.Collection(processedMessageCollection).Doc(messageID).Delete(ctx)
+	now := time.Now()
+
+	if claimed, err := claimMessage(ctx, client, messageID, now); err != nil || !claimed {
+		t.Fatalf("Expected the first delivery to proceed, but got %v, %v", claimed, err)
+	}
+	if claimed, err := claimMessage(ctx, client, messageID, now.Add(time.Minute)); err != nil || claimed {
+		t.Errorf("Expected the redelivery to be skipped, but got %v, %v", claimed, err)
+	}
+	if claimed, err := claimMessage(ctx, client, messageID, now.Add(processedMessageTTL+time.Minute)); err != nil || !claimed {
+		t.Errorf("Expected an expired claim to be taken over, but got %v, %v", claimed, err)
+	}
+
+	releaseMessage(ctx, client, messageID)
+	if claimed, err := claimMessage(ctx, client, messageID, now); err != nil || !claimed {
+		t.Errorf("Expected a released message to be claimable, but got %v, %v", claimed, err)
+	}
+}
+
+// failingBlob serves message content whose body fails to read.
+type failingBlob struct{}
+
+func (failingBlob) GetMessageContent(messageID string) (*http.Response, error) {
+	return &http.Response{Body: io.NopCloser(iotest.ErrReader(errors.New("connection reset")))}, nil
+}
+
+// TestUploadMediaReadFailureReleasesClaim tests that a message whose
+// content can't be read is released for a redelivery and the user is told.
+func TestUploadMediaReadFailureReleasesClaim(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST not set")
+	}
+
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("Failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+	original := firestoreClient
+	firestoreClient = client
+	defer func() { firestoreClient = original }()
+
+	messageID := "test_message_read_failure"
+	defer client.Collection(processedMessageCollection).Doc(messageID).Delete(ctx)
+	if claimed, err := claimMessage(ctx, client, messageID, time.Now()); err != nil || !claimed {
+		t.Fatalf("Expected to claim the message, but got %v, %v", claimed, err)
+	}
+
+	bot := newFakeBot()
+	msg := mediaMessage{ID: messageID, Type: mediaTypeImage, Direct: true}
+	if err := uploadMedia(ctx, bot, failingBlob{}, "r1", "U1", msg); !errors.Is(err, errContentUnavailable) {
+		t.Errorf("Expected the read error to be returned, but got: %v", err)
+	}
+	if len(bot.replies) != 1 {
+		t.Errorf("Expected one error reply, but got: %+v", bot.replies)
+	}
+	if claimed, err := claimMessage(ctx, client, messageID, time.Now()); err != nil || !claimed {
+		t.Errorf("Expected the claim to be released, but got %v, %v", claimed, err)
+	}
+}
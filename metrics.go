@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Upload failure categories reported in the "category" label.
+const (
+	failureAuth      = "auth"
+	failureTransient = "transient"
+	failureOther     = "other"
+)
+
+// metricsRegistry holds the bot's own metrics, served on /metrics. A
+// dedicated registry keeps the output to what operators asked for and lets
+// tests read counters without other packages' collectors.
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	uploadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "linebot_file_uploads_total",
+		Help: "Files uploaded to Google Drive, by media type.",
+	}, []string{"media_type"})
+
+	uploadFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "linebot_file_upload_failures_total",
+		Help: "Uploads that failed, by category: auth, transient or other.",
+	}, []string{"category"})
+
+	uploadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "linebot_file_upload_duration_seconds",
+		Help:    "Time from fetching a media message to the finished Drive upload.",
+		Buckets: prometheus.ExponentialBuckets(0.25, 2, 10),
+	}, []string{"media_type"})
+
+	oauthEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "linebot_file_oauth_events_total",
+		Help: "Google Drive accounts connected and disconnected.",
+	}, []string{"event"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(uploadsTotal, uploadFailuresTotal, uploadDuration, oauthEventsTotal)
+}
+
+// metricsHandler serves metricsRegistry in the Prometheus text format.
+var metricsHandler = promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+
+// recordUploadMetrics counts a finished upload attempt. err is nil for
+// successful uploads; only those are timed.
+func recordUploadMetrics(mediaType string, start time.Time, err error) {
+	if err != nil {
+		uploadFailuresTotal.WithLabelValues(failureCategory(err)).Inc()
+		return
+	}
+	uploadsTotal.WithLabelValues(mediaType).Inc()
+	uploadDuration.WithLabelValues(mediaType).Observe(time.Since(start).Seconds())
+}
+
+// failureCategory groups upload errors by what fixes them: the user
+// reconnecting, trying again later, or neither.
+func failureCategory(err error) string {
+	switch classify(err) {
+	case ErrTokenNotFound, ErrTokenInvalid, ErrInsufficientScope:
+		return failureAuth
+	case ErrRateLimited, ErrTimeout:
+		return failureTransient
+	}
+	if isRetryableUploadError(err) || errors.Is(err, context.DeadlineExceeded) {
+		return failureTransient
+	}
+	return failureOther
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/api/googleapi"
+)
+
+// TestRecordUploadMetrics tests that a simulated upload increments the
+// upload counter and shows up on /metrics.
+func TestRecordUploadMetrics(t *testing.T) {
+	before := testutil.ToFloat64(uploadsTotal.WithLabelValues(mediaTypeImage))
+	recordUploadMetrics(mediaTypeImage, time.Now().Add(-time.Second), nil)
+	if got := testutil.ToFloat64(uploadsTotal.WithLabelValues(mediaTypeImage)); got != before+1 {
+		t.Errorf("Expected uploads to be %v, but got: %v", before+1, got)
+	}
+
+	failedBefore := testutil.ToFloat64(uploadFailuresTotal.WithLabelValues(failureAuth))
+	recordUploadMetrics(mediaTypeImage, time.Now(), ErrTokenInvalid)
+	if got := testutil.ToFloat64(uploadFailuresTotal.WithLabelValues(failureAuth)); got != failedBefore+1 {
+		t.Errorf("Expected auth failures to be %v, but got: %v", failedBefore+1, got)
+	}
+
+	rec := httptest.NewRecorder()
+	metricsHandler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	for _, name := range []string{"linebot_file_uploads_total", "linebot_file_upload_failures_total", "linebot_file_upload_duration_seconds"} {
+		if !strings.Contains(string(body), name) {
+			t.Errorf("Expected /metrics to contain %s", name)
+		}
+	}
+}
+
+// TestFailureCategory tests how upload errors are grouped.
+func TestFailureCategory(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{ErrTokenNotFound, failureAuth},
+		{fmt.Errorf("upload: %w", ErrTokenInvalid), failureAuth},
+		{&googleapi.Error{Code: http.StatusTooManyRequests}, failureTransient},
+		{&googleapi.Error{Code: http.StatusServiceUnavailable}, failureTransient},
+		{context.DeadlineExceeded, failureTransient},
+		{&googleapi.Error{Code: http.StatusNotFound}, failureOther},
+		{errUploadTooLarge, failureOther},
+	}
+	for _, tt := range tests {
+		if got := failureCategory(tt.err); got != tt.want {
+			t.Errorf("failureCategory(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
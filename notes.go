@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// notesFileName is the Drive file /note appends to, in the main folder.
+const notesFileName = "LINE Bot Notes.txt"
+
+// maxNoteReplyRunes keeps /note show within LINE's 5000 character limit
+// for text messages.
+const maxNoteReplyRunes = 4900
+
+// notesLocks serializes appends per user within this instance. Each append
+// also re-reads the file right before writing, so the window for losing a
+// note to another instance is as small as possible.
+var notesLocks sync.Map
+
+func handleNoteCommand(ctx context.Context, bot botClient, replyToken, userID string, args []string) {
+	if len(args) == 0 {
+		replyText(bot, replyToken, tr(ctx, userID, "notes.usage"))
+		return
+	}
+
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	rootName := userRootFolderName(ctx, userID)
+	if len(args) == 1 && args[0] == "show" {
+		notes, err := readNotes(ctx, srv, rootName)
+		if err != nil {
+			log.Printf("Failed to read notes for user %s: %v", userID, err)
+			if !replyForError(ctx, bot, replyToken, userID, err) {
+				replyText(bot, replyToken, tr(ctx, userID, "notes.read_failed"))
+			}
+			return
+		}
+		if notes == "" {
+			replyText(bot, replyToken, tr(ctx, userID, "notes.empty"))
+			return
+		}
+		replyText(bot, replyToken, tailRunes(notes, maxNoteReplyRunes))
+		return
+	}
+
+	mu, _ := notesLocks.LoadOrStore(userID, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+
+	line := fmt.Sprintf("[%s] %s", time.Now().Format("2006-01-02 15:04"), strings.Join(args, " "))
+	if err := appendNote(ctx, srv, rootName, line); err != nil {
+		log.Printf("Failed to append note for user %s: %v", userID, err)
+		if !replyForError(ctx, bot, replyToken, userID, err) {
+			replyText(bot, replyToken, tr(ctx, userID, "notes.save_failed"))
+		}
+		return
+	}
+	replyText(bot, replyToken, trf(ctx, userID, "notes.saved", notesFileName))
+}
+
+// findNotesFile returns the ID of the notes file, or "" if it doesn't exist
+// yet, along with the ID of the rootName folder it lives in.
+func findNotesFile(ctx context.Context, srv *drive.Service, rootName string) (fileID, mainFolderID string, err error) {
+	mainFolderID, err = findOrCreateFolder(ctx, srv, rootName, "root")
+	if err != nil {
+		return "", "", fmt.Errorf("could not find or create the main upload folder: %w", err)
+	}
+	query := fmt.Sprintf("name='%s' and trashed=false and '%s' in parents", escapeQueryValue(notesFileName), mainFolderID)
+	r, err := scopeFileList(srv.Files.List()).Q(query).PageSize(1).Fields("files(id)").Context(ctx).Do()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to search for notes file: %w", err)
+	}
+	if len(r.Files) == 0 {
+		return "", mainFolderID, nil
+	}
+	return r.Files[0].Id, mainFolderID, nil
+}
+
+// readNotes returns the contents of the notes file, or "" if there is none.
+func readNotes(ctx context.Context, srv *drive.Service, rootName string) (string, error) {
+	fileID, _, err := findNotesFile(ctx, srv, rootName)
+	if err != nil || fileID == "" {
+		return "", err
+	}
+	return downloadNotes(ctx, srv, fileID)
+}
+
+func downloadNotes(ctx context.Context, srv *drive.Service, fileID string) (string, error) {
+	resp, err := srv.Files.Get(fileID).SupportsAllDrives(supportsAllDrives()).Context(ctx).Download()
+	if err != nil {
+		return "", fmt.Errorf("failed to download notes: %w", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read notes: %w", err)
+	}
+	return string(b), nil
+}
+
+// appendNote adds line to the notes file, creating it on first use.
+func appendNote(ctx context.Context, srv *drive.Service, rootName, line string) error {
+	fileID, mainFolderID, err := findNotesFile(ctx, srv, rootName)
+	if err != nil {
+		return err
+	}
+	if fileID == "" {
+		file := &drive.File{Name: notesFileName, MimeType: "text/plain", Parents: []string{mainFolderID}}
+		if _, err := srv.Files.Create(file).SupportsAllDrives(supportsAllDrives()).Media(strings.NewReader(line + "\n")).Context(ctx).Do(); err != nil {
+			return fmt.Errorf("failed to create notes file: %w", err)
+		}
+		return nil
+	}
+
+	current, err := downloadNotes(ctx, srv, fileID)
+	if err != nil {
+		return err
+	}
+	if current != "" && !strings.HasSuffix(current, "\n") {
+		current += "\n"
+	}
+	if _, err := srv.Files.Update(fileID, &drive.File{}).SupportsAllDrives(supportsAllDrives()).Media(strings.NewReader(current + line + "\n")).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to update notes file: %w", err)
+	}
+	return nil
+}
+
+// tailRunes returns the last max runes of s, marking the cut.
+func tailRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return "…" + string(runes[len(runes)-max+1:])
+}
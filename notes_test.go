@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestAppendNote tests that a note is appended to the existing contents.
+func TestAppendNote(t *testing.T) {
+	var uploaded string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/files" && strings.Contains(r.URL.Query().Get("q"), "mimeType"):
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "main_folder"}}})
+		case r.URL.Path == "/files":
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "notes_id"}}})
+		case r.URL.Path == "/files/notes_id" && r.Method == http.MethodGet:
+			w.Write([]byte("first"))
+		case r.URL.Path == "/upload/drive/v3/files/notes_id":
+			body, _ := io.ReadAll(r.Body)
+			uploaded = string(body)
+			json.NewEncoder(w).Encode(&drive.File{Id: "notes_id"})
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	if err := appendNote(context.Background(), srv, mainFolderName, "second"); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if !strings.Contains(uploaded, "first\nsecond\n") {
+		t.Errorf("Expected appended contents, but got: %q", uploaded)
+	}
+}
+
+// TestTailRunes tests trimming long notes from the front.
+func TestTailRunes(t *testing.T) {
+	if got := tailRunes("短的", 5); got != "短的" {
+		t.Errorf("Expected short text unchanged, but got: %s", got)
+	}
+	if got := tailRunes("一二三四五六", 4); got != "…四五六" {
+		t.Errorf("Expected '…四五六', but got: %s", got)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// oauthPageTemplate is the page shown at the end of the OAuth flow when no
+// SUCCESS_TEMPLATE is configured. html/template escapes every field, so
+// values reflected from the query string are safe to show.
+var oauthPageTemplate = template.Must(template.New("oauth").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Noto Sans TC", sans-serif; background: #f5f5f5; margin: 0; padding: 48px 16px; text-align: center; color: #333; }
+.card { max-width: 420px; margin: 0 auto; background: #fff; border-radius: 12px; padding: 32px 24px; box-shadow: 0 2px 8px rgba(0,0,0,.08); }
+h1 { font-size: 22px; margin: 0 0 16px; color: {{if .Success}}#06c755{{else}}#d93025{{end}}; }
+p { line-height: 1.6; }
+.detail { color: #888; font-size: 13px; }
+a.button { display: inline-block; margin-top: 16px; padding: 12px 24px; border-radius: 8px; background: #06c755; color: #fff; text-decoration: none; }
+</style>
+</head>
+<body>
+<div class="card">
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{if .Detail}}<p class="detail">{{.Detail}}</p>{{end}}
+{{if .LineDeepLink}}<a class="button" href="{{.LineDeepLink}}">{{.BackLabel}}</a>{{end}}
+</div>
+</body>
+</html>
+`))
+
+// oauthPage is the data rendered by oauthPageTemplate.
+type oauthPage struct {
+	Title        string
+	Message      string
+	Detail       string
+	Success      bool
+	LineDeepLink string
+	Lang         string
+	BackLabel    string
+}
+
+// renderOAuthPage writes page in lang with the given status code.
+func renderOAuthPage(w http.ResponseWriter, lang string, status int, page oauthPage) {
+	page.LineDeepLink = lineDeepLink()
+	page.Lang = translate(lang, "oauth.html_lang")
+	page.BackLabel = translate(lang, "oauth.back")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := oauthPageTemplate.Execute(w, page); err != nil {
+		log.Printf("Failed to render OAuth page: %v", err)
+	}
+}
+
+// renderOAuthError shows an error page telling the user what went wrong,
+// using the message with the given catalog key.
+func renderOAuthError(w http.ResponseWriter, lang string, status int, key string) {
+	renderOAuthPage(w, lang, status, oauthPage{
+		Title:   translate(lang, "oauth.failed_title"),
+		Message: withCommandPrefix(translate(lang, key)),
+	})
+}
+
+// oauthPageLanguage picks the language of the OAuth pages shown before the
+// LINE user is known from the browser's Accept-Language header.
+func oauthPageLanguage(r *http.Request) string {
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(tag)
+		switch {
+		case strings.HasPrefix(tag, "zh"):
+			return langZh
+		case strings.HasPrefix(tag, "en"):
+			return langEn
+		}
+	}
+	return defaultLanguage
+}
+
+// oauthCallbackDenied reports whether the callback can't be completed
+// because the user declined consent or the request carries no code, as
+// when the callback URL is opened directly. It renders the page explaining
+// why, so the handler can return without attempting the exchange.
+func oauthCallbackDenied(w http.ResponseWriter, r *http.Request) bool {
+	lang := oauthPageLanguage(r)
+	if reason := r.FormValue("error"); reason != "" {
+		logger.Info("OAuth consent denied", "error", reason)
+		renderOAuthPage(w, lang, http.StatusForbidden, oauthPage{
+			Title:   translate(lang, "oauth.denied_title"),
+			Message: withCommandPrefix(translate(lang, "oauth.denied")),
+			Detail:  fmt.Sprintf(translate(lang, "oauth.error_code"), reason),
+		})
+		return true
+	}
+	if r.FormValue("code") == "" {
+		logger.Warn("OAuth callback without code", "url", redactURL(r.URL.String()))
+		renderOAuthError(w, lang, http.StatusBadRequest, "oauth.missing_code")
+		return true
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestOAuthCallbackDeniedConsent tests that a callback carrying the error
+// parameter shows the denial page, with the reflected value escaped,
+// instead of attempting the exchange.
+func TestOAuthCallbackDeniedConsent(t *testing.T) {
+	defer func() { lineBotID = "" }()
+	lineBotID = "@linebot"
+
+	rec := httptest.NewRecorder()
+	oauthCallbackHandler(rec, httptest.NewRequest("GET", "/oauth/callback?state=s1&error=%3Cscript%3Ealert(1)%3C%2Fscript%3E", nil))
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, but got: %d", http.StatusForbidden, rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "已取消授權") {
+		t.Errorf("Expected the denial page, but got: %s", body)
+	}
+	if strings.Contains(body, "<script>") || !strings.Contains(body, "&lt;script&gt;") {
+		t.Errorf("Expected the error value to be escaped, but got: %s", body)
+	}
+	if !strings.Contains(body, "https://line.me/R/ti/p/@linebot") {
+		t.Errorf("Expected a link back to LINE, but got: %s", body)
+	}
+}
+
+// TestOAuthCallbackMissingCode tests that opening the callback without a
+// code shows an error page instead of attempting the exchange.
+func TestOAuthCallbackMissingCode(t *testing.T) {
+	rec := httptest.NewRecorder()
+	oauthCallbackHandler(rec, httptest.NewRequest("GET", "/oauth/callback?state=s1", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, but got: %d", http.StatusBadRequest, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected an HTML page, but got Content-Type: %q", ct)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "授權失敗") || !strings.Contains(body, "缺少授權碼") {
+		t.Errorf("Expected the missing code page, but got: %s", body)
+	}
+}
+
+// TestOAuthCallbackEnglish tests that the OAuth pages follow the browser's
+// Accept-Language header.
+func TestOAuthCallbackEnglish(t *testing.T) {
+	req := httptest.NewRequest("GET", "/oauth/callback?state=s1", nil)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9,zh-TW;q=0.8")
+	rec := httptest.NewRecorder()
+	oauthCallbackHandler(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, `<html lang="en">`) || !strings.Contains(body, "Authorization failed") {
+		t.Errorf("Expected the English missing code page, but got: %s", body)
+	}
+}
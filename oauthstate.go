@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// oauthStateTTL is how long a /connect_drive link stays valid. States of
+// abandoned flows older than this are rejected and cleaned up.
+const oauthStateTTL = 10 * time.Minute
+
+// stateCleanupBatchSize is how many expired states are deleted per query.
+const stateCleanupBatchSize = 200
+
+// stateExpired reports whether a state created at createdAt can no longer
+// be used. States without a creation time are treated as expired.
+func stateExpired(createdAt, now time.Time) bool {
+	return createdAt.IsZero() || now.Sub(createdAt) > oauthStateTTL
+}
+
+// stateCleanupHandler deletes expired OAuth states.
+func stateCleanupHandler(w http.ResponseWriter, r *http.Request) {
+	deleted, err := cleanupExpiredStates(r.Context(), time.Now())
+	if err != nil {
+		log.Printf("State cleanup failed: %v", err)
+		http.Error(w, "State cleanup failed.", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "deleted %d states", deleted)
+}
+
+// cleanupExpiredStates deletes, in batches, the states created more than
+// oauthStateTTL before now and returns how many were deleted.
+func cleanupExpiredStates(ctx context.Context, now time.Time) (int, error) {
+	query := firestoreClient.Collection(stateCollection).
+		Where("created_at", "<", now.Add(-oauthStateTTL)).
+		Limit(stateCleanupBatchSize)
+
+	deleted := 0
+	for {
+		docs, err := query.Documents(ctx).GetAll()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to query expired states: %w", err)
+		}
+		if len(docs) == 0 {
+			return deleted, nil
+		}
+
+		bw := firestoreClient.BulkWriter(ctx)
+		jobs := make([]*firestore.BulkWriterJob, 0, len(docs))
+		for _, doc := range docs {
+			job, err := bw.Delete(doc.Ref)
+			if err != nil {
+				bw.End()
+				return deleted, fmt.Errorf("failed to queue state deletion: %w", err)
+			}
+			jobs = append(jobs, job)
+		}
+		bw.End()
+		for _, job := range jobs {
+			if _, err := job.Results(); err != nil {
+				return deleted, fmt.Errorf("failed to delete state: %w", err)
+			}
+			deleted++
+		}
+	}
+}
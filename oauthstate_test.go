@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestStateExpired tests the OAuth state expiry window.
+func TestStateExpired(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		createdAt time.Time
+		want      bool
+	}{
+		{now.Add(-time.Minute), false},
+		{now.Add(-oauthStateTTL), false},
+		{now.Add(-oauthStateTTL - time.Second), true},
+		{time.Time{}, true},
+	}
+	for _, tt := range tests {
+		if got := stateExpired(tt.createdAt, now); got != tt.want {
+			t.Errorf("stateExpired(%v): expected %v, but got %v", tt.createdAt, tt.want, got)
+		}
+	}
+}
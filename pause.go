@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPauseDuration caps how long /pause can suspend uploads.
+const maxPauseDuration = 30 * 24 * time.Hour
+
+// handlePauseCommand suspends automatic uploads for the given duration.
+func handlePauseCommand(ctx context.Context, bot botClient, replyToken, userID string, args []string) {
+	if len(args) != 1 {
+		replyText(bot, replyToken, tr(ctx, userID, "pause.usage"))
+		return
+	}
+	d, err := parsePauseDuration(args[0])
+	if err != nil {
+		replyText(bot, replyToken, tr(ctx, userID, "pause.invalid"))
+		return
+	}
+
+	until := time.Now().Add(d)
+	if err := updateUserPrefs(ctx, userID, map[string]interface{}{
+		"paused_until":   until,
+		"pause_notified": false,
+	}); err != nil {
+		log.Printf("Failed to pause uploads for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "pause.failed"))
+		return
+	}
+	replyText(bot, replyToken, trf(ctx, userID, "pause.done", until.Format("2006-01-02 15:04 MST")))
+}
+
+// handleResumeCommand clears a pause set with /pause.
+func handleResumeCommand(ctx context.Context, bot botClient, replyToken, userID string) {
+	if err := updateUserPrefs(ctx, userID, map[string]interface{}{
+		"paused_until": time.Time{},
+	}); err != nil {
+		log.Printf("Failed to resume uploads for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "resume.failed"))
+		return
+	}
+	replyText(bot, replyToken, tr(ctx, userID, "resume.done"))
+}
+
+// parsePauseDuration accepts Go durations ("30m", "2h") plus a day suffix
+// ("1d"), bounded by maxPauseDuration.
+func parsePauseDuration(v string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			return 0, err
+		}
+	}
+	if d <= 0 || d > maxPauseDuration {
+		return 0, fmt.Errorf("duration %s out of range", v)
+	}
+	return d, nil
+}
+
+// checkUploadsPaused reports whether the user's uploads are paused. The
+// first skipped upload of each pause also reports notify=true so the user
+// gets a single reminder rather than one per file.
+func checkUploadsPaused(ctx context.Context, userID string) (paused, notify bool) {
+	prefs, err := getUserPrefs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load prefs for user %s: %v", userID, err)
+		return false, false
+	}
+	if !time.Now().Before(prefs.PausedUntil) {
+		return false, false
+	}
+	if prefs.PauseNotified {
+		return true, false
+	}
+	if err := updateUserPrefs(ctx, userID, map[string]interface{}{"pause_notified": true}); err != nil {
+		log.Printf("Failed to save pause notification for user %s: %v", userID, err)
+	}
+	return true, true
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParsePauseDuration tests the accepted /pause durations.
+func TestParsePauseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30m", 30 * time.Minute, false},
+		{"2h", 2 * time.Hour, false},
+		{"1d", 24 * time.Hour, false},
+		{"31d", 0, true},
+		{"-1h", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parsePauseDuration(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %t, but got: %v", tt.in, tt.wantErr, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %s, but got: %s", tt.in, tt.want, got)
+		}
+	}
+}
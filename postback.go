@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+)
+
+var errEmptyPostback = errors.New("empty postback data")
+
+// parsePostback decodes URL-encoded postback data such as
+// "action=set_folder&folder_id=123". Only the first value of a repeated
+// key is kept.
+func parsePostback(data string) (map[string]string, error) {
+	if data == "" {
+		return nil, errEmptyPostback
+	}
+	values, err := url.ParseQuery(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse postback data: %w", err)
+	}
+	parsed := make(map[string]string, len(values))
+	for k, v := range values {
+		parsed[k] = v[0]
+	}
+	if parsed["action"] == "" {
+		return nil, errors.New("postback data has no action")
+	}
+	return parsed, nil
+}
+
+// handlePostbackAction dispatches a parsed postback to the handler named
+// by its "action" key.
+func handlePostbackAction(ctx context.Context, bot botClient, replyToken, userID string, data map[string]string) {
+	switch data["action"] {
+	case "choose_folder":
+		page, _ := strconv.Atoi(data["page"])
+		handleChooseFolderCommand(ctx, bot, replyToken, userID, page)
+	case "set_folder":
+		handleSetFolderPostback(ctx, bot, replyToken, userID, data["folder_id"])
+	case "delete":
+		handleDeletePostback(ctx, bot, replyToken, userID, data["fileId"], data["confirm"] == "1")
+	case "recent_files":
+		handleRecentFilesCommand(ctx, bot, replyToken, userID, data["pageToken"])
+	case "trash":
+		page, _ := strconv.Atoi(data["page"])
+		handleTrashCommand(ctx, bot, replyToken, userID, page)
+	case "untrash":
+		handleUntrashPostback(ctx, bot, replyToken, userID, data["fileId"])
+	case "purge":
+		handlePurgePostback(ctx, bot, replyToken, userID, data["fileId"], data["confirm"] == "1")
+	case "purge_cancel", "delete_cancel":
+		replyText(bot, replyToken, tr(ctx, userID, "common.cancelled"))
+	default:
+		log.Printf("Unsupported postback action: %q", data["action"])
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestParsePostback tests decoding postback data.
+func TestParsePostback(t *testing.T) {
+	data, err := parsePostback("action=set_folder&folder_id=a%2Fb&folder_id=c")
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if data["action"] != "set_folder" || data["folder_id"] != "a/b" {
+		t.Errorf("Unexpected postback data: %v", data)
+	}
+
+	if _, err := parsePostback(""); !errors.Is(err, errEmptyPostback) {
+		t.Errorf("Expected errEmptyPostback, but got: %v", err)
+	}
+	for _, malformed := range []string{"action=%zz", "folder_id=1", "action="} {
+		if _, err := parsePostback(malformed); err == nil {
+			t.Errorf("%q: expected an error", malformed)
+		}
+	}
+}
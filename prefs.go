@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const prefsCollection = "user_prefs"
+
+// userPrefs holds the per-user settings stored in Firestore.
+type userPrefs struct {
+	// DestinationFolderID is the folder chosen with /choose_folder. Empty
+	// means uploads go to the default month folder.
+	DestinationFolderID   string `firestore:"destination_folder_id"`
+	DestinationFolderName string `firestore:"destination_folder_name"`
+
+	// PausedUntil suspends automatic uploads until the given time (/pause).
+	PausedUntil   time.Time `firestore:"paused_until"`
+	PauseNotified bool      `firestore:"pause_notified"`
+
+	// Routes maps a media type to a folder under the main folder (/route).
+	Routes map[string]string `firestore:"routes"`
+
+	// Description is applied to every upload; it may contain {{.Date}}.
+	Description string `firestore:"description"`
+
+	// ReconnectingAt is set while a /reconnect is waiting for the new token.
+	ReconnectingAt time.Time `firestore:"reconnecting_at"`
+
+	// ManualUpload makes the bot ask before uploading each file (/manual).
+	ManualUpload bool `firestore:"manual_upload"`
+
+	// Dedupe skips files whose content was already uploaded (/dedupe).
+	// Nil means the user never chose, which defaults to on.
+	Dedupe *bool `firestore:"dedupe"`
+
+	// RootFolderName replaces mainFolderName as the top-level upload
+	// folder (/setfolder). Empty means the default.
+	RootFolderName string `firestore:"root_folder_name"`
+
+	// Language is the reply language chosen with /lang. Empty means
+	// defaultLanguage.
+	Language string `firestore:"language"`
+
+	// LastUploadID is the file /undo moves to the trash. It is cleared
+	// once undone.
+	LastUploadID string `firestore:"last_upload_id"`
+
+	// Sandbox sends every upload to the sandbox folder (/sandbox).
+	Sandbox bool `firestore:"sandbox"`
+}
+
+// getUserPrefs loads the user's preferences. Users without a stored
+// document get the zero value.
+func getUserPrefs(ctx context.Context, userID string) (*userPrefs, error) {
+	var prefs userPrefs
+	doc, err := firestoreClient.Collection(prefsCollection).Doc(userID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return &prefs, nil
+		}
+		return nil, fmt.Errorf("failed to get prefs from firestore: %w", err)
+	}
+	if err := doc.DataTo(&prefs); err != nil {
+		return nil, fmt.Errorf("failed to parse prefs data: %w", err)
+	}
+	return &prefs, nil
+}
+
+// updateUserPrefs merges the given fields into the user's preferences.
+func updateUserPrefs(ctx context.Context, userID string, fields map[string]interface{}) error {
+	_, err := firestoreClient.Collection(prefsCollection).Doc(userID).Set(ctx, fields, firestore.MergeAll)
+	if err != nil {
+		return fmt.Errorf("failed to save prefs to firestore: %w", err)
+	}
+	return nil
+}
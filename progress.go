@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+)
+
+// progressStep is the percentage increment between progress reports.
+const progressStep = 20
+
+// progressReader reports how much of a known-size stream has been read.
+// Reports are throttled to one per progressStep percent and at most one per
+// minInterval.
+type progressReader struct {
+	r           io.Reader
+	total       int64
+	read        int64
+	minInterval time.Duration
+	lastPercent int
+	lastReport  time.Time
+	report      func(percent int)
+}
+
+func newProgressReader(r io.Reader, total int64, minInterval time.Duration, report func(percent int)) *progressReader {
+	return &progressReader{
+		r:           r,
+		total:       total,
+		minInterval: minInterval,
+		lastReport:  time.Now(),
+		report:      report,
+	}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+
+	percent := int(p.read * 100 / p.total)
+	// The final 100% is covered by the success reply.
+	if percent < 100 && percent >= p.lastPercent+progressStep && time.Since(p.lastReport) >= p.minInterval {
+		p.lastPercent = percent - percent%progressStep
+		p.lastReport = time.Now()
+		p.report(p.lastPercent)
+	}
+	return n, err
+}
+
+// withUploadProgress wraps r so the chat the file was sent in, given by
+// to, receives push updates in lang while a large file uploads. Small
+// files, or files of unknown size, are returned unwrapped. The updates are
+// pushed in the background so reading never waits on LINE; stop ends them
+// and must be called once the upload is over.
+func withUploadProgress(bot botClient, to, lang string, r io.Reader, total int64) (wrapped io.Reader, stop func()) {
+	if total <= 0 || total < progressMinBytes {
+		return r, func() {}
+	}
+	report, stop := startProgressPush(func(percent int) {
+		if _, err := bot.PushMessage(
+			&messaging_api.PushMessageRequest{
+				To: to,
+				Messages: []messaging_api.MessageInterface{
+					&messaging_api.TextMessage{
+						Text: fmt.Sprintf(translate(lang, "upload.progress"), percent),
+					},
+				},
+			},
+			"",
+		); err != nil {
+			log.Printf("Failed to push upload progress to %s: %v", to, err)
+		}
+	})
+	return newProgressReader(r, total, progressInterval, report), stop
+}
+
+// startProgressPush calls push from a goroutine for each reported
+// percentage. report never blocks: while a push is in flight only the
+// latest percentage is kept and older ones are dropped. stop waits for an
+// in-flight push and drops any pending one, so no progress arrives after
+// the upload's result.
+func startProgressPush(push func(percent int)) (report func(percent int), stop func()) {
+	updates := make(chan int, 1)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-done:
+				return
+			case percent := <-updates:
+				select {
+				case <-done:
+					return
+				default:
+				}
+				push(percent)
+			}
+		}
+	}()
+
+	report = func(percent int) {
+		// Only the reader calls report, so after draining a stale update
+		// the send below can't block.
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- percent
+	}
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+		})
+	}
+	return report, stop
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+)
+
+// TestProgressReader tests that progress is reported in steps and never
+// at 100%.
+func TestProgressReader(t *testing.T) {
+	data := make([]byte, 1000)
+	var reports []int
+	r := newProgressReader(bytes.NewReader(data), int64(len(data)), 0, func(percent int) {
+		reports = append(reports, percent)
+	})
+
+	buf := make([]byte, 50)
+	for {
+		if _, err := r.Read(buf); err == io.EOF {
+			break
+		}
+	}
+
+	want := []int{20, 40, 60, 80}
+	if len(reports) != len(want) {
+		t.Fatalf("Expected reports %v, but got: %v", want, reports)
+	}
+	for i := range want {
+		if reports[i] != want[i] {
+			t.Errorf("Expected reports %v, but got: %v", want, reports)
+		}
+	}
+}
+
+// signalBot is a fakeBot that signals each push, since progress is pushed
+// from another goroutine.
+type signalBot struct {
+	*fakeBot
+	pushed chan struct{}
+}
+
+func (b *signalBot) PushMessage(req *messaging_api.PushMessageRequest, xLineRetryKey string) (*messaging_api.PushMessageResponse, error) {
+	resp, err := b.fakeBot.PushMessage(req, xLineRetryKey)
+	b.pushed <- struct{}{}
+	return resp, err
+}
+
+// TestWithUploadProgressTarget tests that progress is pushed to the chat
+// passed in, such as the group a file was sent in.
+func TestWithUploadProgressTarget(t *testing.T) {
+	progressMinBytes, progressInterval = 100, 0
+	defer func() { progressMinBytes, progressInterval = int64(20<<20), 5*time.Second }()
+
+	bot := &signalBot{fakeBot: newFakeBot(), pushed: make(chan struct{}, 10)}
+	r, stop := withUploadProgress(bot, "G1", langEn, bytes.NewReader(make([]byte, 1000)), 1000)
+	if _, err := io.Copy(io.Discard, iotest.OneByteReader(r)); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	select {
+	case <-bot.pushed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected progress pushes")
+	}
+	stop()
+	for _, p := range bot.pushes {
+		if p.to != "G1" {
+			t.Errorf("Expected progress pushed to G1, but got: %s", p.to)
+		}
+	}
+}
+
+// TestStartProgressPushDoesNotBlock tests that reporting doesn't wait for
+// a slow push and that only the latest pending update is pushed.
+func TestStartProgressPushDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	var pushed []int
+	report, stop := startProgressPush(func(percent int) {
+		started <- struct{}{}
+		<-release
+		pushed = append(pushed, percent)
+	})
+
+	report(20)
+	<-started
+	reported := make(chan struct{})
+	go func() {
+		report(40)
+		report(60)
+		close(reported)
+	}()
+	select {
+	case <-reported:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected report not to block on a slow push")
+	}
+
+	close(release)
+	<-started
+	stop()
+	if len(pushed) != 2 || pushed[0] != 20 || pushed[1] != 60 {
+		t.Errorf("Expected pushes [20 60], but got: %v", pushed)
+	}
+}
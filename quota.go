@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// handleQuotaCommand replies with how much of the user's Google account
+// storage is used.
+func handleQuotaCommand(ctx context.Context, bot botClient, replyToken, userID string) {
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	about, err := srv.About.Get().Fields("storageQuota").Context(ctx).Do()
+	if err != nil {
+		log.Printf("Failed to get storage quota for user %s: %v", userID, err)
+		driveServices.invalidateOnAuthError(userID, err)
+		if !replyForError(ctx, bot, replyToken, userID, err) {
+			replyText(bot, replyToken, tr(ctx, userID, "quota.failed"))
+		}
+		return
+	}
+	replyText(bot, replyToken, formatQuota(userLanguage(ctx, userID), about.StorageQuota))
+}
+
+// formatQuota describes the storage quota, e.g. "Used 3.2 GB of 15.0 GB".
+// Accounts without a limit report a zero or missing limit.
+func formatQuota(lang string, q *drive.AboutStorageQuota) string {
+	if q == nil {
+		return translate(lang, "quota.unlimited")
+	}
+	if q.Limit == 0 {
+		return fmt.Sprintf(translate(lang, "quota.unlimited_usage"), formatBytes(q.Usage), formatBytes(q.UsageInDrive))
+	}
+	return fmt.Sprintf(translate(lang, "quota.usage"),
+		formatBytes(q.Usage), formatBytes(q.Limit), formatBytes(q.UsageInDrive), formatBytes(max(q.Limit-q.Usage, 0)))
+}
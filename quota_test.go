@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestFormatQuota tests the limited and unlimited quota replies.
+func TestFormatQuota(t *testing.T) {
+	tests := []struct {
+		quota *drive.AboutStorageQuota
+		want  string
+	}{
+		{
+			&drive.AboutStorageQuota{Limit: 15 << 30, Usage: 3435973837, UsageInDrive: 1 << 30},
+			"Used 3.2 GB of 15.0 GB\n(1.0 GB in Drive, 11.8 GB free)",
+		},
+		{
+			&drive.AboutStorageQuota{Usage: 5 << 20, UsageInDrive: 5 << 20},
+			"Unlimited storage\n(5.0 MB used, 5.0 MB in Drive)",
+		},
+		{nil, "Unlimited storage"},
+	}
+	for _, tt := range tests {
+		if got := formatQuota(langEn, tt.quota); got != tt.want {
+			t.Errorf("Expected %q, but got %q", tt.want, got)
+		}
+	}
+}
+
+// TestAboutStorageQuota tests that the quota fields decode from Drive's
+// string-encoded int64 values.
+func TestAboutStorageQuota(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("fields"); got != "storageQuota" {
+			t.Errorf("Expected fields=storageQuota, but got: %s", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"storageQuota": map[string]string{"limit": "16106127360", "usage": "1073741824", "usageInDrive": "1024"},
+		})
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+	about, err := srv.About.Get().Fields("storageQuota").Do()
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if got := formatQuota(langEn, about.StorageQuota); got != "Used 1.0 GB of 15.0 GB\n(1.0 KB in Drive, 14.0 GB free)" {
+		t.Errorf("Unexpected quota reply: %q", got)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// lineRetryFallback is how long to wait after a 429 without a usable
+// Retry-After header.
+const lineRetryFallback = time.Second
+
+// rateLimitedTransport spaces out outbound LINE API requests to at most
+// perSecond per second and retries requests rejected with 429.
+type rateLimitedTransport struct {
+	base       http.RoundTripper
+	interval   time.Duration
+	maxRetries int
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newRateLimitedTransport(base http.RoundTripper, perSecond, maxRetries int) *rateLimitedTransport {
+	t := &rateLimitedTransport{base: base, maxRetries: maxRetries}
+	if perSecond > 0 {
+		t.interval = time.Second / time.Duration(perSecond)
+	}
+	return t
+}
+
+var (
+	lineHTTPClientOnce sync.Once
+	lineHTTPClientInst *http.Client
+)
+
+// lineHTTPClient returns the client shared by every messaging API client,
+// so all outbound LINE calls draw from the same rate limit.
+func lineHTTPClient() *http.Client {
+	lineHTTPClientOnce.Do(func() {
+		lineHTTPClientInst = &http.Client{
+			Transport: newRateLimitedTransport(http.DefaultTransport, lineRateLimit, lineMaxRetries),
+		}
+	})
+	return lineHTTPClientInst
+}
+
+// wait blocks until the limiter allows another request.
+func (t *rateLimitedTransport) wait() {
+	if t.interval <= 0 {
+		return
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	delay := t.next.Sub(now)
+	t.next = t.next.Add(t.interval)
+	t.mu.Unlock()
+	time.Sleep(delay)
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		t.wait()
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= t.maxRetries {
+			return resp, err
+		}
+		// The body must be replayable to retry; the SDK builds requests
+		// from in-memory buffers, so GetBody is normally set.
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+		delay := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+		resp.Body.Close()
+		debugf("LINE API rate limited, retrying %s in %s", req.URL.Path, delay)
+		time.Sleep(delay)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an
+// HTTP date.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return lineRetryFallback
+	}
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return lineRetryFallback
+}
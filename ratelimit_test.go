@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRateLimitedTransportRetries tests that a 429 is retried with the
+// request body intact.
+func TestRateLimitedTransportRetries(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("Expected body 'payload', but got: '%s'", body)
+		}
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newRateLimitedTransport(http.DefaultTransport, 0, 2)}
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls != 2 {
+		t.Errorf("Expected success after 2 calls, got status %d after %d calls", resp.StatusCode, calls)
+	}
+}
+
+// TestRetryAfter tests parsing both Retry-After formats.
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", lineRetryFallback},
+		{"3", 3 * time.Second},
+		{now.Add(2 * time.Second).Format(http.TimeFormat), 2 * time.Second},
+		{"soon", lineRetryFallback},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.header, now); got != tt.want {
+			t.Errorf("%q: expected %s, but got: %s", tt.header, tt.want, got)
+		}
+	}
+}
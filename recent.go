@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// recentFilesPageSize is how many files /recent_files shows at a time.
+const recentFilesPageSize = 5
+
+// maxPostbackDataLen is the longest postback data LINE accepts.
+const maxPostbackDataLen = 300
+
+// handleRecentFilesCommand replies with one page of the user's most recent
+// uploads. An empty pageToken starts from the newest file; otherwise it is
+// the token carried by a previous page's "Show more" button.
+func handleRecentFilesCommand(ctx context.Context, bot botClient, replyToken, userID, pageToken string) {
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	files, nextPageToken, err := getRecentFiles(ctx, srv, userRootFolderName(ctx, userID), recentFilesPageSize, pageToken)
+	if err != nil {
+		log.Printf("Failed to get recent files: %v", err)
+		replyForError(ctx, bot, replyToken, userID, err)
+		return
+	}
+
+	if len(files) == 0 {
+		if pageToken != "" {
+			replyText(bot, replyToken, tr(ctx, userID, "recent.no_more"))
+		} else {
+			replyText(bot, replyToken, tr(ctx, userID, "files.none"))
+		}
+		return
+	}
+
+	loadSharing(ctx, srv, files)
+	carousel := buildFilesCarousel(userLanguage(ctx, userID), files)
+	if data := recentFilesPostbackData(nextPageToken); data != "" {
+		carousel.Contents = append(carousel.Contents, buildShowMoreBubble(userLanguage(ctx, userID), data))
+	}
+
+	msg := &messaging_api.FlexMessage{
+		AltText:  tr(ctx, userID, "recent.alt"),
+		Contents: carousel,
+		QuickReply: &messaging_api.QuickReply{
+			Items: []messaging_api.QuickReplyItem{
+				{
+					Action: &messaging_api.MessageAction{
+						Label: tr(ctx, userID, "label.recent_files"),
+						Text:  commandText("recent_files"),
+					},
+				},
+				{
+					Action: &messaging_api.MessageAction{
+						Label: tr(ctx, userID, "label.disconnect"),
+						Text:  commandText("disconnect_drive"),
+					},
+				},
+			},
+		},
+	}
+	if err := sendReply(bot, replyToken, []messaging_api.MessageInterface{msg}); err != nil {
+		log.Print(err)
+	}
+}
+
+// getRecentFiles returns up to count files from the rootName upload folder,
+// newest first, along with the token for the next page ("" at the end).
+func getRecentFiles(ctx context.Context, srv *drive.Service, rootName string, count int64, pageToken string) ([]*drive.File, string, error) {
+	// First, find the main folder. If it doesn't exist, there are no files to list.
+	mainFolderID, err := findOrCreateFolder(ctx, srv, rootName, "root")
+	if err != nil {
+		return nil, "", fmt.Errorf("could not find or create the main upload folder: %w", err)
+	}
+
+	// Search for files within the main folder, ordering by creation date.
+	query := fmt.Sprintf("'%s' in parents and trashed=false", mainFolderID)
+	call := scopeFileList(srv.Files.List()).
+		Q(query).
+		PageSize(count).
+		OrderBy("createdTime desc").
+		Fields("nextPageToken", googleapi.Field(listingFields()))
+	if pageToken != "" {
+		call = call.PageToken(pageToken)
+	}
+	r, err := call.Context(ctx).Do()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to retrieve files: %w", err)
+	}
+
+	return r.Files, r.NextPageToken, nil
+}
+
+// recentFilesPostbackData encodes the postback for the next page, or ""
+// when there is no next page or the token is too long to fit.
+func recentFilesPostbackData(nextPageToken string) string {
+	if nextPageToken == "" {
+		return ""
+	}
+	data := "action=recent_files&pageToken=" + url.QueryEscape(nextPageToken)
+	if len(data) > maxPostbackDataLen {
+		log.Printf("Page token too long for a postback (%d bytes), omitting Show more", len(data))
+		return ""
+	}
+	return data
+}
+
+// buildShowMoreBubble is the last bubble of a page that has more files.
+func buildShowMoreBubble(lang, data string) messaging_api.FlexBubble {
+	return messaging_api.FlexBubble{
+		Body: &messaging_api.FlexBox{
+			Layout:         messaging_api.FlexBoxLAYOUT_VERTICAL,
+			JustifyContent: messaging_api.FlexBoxJUSTIFY_CONTENT_CENTER,
+			Contents: []messaging_api.FlexComponentInterface{
+				&messaging_api.FlexButton{
+					Style: messaging_api.FlexButtonSTYLE_LINK,
+					Action: &messaging_api.PostbackAction{
+						Label:       translate(lang, "recent.show_more"),
+						Data:        data,
+						DisplayText: translate(lang, "recent.show_more"),
+					},
+				},
+			},
+		},
+	}
+}
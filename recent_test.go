@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestGetRecentFilesPageToken tests that the page token is sent to Drive
+// and the next one is returned to the caller.
+func TestGetRecentFilesPageToken(t *testing.T) {
+	var gotToken string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if strings.Contains(q.Get("q"), "mimeType='application/vnd.google-apps.folder'") {
+			if !strings.Contains(q.Get("q"), "'me' in owners") {
+				t.Errorf("Expected the main folder lookup to require ownership, but got: %s", q.Get("q"))
+			}
+			fmt.Fprint(w, `{"files": [{"id": "main_folder"}]}`)
+			return
+		}
+		if !strings.Contains(q.Get("fields"), "nextPageToken") {
+			t.Errorf("Expected nextPageToken to be requested, but got fields: %s", q.Get("fields"))
+		}
+		gotToken = q.Get("pageToken")
+		if gotToken == "page_2" {
+			fmt.Fprint(w, `{"files": [{"id": "file_6", "name": "f6"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"files": [{"id": "file_1", "name": "f1"}], "nextPageToken": "page_2"}`)
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create drive service: %v", err)
+	}
+
+	files, next, err := getRecentFiles(context.Background(), srv, mainFolderName, recentFilesPageSize, "")
+	if err != nil {
+		t.Fatalf("getRecentFiles failed: %v", err)
+	}
+	if gotToken != "" || len(files) != 1 || files[0].Id != "file_1" || next != "page_2" {
+		t.Errorf("Expected first page with next token page_2, but got token=%q files=%v next=%q", gotToken, files, next)
+	}
+
+	files, next, err = getRecentFiles(context.Background(), srv, mainFolderName, recentFilesPageSize, next)
+	if err != nil {
+		t.Fatalf("getRecentFiles failed: %v", err)
+	}
+	if gotToken != "page_2" || len(files) != 1 || files[0].Id != "file_6" || next != "" {
+		t.Errorf("Expected last page without next token, but got token=%q files=%v next=%q", gotToken, files, next)
+	}
+}
+
+// TestRecentFilesPostbackData tests that the page token round-trips
+// through the postback and that the button is omitted at the end.
+func TestRecentFilesPostbackData(t *testing.T) {
+	if data := recentFilesPostbackData(""); data != "" {
+		t.Errorf("Expected no postback at the end of the list, but got: %q", data)
+	}
+
+	token := "abc+/=&def"
+	data := recentFilesPostbackData(token)
+	parsed, err := parsePostback(data)
+	if err != nil {
+		t.Fatalf("parsePostback failed: %v", err)
+	}
+	if parsed["action"] != "recent_files" || parsed["pageToken"] != token {
+		t.Errorf("Expected token %q to round-trip, but got: %v", token, parsed)
+	}
+
+	if data := recentFilesPostbackData(strings.Repeat("x", maxPostbackDataLen)); data != "" {
+		t.Errorf("Expected an oversized token to be dropped, but got %d bytes", len(data))
+	}
+
+	bubble := buildShowMoreBubble(langEn, data)
+	button := bubble.Body.Contents[0].(*messaging_api.FlexButton)
+	if action := button.Action.(*messaging_api.PostbackAction); action.Data != data {
+		t.Errorf("Expected button data %q, but got: %q", data, action.Data)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// reconnectWindow is how long after /reconnect revokes a token that
+// failed uploads are attributed to the reconnection rather than to a
+// broken connection.
+const reconnectWindow = 10 * time.Minute
+
+// markReconnecting records that the user has started /reconnect, so
+// uploads racing the token revocation don't trigger a second prompt.
+func markReconnecting(ctx context.Context, userID string) {
+	if err := updateUserPrefs(ctx, userID, map[string]interface{}{"reconnecting_at": time.Now()}); err != nil {
+		log.Printf("Failed to mark reconnect for user %s: %v", userID, err)
+	}
+}
+
+// clearReconnecting is called once the new token is stored.
+func clearReconnecting(ctx context.Context, userID string) {
+	if err := updateUserPrefs(ctx, userID, map[string]interface{}{"reconnecting_at": firestore.Delete}); err != nil {
+		log.Printf("Failed to clear reconnect for user %s: %v", userID, err)
+	}
+}
+
+// reconnectInProgress reports whether prefs show a /reconnect started
+// within reconnectWindow of now.
+func reconnectInProgress(prefs *userPrefs, now time.Time) bool {
+	return !prefs.ReconnectingAt.IsZero() && now.Sub(prefs.ReconnectingAt) < reconnectWindow
+}
+
+// isReconnectRace reports whether a failed upload was caused by the user's
+// own /reconnect revoking the token mid-upload.
+func isReconnectRace(ctx context.Context, userID string, err error) bool {
+	if c := classify(err); c != ErrTokenNotFound && c != ErrTokenInvalid {
+		return false
+	}
+	prefs, perr := getUserPrefs(ctx, userID)
+	if perr != nil {
+		log.Printf("Failed to load prefs for user %s: %v", userID, perr)
+		return false
+	}
+	return reconnectInProgress(prefs, time.Now())
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestReconnectInProgress tests the reconnect window check.
+func TestReconnectInProgress(t *testing.T) {
+	now := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		prefs userPrefs
+		want  bool
+	}{
+		{"never reconnected", userPrefs{}, false},
+		{"recent", userPrefs{ReconnectingAt: now.Add(-time.Minute)}, true},
+		{"expired", userPrefs{ReconnectingAt: now.Add(-reconnectWindow)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reconnectInProgress(&tt.prefs, now); got != tt.want {
+				t.Errorf("expected %v, but got: %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// uploadsQuery matches the bot's uploads that are not in the trash.
+var uploadsQuery = fmt.Sprintf("trashed=false and appProperties has { key='%s' and value='true' }", uploadMarkerKey)
+
+var errNoUploads = errors.New("no uploads to rename")
+
+// handleRenameCommand renames the user's most recent upload.
+func handleRenameCommand(ctx context.Context, bot botClient, replyToken, userID string, args []string) {
+	newName := strings.Join(args, " ")
+	if sanitizeFilename(newName) == "" {
+		replyText(bot, replyToken, tr(ctx, userID, "rename.usage"))
+		return
+	}
+
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	file, err := renameLatestUpload(ctx, srv, newName)
+	if err != nil {
+		if errors.Is(err, errNoUploads) {
+			replyText(bot, replyToken, tr(ctx, userID, "files.none"))
+			return
+		}
+		log.Printf("Failed to rename latest upload for user %s: %v", userID, err)
+		driveServices.invalidateOnAuthError(userID, err)
+		if !replyForError(ctx, bot, replyToken, userID, err) {
+			replyText(bot, replyToken, tr(ctx, userID, "rename.failed"))
+		}
+		return
+	}
+	replyText(bot, replyToken, trf(ctx, userID, "rename.done", file.Name, file.WebViewLink))
+}
+
+// renameLatestUpload gives the most recently created upload a new name.
+func renameLatestUpload(ctx context.Context, srv *drive.Service, newName string) (*drive.File, error) {
+	r, err := scopeFileList(srv.Files.List()).
+		Q(uploadsQuery).
+		OrderBy("createdTime desc").
+		PageSize(1).
+		Fields("files(id, name)").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find latest upload: %w", err)
+	}
+	if len(r.Files) == 0 {
+		return nil, errNoUploads
+	}
+	latest := r.Files[0]
+
+	updated, err := srv.Files.Update(latest.Id, &drive.File{Name: renamedFilename(latest.Name, newName)}).SupportsAllDrives(supportsAllDrives()).
+		Fields("id, name, webViewLink").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename file %s: %w", latest.Id, err)
+	}
+	return updated, nil
+}
+
+// renamedFilename sanitizes newName and keeps the old extension when the
+// user didn't type one, so "收據" renames "photo.jpg" to "收據.jpg".
+func renamedFilename(oldName, newName string) string {
+	newName = sanitizeFilename(newName)
+	if filepath.Ext(newName) == "" {
+		newName = sanitizeFilename(newName + filepath.Ext(oldName))
+	}
+	return newName
+}
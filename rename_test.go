@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestRenamedFilename tests sanitization of the new name and that the old
+// extension is kept when none is given.
+func TestRenamedFilename(t *testing.T) {
+	tests := []struct {
+		oldName, newName, want string
+	}{
+		{"photo.jpg", "收據", "收據.jpg"},
+		{"photo.jpg", "receipt.png", "receipt.png"},
+		{"photo.jpg", "a/b\\c", "a_b_c.jpg"},
+		{"notes", "  trip  ", "trip"},
+		{"photo.jpg", strings.Repeat("x", 300), strings.Repeat("x", maxFilenameLen-4) + ".jpg"},
+	}
+	for _, tt := range tests {
+		if got := renamedFilename(tt.oldName, tt.newName); got != tt.want {
+			t.Errorf("renamedFilename(%q, %q) = %q, want %q", tt.oldName, tt.newName, got, tt.want)
+		}
+	}
+}
+
+// TestRenameLatestUpload tests that the newest upload is looked up and
+// updated with the new name.
+func TestRenameLatestUpload(t *testing.T) {
+	var updatedName string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/files":
+			q := r.URL.Query()
+			if !strings.Contains(q.Get("q"), uploadMarkerKey) || q.Get("orderBy") != "createdTime desc" {
+				t.Errorf("Expected newest upload query, but got q=%q orderBy=%q", q.Get("q"), q.Get("orderBy"))
+			}
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "file_1", Name: "line-bot-upload-1.jpg"}}})
+		case r.Method == "PATCH" && r.URL.Path == "/files/file_1":
+			var body drive.File
+			b, _ := io.ReadAll(r.Body)
+			json.Unmarshal(b, &body)
+			updatedName = body.Name
+			json.NewEncoder(w).Encode(&drive.File{Id: "file_1", Name: body.Name, WebViewLink: "https://drive.google.com/file/d/file_1/view"})
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	file, err := renameLatestUpload(context.Background(), srv, "receipt")
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if updatedName != "receipt.jpg" || file.Name != "receipt.jpg" {
+		t.Errorf("Expected the file to be renamed to 'receipt.jpg', but got update=%q reply=%q", updatedName, file.Name)
+	}
+}
+
+// TestRenameLatestUploadNone tests the reply when nothing was uploaded.
+func TestRenameLatestUploadNone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&drive.FileList{})
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+	if _, err := renameLatestUpload(context.Background(), srv, "receipt"); err != errNoUploads {
+		t.Errorf("Expected errNoUploads, but got: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errRichMenuNotConfigured is returned when linking a rich menu whose ID
+// was not set.
+var errRichMenuNotConfigured = errors.New("rich menu not configured: set RICHMENU_CONNECT_ID and RICHMENU_MAIN_ID")
+
+// setUserMenu links the given rich menu to a user, retrying with exponential
+// backoff and verifying the link through GetRichMenuIdOfUser. Persistent
+// failures are logged with the user ID so they can be followed up on.
+func setUserMenu(bot botClient, userID, richMenuID string) error {
+	if richMenuID == "" {
+		return errRichMenuNotConfigured
+	}
+	var lastErr error
+	backoff := richMenuLinkBackoff
+	// The first attempt is not a retry.
+	attempts := 1 + max(richMenuLinkRetries, 0)
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		lastErr = linkAndVerifyRichMenu(bot, userID, richMenuID)
+		if lastErr == nil {
+			return nil
+		}
+		log.Printf("Rich menu link attempt failed: user_id=%s rich_menu_id=%s attempt=%d/%d error=%q", userID, richMenuID, attempt, attempts, lastErr)
+		if attempt < attempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	log.Printf("Rich menu link failed permanently: user_id=%s rich_menu_id=%s attempts=%d error=%q", userID, richMenuID, attempts, lastErr)
+	return lastErr
+}
+
+func linkAndVerifyRichMenu(bot botClient, userID, richMenuID string) error {
+	if _, err := bot.LinkRichMenuIdToUser(userID, richMenuID); err != nil {
+		return fmt.Errorf("failed to link rich menu: %w", err)
+	}
+
+	resp, err := bot.GetRichMenuIdOfUser(userID)
+	if err != nil {
+		return fmt.Errorf("failed to verify rich menu: %w", err)
+	}
+	if resp.RichMenuId != richMenuID {
+		return fmt.Errorf("rich menu mismatch: expected %s, got %s", richMenuID, resp.RichMenuId)
+	}
+	return nil
+}
+
+// handleMenuCommand re-links the requested rich menu for users whose menu
+// disappeared. The main menu is only allowed when the user is connected.
+func handleMenuCommand(ctx context.Context, bot botClient, replyToken, userID string, args []string) {
+	if len(args) != 1 || (args[0] != "connect" && args[0] != "main") {
+		replyText(bot, replyToken, tr(ctx, userID, "menu.usage"))
+		return
+	}
+
+	connected, err := isUserConnected(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to check connection state for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "menu.failed"))
+		return
+	}
+
+	menuID := richMenuConnect
+	if args[0] == "main" {
+		if !connected {
+			sendConnectionPrompt(ctx, bot, replyToken, userID)
+			return
+		}
+		menuID = richMenuMain
+	}
+
+	if err := setUserMenu(bot, userID, menuID); err != nil {
+		if errors.Is(err, errRichMenuNotConfigured) {
+			replyText(bot, replyToken, tr(ctx, userID, "menu.not_configured"))
+			return
+		}
+		replyText(bot, replyToken, tr(ctx, userID, "menu.link_failed"))
+		return
+	}
+	replyText(bot, replyToken, tr(ctx, userID, "menu.restored"))
+}
+
+// isUserConnected reports whether a Google token is stored for the user.
+func isUserConnected(ctx context.Context, userID string) (bool, error) {
+	_, err := firestoreClient.Collection(tokenCollection).Doc(userID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get token from firestore: %w", err)
+	}
+	return true, nil
+}
+
+// handleRichMenuSwitch validates a tab switch made through a richmenuswitch
+// action. LINE switches the menu on the client before we hear about it, so
+// when a user without a Google token lands on the main (connected) tab we
+// put them back on the connect menu.
+func handleRichMenuSwitch(ctx context.Context, bot botClient, replyToken, userID, aliasID, switchStatus string) {
+	if switchStatus != "SUCCESS" {
+		log.Printf("Rich menu switch failed: user_id=%s alias_id=%s status=%s", userID, aliasID, switchStatus)
+	}
+	if richMenuMainAlias == "" || aliasID != richMenuMainAlias {
+		return
+	}
+
+	connected, err := isUserConnected(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to check connection state for user %s: %v", userID, err)
+		return
+	}
+	if !connected {
+		if err := setUserMenu(bot, userID, richMenuConnect); err != nil {
+			log.Printf("Failed to restore connect menu for user %s: %v", userID, err)
+		}
+		sendConnectionPrompt(ctx, bot, replyToken, userID)
+	}
+}
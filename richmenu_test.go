@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+)
+
+// TestSetUserMenuRetries tests that setUserMenu retries a failed link and
+// verifies the final state.
+func TestSetUserMenuRetries(t *testing.T) {
+	linkCalls := 0
+	linked := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v2/bot/user/U1/richmenu/richmenu-main":
+			linkCalls++
+			// Fail the first attempt to force a retry.
+			if linkCalls == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			linked = "richmenu-main"
+			w.Write([]byte("{}"))
+		case r.Method == "GET" && r.URL.Path == "/v2/bot/user/U1/richmenu":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(&messaging_api.RichMenuIdResponse{RichMenuId: linked})
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	bot, err := messaging_api.NewMessagingApiAPI("token", messaging_api.WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create mock bot: %v", err)
+	}
+
+	richMenuLinkRetries = 3
+	richMenuLinkBackoff = time.Millisecond
+
+	if err := setUserMenu(bot, "U1", "richmenu-main"); err != nil {
+		t.Errorf("Expected no error, but got: %v", err)
+	}
+	if linkCalls != 2 {
+		t.Errorf("Expected 2 link calls, but got: %d", linkCalls)
+	}
+}
+
+// TestSetUserMenuRetryCount tests that RICHMENU_LINK_RETRIES counts the
+// retries after the first attempt.
+func TestSetUserMenuRetryCount(t *testing.T) {
+	linkCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		linkCalls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	bot, err := messaging_api.NewMessagingApiAPI("token", messaging_api.WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create mock bot: %v", err)
+	}
+
+	richMenuLinkBackoff = time.Millisecond
+	for _, retries := range []int{0, 2} {
+		richMenuLinkRetries = retries
+		linkCalls = 0
+		if err := setUserMenu(bot, "U1", "richmenu-main"); err == nil {
+			t.Errorf("retries %d: expected an error", retries)
+		}
+		if linkCalls != retries+1 {
+			t.Errorf("retries %d: expected %d link calls, but got: %d", retries, retries+1, linkCalls)
+		}
+	}
+	richMenuLinkRetries = 3
+}
+
+// TestRichMenuIDsFromEnv tests that the rich menu IDs linked for users come
+// from RICHMENU_CONNECT_ID and RICHMENU_MAIN_ID.
+func TestRichMenuIDsFromEnv(t *testing.T) {
+	defer func() { richMenuConnect, richMenuMain = "", "" }()
+	t.Setenv("RICHMENU_CONNECT_ID", "richmenu-connect-env")
+	t.Setenv("RICHMENU_MAIN_ID", "richmenu-main-env")
+	loadConfig()
+	if richMenuMain != "richmenu-main-env" {
+		t.Errorf("Expected the main menu from the env, but got: %q", richMenuMain)
+	}
+
+	var linked []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			linked = append(linked, r.URL.Path)
+			w.Write([]byte("{}"))
+		case "GET":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(&messaging_api.RichMenuIdResponse{RichMenuId: "richmenu-connect-env"})
+		}
+	}))
+	defer server.Close()
+	bot, err := messaging_api.NewMessagingApiAPI("token", messaging_api.WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create mock bot: %v", err)
+	}
+
+	handleEvent(context.Background(), bot, nil, "example.com", webhook.FollowEvent{Source: webhook.UserSource{UserId: "U1"}})
+	if len(linked) != 1 || linked[0] != "/v2/bot/user/U1/richmenu/richmenu-connect-env" {
+		t.Errorf("Expected the connect menu from the env to be linked, but got: %v", linked)
+	}
+}
+
+// TestSetUserMenuNotConfigured tests that linking an unset menu fails
+// without calling LINE.
+func TestSetUserMenuNotConfigured(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+	}))
+	defer server.Close()
+	bot, err := messaging_api.NewMessagingApiAPI("token", messaging_api.WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create mock bot: %v", err)
+	}
+
+	if err := setUserMenu(bot, "U1", ""); !errors.Is(err, errRichMenuNotConfigured) {
+		t.Errorf("Expected errRichMenuNotConfigured, but got: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+// maxRootFolderNameLen keeps /setfolder names to a reasonable length.
+const maxRootFolderNameLen = 100
+
+// rootFolderName returns the top-level folder uploads go under: the name
+// set with /setfolder, or mainFolderName.
+func rootFolderName(prefs *userPrefs) string {
+	if prefs.RootFolderName != "" {
+		return prefs.RootFolderName
+	}
+	return mainFolderName
+}
+
+// userRootFolderName loads the user's prefs and returns their root folder
+// name, falling back to mainFolderName when the prefs can't be read.
+func userRootFolderName(ctx context.Context, userID string) string {
+	prefs, err := getUserPrefs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load prefs for user %s, using defaults: %v", userID, err)
+		return mainFolderName
+	}
+	return rootFolderName(prefs)
+}
+
+// handleSetFolderCommand sets the top-level upload folder by name, e.g.
+// "/setfolder Receipts". "/setfolder reset" goes back to the default.
+func handleSetFolderCommand(ctx context.Context, bot botClient, replyToken, userID string, args []string) {
+	name := strings.TrimSpace(strings.Join(args, " "))
+	if name == "" {
+		replyText(bot, replyToken, tr(ctx, userID, "setfolder.usage"))
+		return
+	}
+	if len([]rune(name)) > maxRootFolderNameLen {
+		replyText(bot, replyToken, trf(ctx, userID, "setfolder.too_long", maxRootFolderNameLen))
+		return
+	}
+
+	value := name
+	if name == "reset" {
+		value = ""
+		name = mainFolderName
+	}
+	if err := updateUserPrefs(ctx, userID, map[string]interface{}{"root_folder_name": value}); err != nil {
+		log.Printf("Failed to set root folder for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "error.save_setting"))
+		return
+	}
+	replyText(bot, replyToken, trf(ctx, userID, "setfolder.done", name))
+}
+
+// handleCurrentFolderCommand replies with the active top-level folder and
+// a link to it when it exists.
+func handleCurrentFolderCommand(ctx context.Context, bot botClient, replyToken, userID string) {
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+	prefs, err := getUserPrefs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load prefs for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "error.read_settings"))
+		return
+	}
+
+	name := rootFolderName(prefs)
+	folderID, err := findFolder(ctx, srv, name, "root")
+	if err != nil {
+		log.Printf("Failed to find root folder for user %s: %v", userID, err)
+		if !replyForError(ctx, bot, replyToken, userID, err) {
+			replyText(bot, replyToken, tr(ctx, userID, "error.lookup_folders"))
+		}
+		return
+	}
+
+	text := trf(ctx, userID, "currentfolder.name", name)
+	if folderID != "" {
+		text += "\n" + folderURL(folderID)
+	} else {
+		text += "\n" + tr(ctx, userID, "currentfolder.missing")
+	}
+	replyText(bot, replyToken, text)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestResolveDestinationRootFolder tests that uploads go under the folder
+// set with /setfolder and that a missing folder is recreated.
+func TestResolveDestinationRootFolder(t *testing.T) {
+	var created []*drive.File
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			q := r.URL.Query().Get("q")
+			if strings.Contains(q, "name='"+mainFolderName+"'") {
+				t.Errorf("Expected the default folder not to be used, but got query: %s", q)
+			}
+			// Nothing exists yet, as if the user deleted the folder.
+			json.NewEncoder(w).Encode(&drive.FileList{})
+		case http.MethodPost:
+			var f drive.File
+			json.NewDecoder(r.Body).Decode(&f)
+			f.Id = f.Name + "_id"
+			created = append(created, &f)
+			json.NewEncoder(w).Encode(&f)
+		}
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	folderID, err := resolveDestination(context.Background(), srv, &userPrefs{RootFolderName: "Receipts"}, uploadOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	month := dateFolderName(time.Now())
+	if folderID != month+"_id" {
+		t.Errorf("Expected month folder id, but got: %s", folderID)
+	}
+	if len(created) != 2 || created[0].Name != "Receipts" || created[0].Parents[0] != "root" || created[1].Parents[0] != "Receipts_id" {
+		t.Errorf("Expected Receipts to be recreated under root with the month folder inside, but got: %+v", created)
+	}
+}
+
+// TestGetRecentFilesRootFolder tests that files are listed from the folder
+// set with /setfolder rather than the default one.
+func TestGetRecentFilesRootFolder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		q := r.URL.Query().Get("q")
+		switch {
+		case strings.Contains(q, "name='Receipts'"):
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "receipts_id"}}})
+		case strings.Contains(q, "mimeType='application/vnd.google-apps.folder'"):
+			t.Errorf("Expected only the Receipts folder to be looked up, but got query: %s", q)
+			json.NewEncoder(w).Encode(&drive.FileList{})
+		case strings.Contains(q, "'receipts_id' in parents"):
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "file_1", Name: "receipt.jpg"}}})
+		default:
+			t.Errorf("Unexpected query: %s", q)
+			json.NewEncoder(w).Encode(&drive.FileList{})
+		}
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	files, _, err := getRecentFiles(context.Background(), srv, rootFolderName(&userPrefs{RootFolderName: "Receipts"}), recentFilesPageSize, "")
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if len(files) != 1 || files[0].Id != "file_1" {
+		t.Errorf("Expected the file in Receipts, but got: %+v", files)
+	}
+}
+
+// TestRootFolderName tests the fallback to the default folder.
+func TestRootFolderName(t *testing.T) {
+	if got := rootFolderName(&userPrefs{}); got != mainFolderName {
+		t.Errorf("Expected %q, but got %q", mainFolderName, got)
+	}
+	if got := rootFolderName(&userPrefs{RootFolderName: "Receipts"}); got != "Receipts" {
+		t.Errorf("Expected %q, but got %q", "Receipts", got)
+	}
+}
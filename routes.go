@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+)
+
+// routeTypes maps the type names accepted by /route to media types.
+var routeTypes = map[string]string{
+	"images": mediaTypeImage,
+	"videos": mediaTypeVideo,
+	"audio":  mediaTypeAudio,
+	"files":  mediaTypeFile,
+}
+
+// handleRouteCommand maps a media type to a folder under the main folder,
+// e.g. "/route images Photos". "/route images clear" removes the mapping.
+func handleRouteCommand(ctx context.Context, bot botClient, replyToken, userID string, args []string) {
+	usage := tr(ctx, userID, "route.usage")
+	if len(args) < 2 {
+		replyText(bot, replyToken, usage)
+		return
+	}
+	mediaType, ok := routeTypes[args[0]]
+	if !ok {
+		replyText(bot, replyToken, usage)
+		return
+	}
+
+	folder := sanitizeFilename(strings.Join(args[1:], " "))
+	var value interface{} = folder
+	reply := trf(ctx, userID, "route.set", args[0], userRootFolderName(ctx, userID), folder)
+	if folder == "clear" {
+		value = firestore.Delete
+		reply = trf(ctx, userID, "route.cleared", args[0])
+	}
+
+	if err := updateUserPrefs(ctx, userID, map[string]interface{}{
+		"routes": map[string]interface{}{mediaType: value},
+	}); err != nil {
+		log.Printf("Failed to save route for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "route.save_failed"))
+		return
+	}
+	replyText(bot, replyToken, reply)
+}
+
+// handleRoutesCommand lists the user's current type-to-folder mappings.
+func handleRoutesCommand(ctx context.Context, bot botClient, replyToken, userID string) {
+	prefs, err := getUserPrefs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load prefs for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "route.load_failed"))
+		return
+	}
+	replyText(bot, replyToken, formatRoutes(userLanguage(ctx, userID), rootFolderName(prefs), prefs.Routes))
+}
+
+func formatRoutes(lang, rootName string, routes map[string]string) string {
+	if len(routes) == 0 {
+		return translate(lang, "route.none")
+	}
+
+	var names []string
+	for name := range routeTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(translate(lang, "route.list"))
+	for _, name := range names {
+		if folder, ok := routes[routeTypes[name]]; ok {
+			fmt.Fprintf(&sb, "\n%s → %s/%s", name, rootName, folder)
+		}
+	}
+	return sb.String()
+}
+
+// routeFolderFor returns the folder the user routed mediaType to, or an
+// empty string when the type is unmapped.
+func routeFolderFor(ctx context.Context, userID, mediaType string) string {
+	prefs, err := getUserPrefs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load prefs for user %s: %v", userID, err)
+		return ""
+	}
+	return prefs.Routes[mediaType]
+}
@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+// TestFormatRoutes tests the /routes listing.
+func TestFormatRoutes(t *testing.T) {
+	if got := formatRoutes(langEn, mainFolderName, nil); got != "No routes set. All uploads go to the default folder." {
+		t.Errorf("Unexpected empty listing: '%s'", got)
+	}
+
+	got := formatRoutes(langEn, "Receipts", map[string]string{mediaTypeVideo: "Clips", mediaTypeImage: "Photos"})
+	want := "Current routes:\nimages → Receipts/Photos\nvideos → Receipts/Clips"
+	if got != want {
+		t.Errorf("Expected '%s', but got: '%s'", want, got)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// sandboxFolderName is where uploads go while /sandbox is on. It sits next
+// to the main folder so trying the bot never touches real uploads.
+const sandboxFolderName = "LINE Bot Sandbox"
+
+// handleSandboxCommand turns sandbox mode on or off, or trashes the
+// sandbox contents with "/sandbox clear".
+func handleSandboxCommand(ctx context.Context, bot botClient, replyToken, userID string, args []string) {
+	if len(args) != 1 {
+		replyText(bot, replyToken, tr(ctx, userID, "sandbox.usage"))
+		return
+	}
+
+	switch args[0] {
+	case "on", "off":
+		on := args[0] == "on"
+		if err := updateUserPrefs(ctx, userID, map[string]interface{}{"sandbox": on}); err != nil {
+			log.Printf("Failed to set sandbox for user %s: %v", userID, err)
+			replyText(bot, replyToken, tr(ctx, userID, "error.save_setting"))
+			return
+		}
+		if on {
+			replyText(bot, replyToken, trf(ctx, userID, "sandbox.on", sandboxFolderName))
+		} else {
+			replyText(bot, replyToken, tr(ctx, userID, "sandbox.off"))
+		}
+	case "clear":
+		srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+		if !ok {
+			return
+		}
+		n, err := clearSandbox(ctx, srv)
+		if err != nil {
+			log.Printf("Failed to clear sandbox for user %s: %v", userID, err)
+			if !replyForError(ctx, bot, replyToken, userID, err) {
+				replyText(bot, replyToken, tr(ctx, userID, "sandbox.clear_failed"))
+			}
+			return
+		}
+		replyText(bot, replyToken, trf(ctx, userID, "sandbox.cleared", n))
+	default:
+		replyText(bot, replyToken, tr(ctx, userID, "sandbox.usage"))
+	}
+}
+
+// isSandboxEnabled reports whether the user's uploads go to the sandbox.
+func isSandboxEnabled(ctx context.Context, userID string) bool {
+	prefs, err := getUserPrefs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load prefs for user %s: %v", userID, err)
+		return false
+	}
+	return prefs.Sandbox
+}
+
+// clearSandbox moves everything in the sandbox folder to the trash and
+// returns how many files were trashed.
+func clearSandbox(ctx context.Context, srv *drive.Service) (int, error) {
+	folderID, err := findFolder(ctx, srv, sandboxFolderName, "root")
+	if err != nil || folderID == "" {
+		return 0, err
+	}
+
+	query := fmt.Sprintf("'%s' in parents and trashed=false", folderID)
+	trashed := 0
+	for {
+		// Trashed files drop out of the query, so always read the first page.
+		r, err := scopeFileList(srv.Files.List()).Q(query).PageSize(100).Fields("files(id)").Context(ctx).Do()
+		if err != nil {
+			return trashed, fmt.Errorf("failed to list sandbox files: %w", err)
+		}
+		if len(r.Files) == 0 {
+			return trashed, nil
+		}
+		for _, f := range r.Files {
+			if _, err := srv.Files.Update(f.Id, &drive.File{Trashed: true}).SupportsAllDrives(supportsAllDrives()).Context(ctx).Do(); err != nil {
+				return trashed, fmt.Errorf("failed to trash sandbox file '%s': %w", f.Id, err)
+			}
+			trashed++
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestClearSandbox tests that every file in the sandbox is trashed.
+func TestClearSandbox(t *testing.T) {
+	remaining := []*drive.File{{Id: "a"}, {Id: "b"}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && strings.Contains(r.URL.Query().Get("q"), "mimeType"):
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "sandbox_id"}}})
+		case r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(&drive.FileList{Files: remaining})
+		case r.Method == http.MethodPatch:
+			var f drive.File
+			json.NewDecoder(r.Body).Decode(&f)
+			if !f.Trashed {
+				t.Errorf("Expected file to be trashed")
+			}
+			remaining = remaining[1:]
+			json.NewEncoder(w).Encode(&drive.File{})
+		}
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	n, err := clearSandbox(context.Background(), srv)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 files trashed, but got: %d", n)
+	}
+}
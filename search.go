@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// searchMaxResults caps /search results to what fits in one carousel.
+const searchMaxResults = 10
+
+func handleSearchCommand(ctx context.Context, bot botClient, replyToken, userID string, args []string) {
+	keyword := strings.Join(args, " ")
+	if keyword == "" {
+		replyText(bot, replyToken, tr(ctx, userID, "search.usage"))
+		return
+	}
+
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	files, err := searchFiles(ctx, srv, userRootFolderName(ctx, userID), keyword, searchMaxResults)
+	if err != nil {
+		log.Printf("Failed to search files: %v", err)
+		replyForError(ctx, bot, replyToken, userID, err)
+		return
+	}
+
+	if len(files) == 0 {
+		replyText(bot, replyToken, trf(ctx, userID, "search.none", keyword))
+		return
+	}
+
+	loadSharing(ctx, srv, files)
+	if err := sendReply(bot, replyToken, []messaging_api.MessageInterface{
+		&messaging_api.FlexMessage{
+			AltText:  trf(ctx, userID, "search.alt", keyword),
+			Contents: buildFilesCarousel(userLanguage(ctx, userID), files),
+		},
+	}); err != nil {
+		log.Print(err)
+	}
+}
+
+// searchFiles returns up to count files whose name contains keyword,
+// newest first, in the rootName folder or any folder directly under it.
+func searchFiles(ctx context.Context, srv *drive.Service, rootName, keyword string, count int64) ([]*drive.File, error) {
+	if count > searchMaxResults {
+		count = searchMaxResults
+	}
+
+	mainFolderID, err := findOrCreateFolder(ctx, srv, rootName, "root")
+	if err != nil {
+		return nil, fmt.Errorf("could not find or create the main upload folder: %w", err)
+	}
+	folders, err := listUploadFolders(ctx, srv, rootName)
+	if err != nil {
+		return nil, err
+	}
+
+	parents := []string{fmt.Sprintf("'%s' in parents", mainFolderID)}
+	for _, f := range folders {
+		parents = append(parents, fmt.Sprintf("'%s' in parents", f.Id))
+	}
+	query := fmt.Sprintf("name contains '%s' and mimeType!='application/vnd.google-apps.folder' and trashed=false and (%s)",
+		escapeQueryValue(keyword), strings.Join(parents, " or "))
+
+	r, err := scopeFileList(srv.Files.List()).
+		Q(query).
+		PageSize(count).
+		OrderBy("createdTime desc").
+		Fields(googleapi.Field(listingFields())).
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to search files: %w", err)
+	}
+	return r.Files, nil
+}
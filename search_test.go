@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestSearchFiles tests that the keyword is escaped and the search covers
+// the main folder and its subfolders.
+func TestSearchFiles(t *testing.T) {
+	var searchQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		q := r.URL.Query().Get("q")
+		switch {
+		case strings.Contains(q, "name contains"):
+			searchQuery = q
+			if got := r.URL.Query().Get("pageSize"); got != "10" {
+				t.Errorf("Expected page size capped at 10, but got: %s", got)
+			}
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "match"}}})
+		case strings.Contains(q, "'root' in parents"):
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "main_id"}}})
+		default:
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "month_id"}}})
+		}
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	files, err := searchFiles(context.Background(), srv, mainFolderName, "Bob's invoice", 50)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("Expected 1 file, but got: %d", len(files))
+	}
+	for _, want := range []string{`name contains 'Bob\'s invoice'`, "'main_id' in parents", "'month_id' in parents"} {
+		if !strings.Contains(searchQuery, want) {
+			t.Errorf("Expected %q in query, got: %s", want, searchQuery)
+		}
+	}
+}
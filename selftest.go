@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const selfTestContent = "LINE Bot self-test"
+
+// selfTestStep is the outcome of one step of /selftest. Name is also the
+// step's message key under "selftest.".
+type selfTestStep struct {
+	Name string
+	Err  error
+}
+
+func handleSelfTestCommand(ctx context.Context, bot botClient, replyToken, userID string) {
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	steps := runSelfTest(ctx, srv, userRootFolderName(ctx, userID))
+
+	var sb strings.Builder
+	sb.WriteString(tr(ctx, userID, "selftest.results"))
+	for _, step := range steps {
+		if step.Err != nil {
+			log.Printf("Self-test step %q failed for user %s: %v", step.Name, userID, step.Err)
+			fmt.Fprintf(&sb, "\n❌ %s: %v", tr(ctx, userID, "selftest."+step.Name), step.Err)
+			if c := classify(step.Err); c == ErrTokenInvalid {
+				sendReconnectionPrompt(ctx, bot, replyToken, userID)
+				return
+			}
+		} else {
+			fmt.Fprintf(&sb, "\n✅ %s", tr(ctx, userID, "selftest."+step.Name))
+		}
+	}
+	replyText(bot, replyToken, sb.String())
+}
+
+// runSelfTest performs a full Drive round trip: it resolves the main
+// folder, uploads a small file, reads it back and deletes it. The test file
+// is always deleted once created, even when a later step fails. Steps after
+// the first failure are not run.
+func runSelfTest(ctx context.Context, srv *drive.Service, rootName string) (steps []selfTestStep) {
+	mainFolderID, err := findOrCreateFolder(ctx, srv, rootName, "root")
+	steps = append(steps, selfTestStep{Name: "resolve_folder", Err: err})
+	if err != nil {
+		return steps
+	}
+
+	file, err := srv.Files.Create(&drive.File{
+		Name:    "line-bot-selftest.txt",
+		Parents: []string{mainFolderID},
+	}).SupportsAllDrives(supportsAllDrives()).Media(strings.NewReader(selfTestContent)).Fields("id").Context(ctx).Do()
+	steps = append(steps, selfTestStep{Name: "upload", Err: err})
+	if err != nil {
+		return steps
+	}
+
+	defer func() {
+		err := srv.Files.Delete(file.Id).SupportsAllDrives(supportsAllDrives()).Context(ctx).Do()
+		steps = append(steps, selfTestStep{Name: "delete", Err: err})
+	}()
+
+	steps = append(steps, selfTestStep{Name: "read", Err: readBackSelfTestFile(ctx, srv, file.Id)})
+	return steps
+}
+
+func readBackSelfTestFile(ctx context.Context, srv *drive.Service, fileID string) error {
+	resp, err := srv.Files.Get(fileID).SupportsAllDrives(supportsAllDrives()).Context(ctx).Download()
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if string(b) != selfTestContent {
+		return fmt.Errorf("content mismatch")
+	}
+	return nil
+}
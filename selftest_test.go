@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestRunSelfTestCleansUp tests that the test file is deleted even when
+// reading it back fails.
+func TestRunSelfTestCleansUp(t *testing.T) {
+	deleted := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/files":
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "main_folder_id"}}})
+		case r.Method == "POST" && r.URL.Path == "/upload/drive/v3/files":
+			json.NewEncoder(w).Encode(&drive.File{Id: "test_file_id"})
+		case r.Method == "GET" && r.URL.Path == "/files/test_file_id":
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.Method == "DELETE" && r.URL.Path == "/files/test_file_id":
+			deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	steps := runSelfTest(context.Background(), srv, mainFolderName)
+	if !deleted {
+		t.Error("Expected the test file to be deleted, but it was not.")
+	}
+	if len(steps) != 4 {
+		t.Fatalf("Expected 4 steps, but got: %d", len(steps))
+	}
+	if steps[2].Err == nil {
+		t.Error("Expected the read step to fail")
+	}
+	if steps[3].Err != nil {
+		t.Errorf("Expected the delete step to succeed, but got: %v", steps[3].Err)
+	}
+}
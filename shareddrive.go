@@ -0,0 +1,42 @@
+package main
+
+import (
+	"google.golang.org/api/drive/v3"
+)
+
+// supportsAllDrives reports whether Drive calls must opt in to shared
+// drive items, i.e. whether SHARED_DRIVE_ID is set.
+func supportsAllDrives() bool {
+	return sharedDriveID != ""
+}
+
+// scopeFileList limits a listing to the shared drive when SHARED_DRIVE_ID
+// is set, and to the user's own drive otherwise.
+func scopeFileList(call *drive.FilesListCall) *drive.FilesListCall {
+	if sharedDriveID == "" {
+		return call.SupportsAllDrives(false)
+	}
+	return call.SupportsAllDrives(true).
+		IncludeItemsFromAllDrives(true).
+		Corpora("drive").
+		DriveId(sharedDriveID)
+}
+
+// driveParent resolves the "root" alias callers use for top-level folders
+// to the shared drive, whose ID is also the ID of its root folder.
+func driveParent(parentID string) string {
+	if parentID == "root" && sharedDriveID != "" {
+		return sharedDriveID
+	}
+	return parentID
+}
+
+// folderOwnerClause restricts folder lookups in My Drive to folders the
+// user owns. Shared drive items are owned by the drive, so there it
+// matches every folder.
+func folderOwnerClause() string {
+	if sharedDriveID != "" {
+		return ""
+	}
+	return " and 'me' in owners"
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestFindOrCreateFolderInSharedDrive tests that with SHARED_DRIVE_ID set
+// the folder is looked up and created in the shared drive.
+func TestFindOrCreateFolderInSharedDrive(t *testing.T) {
+	original := sharedDriveID
+	sharedDriveID = "shared_drive_1"
+	defer func() { sharedDriveID = original }()
+
+	var parents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		q := r.URL.Query()
+		if q.Get("supportsAllDrives") != "true" {
+			t.Errorf("Expected supportsAllDrives=true, but got: %q", q.Get("supportsAllDrives"))
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/files":
+			if q.Get("driveId") != "shared_drive_1" || q.Get("corpora") != "drive" || q.Get("includeItemsFromAllDrives") != "true" {
+				t.Errorf("Expected a listing scoped to the shared drive, but got: %s", r.URL.RawQuery)
+			}
+			if !strings.Contains(q.Get("q"), "'shared_drive_1' in parents") || strings.Contains(q.Get("q"), "'me' in owners") {
+				t.Errorf("Expected a query for the shared drive's root, but got: %s", q.Get("q"))
+			}
+			json.NewEncoder(w).Encode(&drive.FileList{})
+		case r.Method == "POST" && r.URL.Path == "/files":
+			var body drive.File
+			b, _ := io.ReadAll(r.Body)
+			json.Unmarshal(b, &body)
+			parents = body.Parents
+			json.NewEncoder(w).Encode(&drive.File{Id: "folder_1"})
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	folderID, err := findOrCreateFolder(context.Background(), srv, mainFolderName, "root")
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if folderID != "folder_1" {
+		t.Errorf("Expected folder_1, but got: %q", folderID)
+	}
+	if len(parents) != 1 || parents[0] != "shared_drive_1" {
+		t.Errorf("Expected the folder to be created in the shared drive's root, but got parents %v", parents)
+	}
+}
+
+// TestCreateWithRetrySharedDrive tests that uploads opt in to shared
+// drives only when SHARED_DRIVE_ID is set.
+func TestCreateWithRetrySharedDrive(t *testing.T) {
+	original := sharedDriveID
+	defer func() { sharedDriveID = original }()
+
+	for _, tt := range []struct {
+		driveID, want string
+	}{
+		{"", "false"},
+		{"shared_drive_1", "true"},
+	} {
+		sharedDriveID = tt.driveID
+		var got string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.URL.Query().Get("supportsAllDrives")
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(&drive.File{Id: "file_1"})
+		}))
+
+		srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+		if err != nil {
+			t.Fatalf("Failed to create mock drive service: %v", err)
+		}
+		if _, err := createWithRetry(context.Background(), srv, &drive.File{Name: "a.txt"}, strings.NewReader("hello")); err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		server.Close()
+		if got != tt.want {
+			t.Errorf("SHARED_DRIVE_ID %q: expected supportsAllDrives=%s, but got: %q", tt.driveID, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRunServerWaitsForRequests tests that shutting down lets a request
+// already being handled finish before runServer returns.
+func TestRunServerWaitsForRequests(t *testing.T) {
+	started := make(chan struct{})
+	var finished atomic.Bool
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		finished.Store(true)
+		io.WriteString(w, "done")
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- runServer(ctx, server, ln) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/")
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		responses <- result{string(b), err}
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("Expected a clean shutdown, but got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runServer did not return after shutdown")
+	}
+	if !finished.Load() {
+		t.Error("Expected runServer to wait for the in-flight request")
+	}
+	if r := <-responses; r.err != nil || r.body != "done" {
+		t.Errorf("Expected the in-flight request to complete, but got %q, %v", r.body, r.err)
+	}
+}
+
+// TestRunServerShutdownTimeout tests that a request outlasting
+// shutdownTimeout doesn't block shutdown forever.
+func TestRunServerShutdownTimeout(t *testing.T) {
+	original := shutdownTimeout
+	defer func() { shutdownTimeout = original }()
+	shutdownTimeout = 50 * time.Millisecond
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- runServer(ctx, server, ln) }()
+	go http.Get("http://" + ln.Addr().String() + "/")
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Error("Expected an error when requests outlast the shutdown timeout")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runServer did not give up after the shutdown timeout")
+	}
+}
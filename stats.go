@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const statsCollection = "user_stats"
+
+// userStats is the per-user upload counter document.
+type userStats struct {
+	UploadCount  int64     `firestore:"upload_count"`
+	TotalBytes   int64     `firestore:"total_bytes"`
+	LastUploadAt time.Time `firestore:"last_upload_at"`
+
+	// OnboardingShown is set once the first-upload tip has been sent.
+	OnboardingShown bool `firestore:"onboarding_shown"`
+}
+
+// recordUpload atomically bumps the user's upload counters. It uses
+// firestore.Increment so concurrent uploads from the same user never lose
+// updates the way a read-modify-write would.
+func recordUpload(ctx context.Context, client *firestore.Client, userID string, size int64) error {
+	_, err := client.Collection(statsCollection).Doc(userID).Set(ctx, map[string]interface{}{
+		"upload_count":   firestore.Increment(1),
+		"total_bytes":    firestore.Increment(size),
+		"last_upload_at": time.Now(),
+	}, firestore.MergeAll)
+	if err != nil {
+		return fmt.Errorf("failed to record upload stats: %w", err)
+	}
+	return nil
+}
+
+// claimOnboarding reports whether the user is about to record their very
+// first upload and hasn't seen the onboarding tip yet, flipping the flag
+// so the tip is only ever shown once. Call it before recordUpload.
+func claimOnboarding(ctx context.Context, client *firestore.Client, userID string) (bool, error) {
+	ref := client.Collection(statsCollection).Doc(userID)
+	first := false
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		first = false
+		var stats userStats
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(&stats); err != nil {
+				return err
+			}
+		}
+		if stats.UploadCount > 0 || stats.OnboardingShown {
+			return nil
+		}
+		first = true
+		return tx.Set(ref, map[string]interface{}{"onboarding_shown": true}, firestore.MergeAll)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to claim onboarding tip: %w", err)
+	}
+	return first, nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// statsCacheTTL is how long /stats results are reused. Building them lists
+// every upload, so repeated requests shouldn't redo the work.
+const statsCacheTTL = 5 * time.Minute
+
+// statsCategories are the media categories /stats counts, in display
+// order.
+var statsCategories = []string{"image", "video", "audio", "other"}
+
+// uploadSummary totals the user's uploads that are not in the trash.
+type uploadSummary struct {
+	Files      int
+	Bytes      int64
+	ByCategory map[string]int
+}
+
+type statsCacheEntry struct {
+	summary *uploadSummary
+	expires time.Time
+}
+
+var (
+	statsCacheMu sync.Mutex
+	statsCache   = map[string]statsCacheEntry{}
+)
+
+// handleStatsCommand replies with how many files the user uploaded, their
+// total size and a count per media category.
+func handleStatsCommand(ctx context.Context, bot botClient, replyToken, userID string) {
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	summary, err := getCachedUploadSummary(ctx, srv, userID, time.Now())
+	if err != nil {
+		log.Printf("Failed to get stats for user %s: %v", userID, err)
+		driveServices.invalidateOnAuthError(userID, err)
+		if !replyForError(ctx, bot, replyToken, userID, err) {
+			replyText(bot, replyToken, tr(ctx, userID, "stats.failed"))
+		}
+		return
+	}
+	if summary.Files == 0 {
+		replyText(bot, replyToken, tr(ctx, userID, "files.none"))
+		return
+	}
+
+	if err := sendReply(bot, replyToken, []messaging_api.MessageInterface{
+		&messaging_api.FlexMessage{
+			AltText:  trf(ctx, userID, "stats.alt", summary.Files, formatBytes(summary.Bytes)),
+			Contents: buildStatsBubble(userLanguage(ctx, userID), summary),
+		},
+	}); err != nil {
+		log.Print(err)
+	}
+}
+
+// getCachedUploadSummary returns the user's upload summary, computing it
+// only when the cached copy is missing or older than statsCacheTTL.
+func getCachedUploadSummary(ctx context.Context, srv *drive.Service, userID string, now time.Time) (*uploadSummary, error) {
+	statsCacheMu.Lock()
+	entry, ok := statsCache[userID]
+	statsCacheMu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.summary, nil
+	}
+
+	summary, err := summarizeUploads(ctx, srv)
+	if err != nil {
+		return nil, err
+	}
+
+	statsCacheMu.Lock()
+	statsCache[userID] = statsCacheEntry{summary: summary, expires: now.Add(statsCacheTTL)}
+	statsCacheMu.Unlock()
+	return summary, nil
+}
+
+// summarizeUploads lists every upload, following all result pages. Uploads
+// carry the upload marker, so this covers the whole upload folder tree,
+// including custom and routed folders, without walking it.
+func summarizeUploads(ctx context.Context, srv *drive.Service) (*uploadSummary, error) {
+	summary := &uploadSummary{ByCategory: map[string]int{}}
+	pageToken := ""
+	for {
+		call := scopeFileList(srv.Files.List()).Q(uploadsQuery).PageSize(1000).Fields("nextPageToken, files(size, mimeType)")
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		r, err := call.Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list uploads: %w", err)
+		}
+		for _, f := range r.Files {
+			summary.Files++
+			summary.Bytes += f.Size
+			summary.ByCategory[mimeCategory(f.MimeType)]++
+		}
+		if r.NextPageToken == "" {
+			return summary, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// mimeCategory maps a MIME type to one of statsCategories.
+func mimeCategory(mimeType string) string {
+	major, _, _ := strings.Cut(mimeType, "/")
+	switch major {
+	case "image", "video", "audio":
+		return major
+	}
+	return "other"
+}
+
+// buildStatsBubble renders the summary as a list of totals.
+func buildStatsBubble(lang string, summary *uploadSummary) *messaging_api.FlexBubble {
+	row := func(label, value string) messaging_api.FlexComponentInterface {
+		return &messaging_api.FlexBox{
+			Layout: "horizontal",
+			Margin: "md",
+			Contents: []messaging_api.FlexComponentInterface{
+				&messaging_api.FlexText{Text: label, Size: "md", Color: cardAccentColor, Flex: 3},
+				&messaging_api.FlexText{Text: value, Size: "md", Align: "end", Flex: 2},
+			},
+		}
+	}
+
+	contents := []messaging_api.FlexComponentInterface{
+		&messaging_api.FlexText{
+			Text:   translate(lang, "stats.title"),
+			Weight: "bold",
+			Size:   "xl",
+		},
+		row(translate(lang, "stats.files"), strconv.Itoa(summary.Files)),
+		row(translate(lang, "stats.total_size"), formatBytes(summary.Bytes)),
+		&messaging_api.FlexSeparator{Margin: "md"},
+	}
+	for _, c := range statsCategories {
+		contents = append(contents, row(translate(lang, "stats."+c), strconv.Itoa(summary.ByCategory[c])))
+	}
+
+	return &messaging_api.FlexBubble{
+		Body: &messaging_api.FlexBox{
+			Layout:   "vertical",
+			Contents: contents,
+		},
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestRecordUploadConcurrent fires concurrent increments against the
+// Firestore emulator and checks that none are lost.
+func TestRecordUploadConcurrent(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST not set")
+	}
+
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("Failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	userID := "concurrent-user"
+	client.Collection(statsCollection).Doc(userID).Delete(ctx)
+
+	const workers = 10
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := recordUpload(ctx, client, userID, 100); err != nil {
+				t.Errorf("Expected no error, but got: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	doc, err := client.Collection(statsCollection).Doc(userID).Get(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read stats: %v", err)
+	}
+	var stats userStats
+	if err := doc.DataTo(&stats); err != nil {
+		t.Fatalf("Failed to parse stats: %v", err)
+	}
+	if stats.UploadCount != workers {
+		t.Errorf("Expected upload count %d, but got: %d", workers, stats.UploadCount)
+	}
+	if stats.TotalBytes != workers*100 {
+		t.Errorf("Expected total bytes %d, but got: %d", workers*100, stats.TotalBytes)
+	}
+}
+
+// TestClaimOnboarding checks against the Firestore emulator that the tip
+// is claimed only for the first upload.
+func TestClaimOnboarding(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST not set")
+	}
+
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("Failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	userID := "onboarding-user"
+	client.Collection(statsCollection).Doc(userID).Delete(ctx)
+
+	for i, want := range []bool{true, false} {
+		got, err := claimOnboarding(ctx, client, userID)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		if got != want {
+			t.Errorf("claim %d: expected %v, but got: %v", i, want, got)
+		}
+	}
+}
+
+// TestSummarizeUploads tests the aggregation across several result pages.
+func TestSummarizeUploads(t *testing.T) {
+	pages := map[string]string{
+		"":      `{"nextPageToken": "page2", "files": [{"size": "100", "mimeType": "image/jpeg"}, {"size": "200", "mimeType": "video/mp4"}]}`,
+		"page2": `{"files": [{"size": "50", "mimeType": "image/png"}, {"size": "25", "mimeType": "audio/m4a"}, {"size": "5", "mimeType": "application/pdf"}]}`,
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		q := r.URL.Query()
+		if q.Get("q") != uploadsQuery {
+			t.Errorf("Expected the uploads query, but got: %q", q.Get("q"))
+		}
+		if !strings.Contains(q.Get("fields"), "size") || !strings.Contains(q.Get("fields"), "mimeType") {
+			t.Errorf("Expected size and mimeType to be requested, but got: %q", q.Get("fields"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(pages[q.Get("pageToken")]))
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	summary, err := summarizeUploads(context.Background(), srv)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected both pages to be fetched, but got %d requests", requests)
+	}
+	if summary.Files != 5 || summary.Bytes != 380 {
+		t.Errorf("Expected 5 files and 380 bytes, but got %d files and %d bytes", summary.Files, summary.Bytes)
+	}
+	want := map[string]int{"image": 2, "video": 1, "audio": 1, "other": 1}
+	for c, n := range want {
+		if summary.ByCategory[c] != n {
+			t.Errorf("Expected %d %s files, but got %d", n, c, summary.ByCategory[c])
+		}
+	}
+}
+
+// TestGetCachedUploadSummary tests that a recent summary is reused.
+func TestGetCachedUploadSummary(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"files": [{"size": "1", "mimeType": "image/jpeg"}]}`))
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	userID := "stats-cache-user"
+	defer func() {
+		statsCacheMu.Lock()
+		delete(statsCache, userID)
+		statsCacheMu.Unlock()
+	}()
+	now := time.Now()
+	for _, at := range []time.Time{now, now.Add(time.Minute), now.Add(statsCacheTTL + time.Second)} {
+		if _, err := getCachedUploadSummary(context.Background(), srv, userID, at); err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("Expected the cache to save one listing, but got %d requests", requests)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const tokenHealthCollection = "token_health"
+
+// tokenHealth records the last health check result for a user's token.
+type tokenHealth struct {
+	LastCheckedAt time.Time `firestore:"last_checked_at"`
+	Healthy       bool      `firestore:"healthy"`
+}
+
+// requireTasksSecret guards scheduled-task endpoints. They are meant to be
+// called by Cloud Scheduler with "Authorization: Bearer <TASKS_SECRET>" and
+// are disabled entirely when TASKS_SECRET is unset.
+func requireTasksSecret(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if tasksSecret == "" {
+			http.NotFound(w, r)
+			return
+		}
+		got := r.Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(got), []byte("Bearer "+tasksSecret)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// tokenCheckHandler scans stored tokens and pushes a reconnection prompt to
+// users whose token is about to stop working.
+func tokenCheckHandler(bot botClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		notified, err := checkExpiringTokens(r.Context(), bot, time.Now())
+		if err != nil {
+			log.Printf("Token check failed: %v", err)
+			http.Error(w, "Token check failed.", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "notified %d users", notified)
+	}
+}
+
+func checkExpiringTokens(ctx context.Context, bot botClient, now time.Time) (int, error) {
+	iter := firestoreClient.Collection(tokenCollection).Documents(ctx)
+	defer iter.Stop()
+
+	notified := 0
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return notified, fmt.Errorf("failed to iterate tokens: %w", err)
+		}
+
+		var token storedToken
+		if err := doc.DataTo(&token); err != nil {
+			log.Printf("Failed to parse token data for user %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		if err := openStoredToken(&token); err != nil {
+			log.Printf("Failed to decrypt token for user %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		if !shouldNotifyExpiry(&token, now, tokenExpiryWindow) {
+			continue
+		}
+
+		if err := pushReconnectionPrompt(ctx, bot, doc.Ref.ID); err != nil {
+			log.Printf("Failed to push reconnection prompt to user %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		notified++
+		if _, err := doc.Ref.Set(ctx, map[string]interface{}{"last_notified": now}, firestore.MergeAll); err != nil {
+			log.Printf("Failed to record expiry notice for user %s: %v", doc.Ref.ID, err)
+		}
+	}
+	return notified, nil
+}
+
+// shouldNotifyExpiry reports whether the user should be warned about a
+// token nearing failure. Each token is only warned about once, so running
+// the check more often than tokenExpiryWindow doesn't repeat the message.
+func shouldNotifyExpiry(token *storedToken, now time.Time, window time.Duration) bool {
+	if !tokenNearingFailure(&token.Token, now, window) {
+		return false
+	}
+	return token.LastNotified.Before(token.Expiry.Add(-window))
+}
+
+// tokenNearingFailure reports whether a token cannot be refreshed and its
+// access token expires within the window. Tokens that already expired are
+// skipped so users are not prompted on every run.
+func tokenNearingFailure(token *oauth2.Token, now time.Time, window time.Duration) bool {
+	if token.RefreshToken != "" || token.Expiry.IsZero() {
+		return false
+	}
+	return token.Expiry.After(now) && !token.Expiry.After(now.Add(window))
+}
+
+// tokenHealthHandler actively verifies every stored token with a cheap
+// About.Get call. Users whose refresh token was rejected get a
+// reconnection prompt and are switched back to the connect rich menu.
+func tokenHealthHandler(bot botClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		checked, broken, err := checkTokenHealth(r.Context(), bot, time.Now())
+		if err != nil {
+			log.Printf("Token health check failed: %v", err)
+			http.Error(w, "Token health check failed.", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "checked %d tokens, %d broken", checked, broken)
+	}
+}
+
+func checkTokenHealth(ctx context.Context, bot botClient, now time.Time) (checked, broken int, err error) {
+	iter := firestoreClient.Collection(tokenCollection).Documents(ctx)
+	defer iter.Stop()
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return checked, broken, fmt.Errorf("failed to iterate tokens: %w", err)
+		}
+		userID := doc.Ref.ID
+
+		healthRef := firestoreClient.Collection(tokenHealthCollection).Doc(userID)
+		previous, found := loadTokenHealth(ctx, healthRef)
+		if found && now.Sub(previous.LastCheckedAt) < tokenHealthMinInterval {
+			continue
+		}
+
+		var token storedToken
+		if err := doc.DataTo(&token); err != nil {
+			log.Printf("Failed to parse token data for user %s: %v", userID, err)
+			continue
+		}
+		if err := openStoredToken(&token); err != nil {
+			log.Printf("Failed to decrypt token for user %s: %v", userID, err)
+			continue
+		}
+
+		// Throttle between users to stay well inside the Drive API quota.
+		if checked > 0 {
+			time.Sleep(tokenHealthThrottle)
+		}
+		checked++
+
+		healthy := true
+		srv, err := newDriveService(ctx, &token.Token)
+		if err == nil {
+			_, err = srv.About.Get().Fields("user").Context(ctx).Do()
+		}
+		if err != nil {
+			if !isInvalidGrantError(err) {
+				// Transient failures say nothing about the token itself.
+				log.Printf("Token health check inconclusive for user %s: %v", userID, err)
+				continue
+			}
+			healthy = false
+			broken++
+			log.Printf("Token for user %s is no longer valid: %v", userID, err)
+			// Only notify on the transition to broken so users are not
+			// prompted again on every run.
+			if !found || previous.Healthy {
+				if err := pushReconnectionPrompt(ctx, bot, userID); err != nil {
+					log.Printf("Failed to push reconnection prompt to user %s: %v", userID, err)
+				}
+				if err := setUserMenu(bot, userID, richMenuConnect); err != nil {
+					log.Printf("Failed to link rich menu for user %s: %v", userID, err)
+				}
+			}
+		}
+
+		if _, err := healthRef.Set(ctx, tokenHealth{LastCheckedAt: now, Healthy: healthy}); err != nil {
+			log.Printf("Failed to record token health for user %s: %v", userID, err)
+		}
+	}
+	return checked, broken, nil
+}
+
+// loadTokenHealth returns the previous health check result for a user, if
+// there is one.
+func loadTokenHealth(ctx context.Context, ref *firestore.DocumentRef) (tokenHealth, bool) {
+	var health tokenHealth
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		if status.Code(err) != codes.NotFound {
+			log.Printf("Failed to read token health for user %s: %v", ref.ID, err)
+		}
+		return health, false
+	}
+	if err := doc.DataTo(&health); err != nil {
+		return health, false
+	}
+	return health, true
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// TestTokenNearingFailure tests which tokens trigger a reconnection prompt.
+func TestTokenNearingFailure(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		token oauth2.Token
+		want  bool
+	}{
+		{"refreshable", oauth2.Token{RefreshToken: "r", Expiry: now.Add(time.Hour)}, false},
+		{"expiring soon", oauth2.Token{Expiry: now.Add(time.Hour)}, true},
+		{"expiring later", oauth2.Token{Expiry: now.Add(48 * time.Hour)}, false},
+		{"already expired", oauth2.Token{Expiry: now.Add(-time.Hour)}, false},
+		{"no expiry", oauth2.Token{}, false},
+	}
+	for _, tt := range tests {
+		if got := tokenNearingFailure(&tt.token, now, 24*time.Hour); got != tt.want {
+			t.Errorf("%s: expected %t, but got: %t", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestShouldNotifyExpiry tests the notification decision for expiry and
+// refresh-token combinations, and that a token is only warned about once.
+func TestShouldNotifyExpiry(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	window := 24 * time.Hour
+	expiring := oauth2.Token{Expiry: now.Add(time.Hour)}
+	tests := []struct {
+		name  string
+		token storedToken
+		want  bool
+	}{
+		{"expiring, never notified", storedToken{Token: expiring}, true},
+		{"expiring, refreshable", storedToken{Token: oauth2.Token{RefreshToken: "r", Expiry: now.Add(time.Hour)}}, false},
+		{"expiring later", storedToken{Token: oauth2.Token{Expiry: now.Add(48 * time.Hour)}}, false},
+		{"already expired", storedToken{Token: oauth2.Token{Expiry: now.Add(-time.Hour)}}, false},
+		{"no expiry", storedToken{}, false},
+		{"notified in this window", storedToken{Token: expiring, LastNotified: now.Add(-2 * time.Hour)}, false},
+		{"notified for an earlier token", storedToken{Token: expiring, LastNotified: now.Add(-72 * time.Hour)}, true},
+	}
+	for _, tt := range tests {
+		if got := shouldNotifyExpiry(&tt.token, now, window); got != tt.want {
+			t.Errorf("%s: expected %t, but got: %t", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestRequireTasksSecret tests the scheduled task authorization check.
+func TestRequireTasksSecret(t *testing.T) {
+	handler := requireTasksSecret(func(w http.ResponseWriter, r *http.Request) {})
+
+	tasksSecret = ""
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("POST", "/tasks/check_tokens", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 when disabled, but got: %d", rec.Code)
+	}
+
+	tasksSecret = "secret"
+	defer func() { tasksSecret = "" }()
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("POST", "/tasks/check_tokens", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without secret, but got: %d", rec.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/tasks/check_tokens", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 with secret, but got: %d", rec.Code)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+// tokenEncryptionKey is the AES key used to encrypt OAuth tokens before they
+// are written to Firestore. It is loaded from TOKEN_ENCRYPTION_KEY at startup.
+var tokenEncryptionKey []byte
+
+var errTokenKeyMissing = errors.New("TOKEN_ENCRYPTION_KEY environment variable must be set")
+
+// parseTokenEncryptionKey decodes a base64 AES-128, AES-192 or AES-256 key.
+func parseTokenEncryptionKey(value string) ([]byte, error) {
+	if value == "" {
+		return nil, errTokenKeyMissing
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("TOKEN_ENCRYPTION_KEY is not valid base64: %w", err)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("TOKEN_ENCRYPTION_KEY must decode to 16, 24 or 32 bytes, got %d", len(key))
+	}
+}
+
+func newTokenCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, errTokenKeyMissing
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptToken seals the JSON encoding of token with AES-GCM. The returned
+// nonce must be stored alongside the ciphertext to decrypt it again.
+func encryptToken(key []byte, token *oauth2.Token) (ciphertext, nonce []byte, err error) {
+	aead, err := newTokenCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode token: %w", err)
+	}
+	nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nil, nonce, plaintext, nil), nonce, nil
+}
+
+// decryptToken reverses encryptToken.
+func decryptToken(key, ciphertext, nonce []byte) (*oauth2.Token, error) {
+	aead, err := newTokenCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length %d", len(nonce))
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt token: %w", err)
+	}
+	var token oauth2.Token
+	if err := json.Unmarshal(plaintext, &token); err != nil {
+		return nil, fmt.Errorf("failed to decode token: %w", err)
+	}
+	return &token, nil
+}
+
+// sealStoredToken returns a copy of st ready to be written to Firestore:
+// the OAuth fields are cleared and replaced by their ciphertext.
+func sealStoredToken(st *storedToken) (*storedToken, error) {
+	ciphertext, nonce, err := encryptToken(tokenEncryptionKey, &st.Token)
+	if err != nil {
+		return nil, err
+	}
+	sealed := *st
+	sealed.Token = oauth2.Token{}
+	sealed.Ciphertext = ciphertext
+	sealed.Nonce = nonce
+	sealed.Encrypted = true
+	return &sealed, nil
+}
+
+// openStoredToken decrypts st in place. Documents written before tokens were
+// encrypted are left untouched.
+func openStoredToken(st *storedToken) error {
+	if !st.Encrypted {
+		return nil
+	}
+	token, err := decryptToken(tokenEncryptionKey, st.Ciphertext, st.Nonce)
+	if err != nil {
+		return err
+	}
+	st.Token = *token
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+var testTokenKey = bytes.Repeat([]byte{0x42}, 32)
+
+// TestEncryptTokenRoundTrip tests that a token survives encryption and that
+// each encryption uses a fresh nonce.
+func TestEncryptTokenRoundTrip(t *testing.T) {
+	token := &oauth2.Token{
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		TokenType:    "Bearer",
+		Expiry:       time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	ciphertext, nonce, err := encryptToken(testTokenKey, token)
+	if err != nil {
+		t.Fatalf("encryptToken failed: %v", err)
+	}
+	if bytes.Contains(ciphertext, []byte("refresh")) {
+		t.Error("Expected ciphertext not to contain the refresh token")
+	}
+
+	got, err := decryptToken(testTokenKey, ciphertext, nonce)
+	if err != nil {
+		t.Fatalf("decryptToken failed: %v", err)
+	}
+	if got.AccessToken != token.AccessToken || got.RefreshToken != token.RefreshToken ||
+		got.TokenType != token.TokenType || !got.Expiry.Equal(token.Expiry) {
+		t.Errorf("Expected %+v, but got: %+v", token, got)
+	}
+
+	_, nonce2, err := encryptToken(testTokenKey, token)
+	if err != nil {
+		t.Fatalf("encryptToken failed: %v", err)
+	}
+	if bytes.Equal(nonce, nonce2) {
+		t.Error("Expected a fresh nonce for each encryption")
+	}
+}
+
+// TestDecryptTokenErrors tests that tampered data and wrong keys are rejected.
+func TestDecryptTokenErrors(t *testing.T) {
+	ciphertext, nonce, err := encryptToken(testTokenKey, &oauth2.Token{AccessToken: "access"})
+	if err != nil {
+		t.Fatalf("encryptToken failed: %v", err)
+	}
+
+	otherKey := bytes.Repeat([]byte{0x24}, 32)
+	if _, err := decryptToken(otherKey, ciphertext, nonce); err == nil {
+		t.Error("Expected an error when decrypting with the wrong key")
+	}
+
+	tampered := append([]byte(nil), ciphertext...)
+	tampered[0] ^= 0xff
+	if _, err := decryptToken(testTokenKey, tampered, nonce); err == nil {
+		t.Error("Expected an error for tampered ciphertext")
+	}
+
+	if _, err := decryptToken(testTokenKey, ciphertext, nonce[:4]); err == nil {
+		t.Error("Expected an error for a short nonce")
+	}
+
+	if _, err := decryptToken(nil, ciphertext, nonce); !errors.Is(err, errTokenKeyMissing) {
+		t.Errorf("Expected errTokenKeyMissing, but got: %v", err)
+	}
+}
+
+// TestParseTokenEncryptionKey tests key validation.
+func TestParseTokenEncryptionKey(t *testing.T) {
+	if _, err := parseTokenEncryptionKey(""); !errors.Is(err, errTokenKeyMissing) {
+		t.Errorf("Expected errTokenKeyMissing, but got: %v", err)
+	}
+	if _, err := parseTokenEncryptionKey("not base64!"); err == nil {
+		t.Error("Expected an error for invalid base64")
+	}
+	if _, err := parseTokenEncryptionKey(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Error("Expected an error for a key of the wrong length")
+	}
+
+	key, err := parseTokenEncryptionKey(base64.StdEncoding.EncodeToString(testTokenKey))
+	if err != nil {
+		t.Fatalf("Expected a valid key, but got: %v", err)
+	}
+	if !bytes.Equal(key, testTokenKey) {
+		t.Errorf("Expected decoded key to match")
+	}
+}
+
+// TestSealStoredToken tests that sealed documents carry no plaintext token
+// fields and open back to the original token.
+func TestSealStoredToken(t *testing.T) {
+	original := tokenEncryptionKey
+	tokenEncryptionKey = testTokenKey
+	defer func() { tokenEncryptionKey = original }()
+
+	st := newStoredToken(&oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}, time.Now())
+	sealed, err := sealStoredToken(st)
+	if err != nil {
+		t.Fatalf("sealStoredToken failed: %v", err)
+	}
+	if !sealed.Encrypted || sealed.AccessToken != "" || sealed.RefreshToken != "" {
+		t.Errorf("Expected an encrypted document without plaintext fields, but got: %+v", sealed)
+	}
+	if st.RefreshToken != "refresh" {
+		t.Error("Expected the original token to be left untouched")
+	}
+
+	if err := openStoredToken(sealed); err != nil {
+		t.Fatalf("openStoredToken failed: %v", err)
+	}
+	if sealed.AccessToken != "access" || sealed.RefreshToken != "refresh" {
+		t.Errorf("Expected the token to be restored, but got: %+v", sealed.Token)
+	}
+
+	legacy := &storedToken{Token: oauth2.Token{AccessToken: "plain"}}
+	if err := openStoredToken(legacy); err != nil || legacy.AccessToken != "plain" {
+		t.Errorf("Expected legacy tokens to pass through, but got: %v, %+v", err, legacy.Token)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tokenSchemaVersion is the current version of the documents stored in
+// tokenCollection. Bump it whenever storedToken gains a field that old
+// documents need backfilled, and extend migrateToken accordingly.
+const tokenSchemaVersion = 1
+
+// storedToken is the document stored in tokenCollection. The embedded
+// oauth2.Token keeps the field names used by documents written before the
+// schema was versioned.
+type storedToken struct {
+	oauth2.Token
+	SchemaVersion int       `firestore:"schema_version"`
+	IssuedAt      time.Time `firestore:"issued_at"`
+	LastUsedAt    time.Time `firestore:"last_used_at"`
+	AccountLabel  string    `firestore:"account_label"`
+	Encrypted     bool      `firestore:"encrypted"`
+	// LastNotified is when the user was last warned that this token is
+	// about to stop working. Saving a new token resets it.
+	LastNotified time.Time `firestore:"last_notified"`
+	// Ciphertext and Nonce hold the AES-GCM encrypted oauth2.Token when
+	// Encrypted is set; the embedded token fields are then left empty.
+	Ciphertext []byte `firestore:"ciphertext"`
+	Nonce      []byte `firestore:"nonce"`
+}
+
+// newStoredToken wraps a freshly issued token in the current schema.
+func newStoredToken(token *oauth2.Token, now time.Time) *storedToken {
+	return &storedToken{
+		Token:         *token,
+		SchemaVersion: tokenSchemaVersion,
+		IssuedAt:      now,
+		LastUsedAt:    now,
+	}
+}
+
+// loadToken reads the user's token document, migrating it to the current
+// schema on the way. Migration failures are logged but do not fail the read.
+func loadToken(ctx context.Context, userID string) (*storedToken, error) {
+	docRef := firestoreClient.Collection(tokenCollection).Doc(userID)
+	doc, err := docRef.Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get token from firestore: %w", err)
+	}
+
+	var token storedToken
+	if err := doc.DataTo(&token); err != nil {
+		return nil, fmt.Errorf("failed to parse token data: %w", err)
+	}
+	if err := openStoredToken(&token); err != nil {
+		return nil, fmt.Errorf("failed to decrypt token for user %s: %w", userID, err)
+	}
+
+	if updates := migrateToken(&token, doc.CreateTime, doc.UpdateTime); len(updates) > 0 {
+		if _, err := docRef.Set(ctx, updates, firestore.MergeAll); err != nil {
+			logger.Error("Failed to migrate token", "userID", userID, "error", err)
+		} else {
+			logger.Info("Migrated token", "userID", userID, "schemaVersion", tokenSchemaVersion)
+		}
+	}
+	if !token.Encrypted {
+		encryptLegacyToken(ctx, docRef, &token)
+	}
+	return &token, nil
+}
+
+// tokenLastUsedInterval is how stale last_used_at may get before a Drive
+// service build writes it again, so busy users don't cost a Firestore
+// write per build.
+const tokenLastUsedInterval = time.Hour
+
+// touchTokenLastUsed records that the user's token was used at now, at
+// most once per tokenLastUsedInterval. Failures are logged but do not fail
+// the caller.
+func touchTokenLastUsed(ctx context.Context, userID string, token *storedToken, now time.Time) {
+	if now.Sub(token.LastUsedAt) < tokenLastUsedInterval {
+		return
+	}
+	docRef := firestoreClient.Collection(tokenCollection).Doc(userID)
+	if _, err := docRef.Set(ctx, map[string]interface{}{"last_used_at": now}, firestore.MergeAll); err != nil {
+		logger.Error("Failed to update token last use", "userID", userID, "error", err)
+		return
+	}
+	token.LastUsedAt = now
+}
+
+// saveToken encrypts token and writes it to the user's token document.
+func saveToken(ctx context.Context, userID string, token *storedToken) error {
+	sealed, err := sealStoredToken(token)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt token: %w", err)
+	}
+	_, err = firestoreClient.Collection(tokenCollection).Doc(userID).Set(ctx, sealed)
+	return err
+}
+
+// encryptLegacyToken rewrites a plaintext document from before tokens were
+// encrypted. Failures are logged; the plaintext token is still usable.
+func encryptLegacyToken(ctx context.Context, docRef *firestore.DocumentRef, token *storedToken) {
+	sealed, err := sealStoredToken(token)
+	if err != nil {
+		logger.Error("Failed to encrypt legacy token", "userID", docRef.ID, "error", err)
+		return
+	}
+	if _, err := docRef.Set(ctx, sealed); err != nil {
+		logger.Error("Failed to encrypt legacy token", "userID", docRef.ID, "error", err)
+		return
+	}
+	logger.Info("Encrypted legacy token", "userID", docRef.ID)
+}
+
+// migrateToken backfills fields missing from documents written by older
+// versions of the bot. It updates token in place and returns the fields to
+// write back, or nil when the document is already current.
+func migrateToken(token *storedToken, createTime, updateTime time.Time) map[string]interface{} {
+	if token.SchemaVersion >= tokenSchemaVersion {
+		return nil
+	}
+
+	updates := map[string]interface{}{}
+	// Version 0 -> 1: issued_at, last_used_at, account_label and encrypted
+	// were introduced. Use the document timestamps as the best estimate.
+	if token.IssuedAt.IsZero() {
+		token.IssuedAt = createTime
+		updates["issued_at"] = createTime
+	}
+	if token.LastUsedAt.IsZero() {
+		token.LastUsedAt = updateTime
+		updates["last_used_at"] = updateTime
+	}
+	updates["account_label"] = token.AccountLabel
+	updates["encrypted"] = token.Encrypted
+
+	token.SchemaVersion = tokenSchemaVersion
+	updates["schema_version"] = tokenSchemaVersion
+	return updates
+}
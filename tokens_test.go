@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"golang.org/x/oauth2"
+)
+
+// TestMigrateToken tests that legacy token documents are backfilled once.
+func TestMigrateToken(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	token := &storedToken{Token: oauth2.Token{AccessToken: "access"}}
+	updates := migrateToken(token, created, updated)
+	if updates["schema_version"] != tokenSchemaVersion {
+		t.Errorf("Expected schema_version %d, but got: %v", tokenSchemaVersion, updates["schema_version"])
+	}
+	if !token.IssuedAt.Equal(created) {
+		t.Errorf("Expected issued_at %v, but got: %v", created, token.IssuedAt)
+	}
+	if !token.LastUsedAt.Equal(updated) {
+		t.Errorf("Expected last_used_at %v, but got: %v", updated, token.LastUsedAt)
+	}
+
+	if updates := migrateToken(token, created, updated); updates != nil {
+		t.Errorf("Expected no updates for a current token, but got: %v", updates)
+	}
+}
+
+// TestNewStoredToken tests that new tokens are written with the current
+// schema version.
+func TestNewStoredToken(t *testing.T) {
+	now := time.Now()
+	token := newStoredToken(&oauth2.Token{AccessToken: "access"}, now)
+	if token.SchemaVersion != tokenSchemaVersion {
+		t.Errorf("Expected schema version %d, but got: %d", tokenSchemaVersion, token.SchemaVersion)
+	}
+	if updates := migrateToken(token, now, now); updates != nil {
+		t.Errorf("Expected no migration for a new token, but got: %v", updates)
+	}
+}
+
+// TestTouchTokenLastUsed tests that last_used_at is written when it is
+// stale and left alone within tokenLastUsedInterval.
+func TestTouchTokenLastUsed(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST not set")
+	}
+
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("Failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+	original := firestoreClient
+	firestoreClient = client
+	defer func() { firestoreClient = original }()
+
+	userID := "test_touch_last_used"
+	docRef := client.Collection(tokenCollection).Doc(userID)
+	defer docRef.Delete(ctx)
+	lastUsed := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := docRef.Set(ctx, map[string]interface{}{"last_used_at": lastUsed}); err != nil {
+		t.Fatalf("Failed to write token: %v", err)
+	}
+	lastUsedAt := func() time.Time {
+		doc, err := docRef.Get(ctx)
+		if err != nil {
+			t.Fatalf("Failed to read token: %v", err)
+		}
+		v, _ := doc.DataAt("last_used_at")
+		ts, _ := v.(time.Time)
+		return ts
+	}
+
+	token := &storedToken{LastUsedAt: lastUsed}
+	now := lastUsed.Add(2 * tokenLastUsedInterval)
+	touchTokenLastUsed(ctx, userID, token, now)
+	if got := lastUsedAt(); !got.Equal(now) || !token.LastUsedAt.Equal(now) {
+		t.Errorf("Expected last_used_at %v, but got: %v", now, got)
+	}
+
+	touchTokenLastUsed(ctx, userID, token, now.Add(time.Minute))
+	if got := lastUsedAt(); !got.Equal(now) {
+		t.Errorf("Expected last_used_at to stay %v, but got: %v", now, got)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"google.golang.org/api/drive/v3"
+)
+
+// trashPageSize is how many trashed files /trash shows at a time.
+const trashPageSize = 5
+
+// trashedUploadsQuery matches the bot's uploads that are in the trash.
+var trashedUploadsQuery = fmt.Sprintf("trashed=true and appProperties has { key='%s' and value='true' }", uploadMarkerKey)
+
+// handleTrashCommand lists one page of the user's trashed uploads with
+// buttons to restore or permanently delete each one.
+func handleTrashCommand(ctx context.Context, bot botClient, replyToken, userID string, page int) {
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	files, more, err := listTrashedUploads(ctx, srv, page)
+	if err != nil {
+		log.Printf("Failed to list trash for user %s: %v", userID, err)
+		if !replyForError(ctx, bot, replyToken, userID, err) {
+			replyText(bot, replyToken, tr(ctx, userID, "trash.failed"))
+		}
+		return
+	}
+	if len(files) == 0 {
+		replyText(bot, replyToken, tr(ctx, userID, "trash.empty"))
+		return
+	}
+
+	msg := &messaging_api.FlexMessage{
+		AltText:  tr(ctx, userID, "trash.alt"),
+		Contents: buildTrashCarousel(userLanguage(ctx, userID), files),
+	}
+	if more {
+		msg.QuickReply = &messaging_api.QuickReply{
+			Items: []messaging_api.QuickReplyItem{
+				{
+					Action: &messaging_api.PostbackAction{
+						Label:       tr(ctx, userID, "trash.next_page"),
+						Data:        "action=trash&page=" + strconv.Itoa(page+1),
+						DisplayText: tr(ctx, userID, "trash.next_page"),
+					},
+				},
+			},
+		}
+	}
+	if err := sendReply(bot, replyToken, []messaging_api.MessageInterface{msg}); err != nil {
+		log.Print(err)
+	}
+}
+
+// listTrashedUploads returns the given page of trashed uploads, most
+// recently modified first, and whether there are more.
+func listTrashedUploads(ctx context.Context, srv *drive.Service, page int) ([]*drive.File, bool, error) {
+	start := page * trashPageSize
+	r, err := scopeFileList(srv.Files.List()).
+		Q(trashedUploadsQuery).
+		OrderBy("modifiedTime desc").
+		PageSize(int64(start + trashPageSize + 1)).
+		Fields("files(id, name, trashedTime)").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list trashed files: %w", err)
+	}
+	files := r.Files
+	if start >= len(files) {
+		return nil, false, nil
+	}
+	files = files[start:]
+	if len(files) > trashPageSize {
+		return files[:trashPageSize], true, nil
+	}
+	return files, false, nil
+}
+
+// buildTrashCarousel renders trashed files with restore and delete
+// buttons.
+func buildTrashCarousel(lang string, files []*drive.File) *messaging_api.FlexCarousel {
+	var bubbles []messaging_api.FlexBubble
+	for _, file := range files {
+		contents := []messaging_api.FlexComponentInterface{
+			&messaging_api.FlexText{
+				Text:   file.Name,
+				Weight: "bold",
+				Size:   "md",
+				Wrap:   true,
+			},
+		}
+		if file.TrashedTime != "" {
+			contents = append(contents, &messaging_api.FlexText{
+				Text:  fmt.Sprintf(translate(lang, "trash.trashed"), formatDriveTime(file.TrashedTime)),
+				Size:  "xs",
+				Color: "#aaaaaa",
+			})
+		}
+		bubbles = append(bubbles, messaging_api.FlexBubble{
+			Body: &messaging_api.FlexBox{
+				Layout:   "vertical",
+				Contents: contents,
+			},
+			Footer: &messaging_api.FlexBox{
+				Layout:  "vertical",
+				Spacing: "sm",
+				Contents: []messaging_api.FlexComponentInterface{
+					&messaging_api.FlexButton{
+						Style:  "link",
+						Height: "sm",
+						Action: &messaging_api.PostbackAction{
+							Label:       translate(lang, "trash.restore"),
+							Data:        "action=untrash&fileId=" + url.QueryEscape(file.Id),
+							DisplayText: fmt.Sprintf(translate(lang, "trash.restore_display"), file.Name),
+						},
+					},
+					&messaging_api.FlexButton{
+						Style:  "link",
+						Height: "sm",
+						Color:  "#FF3B30",
+						Action: &messaging_api.PostbackAction{
+							Label:       translate(lang, "trash.purge"),
+							Data:        "action=purge&fileId=" + url.QueryEscape(file.Id),
+							DisplayText: fmt.Sprintf(translate(lang, "trash.purge_display"), file.Name),
+						},
+					},
+				},
+			},
+		})
+	}
+	return &messaging_api.FlexCarousel{Contents: bubbles}
+}
+
+// handleUntrashPostback restores a trashed upload.
+func handleUntrashPostback(ctx context.Context, bot botClient, replyToken, userID, fileID string) {
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+	file, err := getTrashedUpload(ctx, srv, fileID)
+	if err == nil {
+		// false is the zero value, so it has to be sent explicitly.
+		_, err = srv.Files.Update(fileID, &drive.File{Trashed: false, ForceSendFields: []string{"Trashed"}}).SupportsAllDrives(supportsAllDrives()).Fields("id").Context(ctx).Do()
+	}
+	if replyTrashError(ctx, bot, replyToken, userID, fileID, err) {
+		return
+	}
+	replyText(bot, replyToken, trf(ctx, userID, "trash.restored", file.Name))
+}
+
+// handlePurgePostback asks for confirmation, then permanently deletes a
+// trashed upload.
+func handlePurgePostback(ctx context.Context, bot botClient, replyToken, userID, fileID string, confirmed bool) {
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+	file, err := getTrashedUpload(ctx, srv, fileID)
+	if replyTrashError(ctx, bot, replyToken, userID, fileID, err) {
+		return
+	}
+
+	if !confirmed {
+		if err := sendReply(bot, replyToken, []messaging_api.MessageInterface{
+			&messaging_api.TextMessage{
+				Text: trf(ctx, userID, "trash.confirm_purge", file.Name),
+				QuickReply: &messaging_api.QuickReply{
+					Items: []messaging_api.QuickReplyItem{
+						{Action: &messaging_api.PostbackAction{
+							Label:       tr(ctx, userID, "trash.confirm"),
+							Data:        "action=purge&confirm=1&fileId=" + url.QueryEscape(fileID),
+							DisplayText: tr(ctx, userID, "trash.confirm"),
+						}},
+						{Action: &messaging_api.PostbackAction{
+							Label:       tr(ctx, userID, "common.cancel"),
+							Data:        "action=purge_cancel",
+							DisplayText: tr(ctx, userID, "common.cancel"),
+						}},
+					},
+				},
+			},
+		}); err != nil {
+			log.Print(err)
+		}
+		return
+	}
+
+	err = srv.Files.Delete(fileID).SupportsAllDrives(supportsAllDrives()).Context(ctx).Do()
+	if replyTrashError(ctx, bot, replyToken, userID, fileID, err) {
+		return
+	}
+	replyText(bot, replyToken, trf(ctx, userID, "trash.purged", file.Name))
+}
+
+// getTrashedUpload returns the file if it is one of the bot's uploads and
+// still in the trash.
+func getTrashedUpload(ctx context.Context, srv *drive.Service, fileID string) (*drive.File, error) {
+	file, err := srv.Files.Get(fileID).SupportsAllDrives(supportsAllDrives()).Fields("id, name, trashed, appProperties").Context(ctx).Do()
+	if isNotFound(err) || (err == nil && !file.Trashed) {
+		return nil, errFileGone
+	}
+	if err != nil {
+		return nil, err
+	}
+	if file.AppProperties[uploadMarkerKey] != "true" {
+		return nil, errOutsideUploads
+	}
+	return file, nil
+}
+
+// replyTrashError replies to a failed trash action and reports whether
+// there was an error.
+func replyTrashError(ctx context.Context, bot botClient, replyToken, userID, fileID string, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, errFileGone) || isNotFound(err):
+		replyText(bot, replyToken, tr(ctx, userID, "trash.gone"))
+	case errors.Is(err, errOutsideUploads):
+		log.Printf("User %s tried to change trashed file %s outside the uploads", userID, fileID)
+		replyText(bot, replyToken, tr(ctx, userID, "trash.not_upload"))
+	default:
+		log.Printf("Failed to update trashed file %s for user %s: %v", fileID, userID, err)
+		if !replyForError(ctx, bot, replyToken, userID, err) {
+			replyText(bot, replyToken, tr(ctx, userID, "error.generic"))
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestListTrashedUploads tests paging through the trashed uploads.
+func TestListTrashedUploads(t *testing.T) {
+	var all []*drive.File
+	for i := 0; i < 7; i++ {
+		all = append(all, &drive.File{Id: fmt.Sprintf("f%d", i)})
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query().Get("q"); !strings.Contains(q, "trashed=true") || !strings.Contains(q, uploadMarkerKey) {
+			t.Errorf("Unexpected query: %s", q)
+		}
+		var n int
+		fmt.Sscan(r.URL.Query().Get("pageSize"), &n)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&drive.FileList{Files: all[:min(n, len(all))]})
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	files, more, err := listTrashedUploads(context.Background(), srv, 0)
+	if err != nil || len(files) != trashPageSize || !more {
+		t.Errorf("Expected a full first page with more, but got %d files, more=%v, err=%v", len(files), more, err)
+	}
+	files, more, _ = listTrashedUploads(context.Background(), srv, 1)
+	if len(files) != 2 || files[0].Id != "f5" || more {
+		t.Errorf("Expected the last 2 files without more, but got %v, more=%v", files, more)
+	}
+	files, _, _ = listTrashedUploads(context.Background(), srv, 5)
+	if len(files) != 0 {
+		t.Errorf("Expected no files past the end, but got %d", len(files))
+	}
+}
+
+// TestGetTrashedUpload tests that only trashed bot uploads are accepted.
+func TestGetTrashedUpload(t *testing.T) {
+	files := map[string]*drive.File{
+		"ok":       {Id: "ok", Trashed: true, AppProperties: map[string]string{uploadMarkerKey: "true"}},
+		"restored": {Id: "restored", AppProperties: map[string]string{uploadMarkerKey: "true"}},
+		"foreign":  {Id: "foreign", Trashed: true},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := files[strings.TrimPrefix(r.URL.Path, "/files/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"code": 404, "message": "File not found"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(f)
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	if _, err := getTrashedUpload(context.Background(), srv, "ok"); err != nil {
+		t.Errorf("Expected no error, but got: %v", err)
+	}
+	for id, want := range map[string]error{"restored": errFileGone, "missing": errFileGone, "foreign": errOutsideUploads} {
+		if _, err := getTrashedUpload(context.Background(), srv, id); !errors.Is(err, want) {
+			t.Errorf("%s: expected %v, but got: %v", id, want, err)
+		}
+	}
+}
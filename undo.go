@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rememberLastUpload stores the file /undo would trash.
+func rememberLastUpload(ctx context.Context, client *firestore.Client, userID, fileID string) error {
+	_, err := client.Collection(prefsCollection).Doc(userID).Set(ctx, map[string]interface{}{
+		"last_upload_id": fileID,
+	}, firestore.MergeAll)
+	if err != nil {
+		return fmt.Errorf("failed to remember last upload: %w", err)
+	}
+	return nil
+}
+
+// takeLastUpload returns the file /undo should trash and clears it in the
+// same transaction, so two /undo in a row can't both act on it. It
+// returns "" when there is nothing to undo.
+func takeLastUpload(ctx context.Context, client *firestore.Client, userID string) (string, error) {
+	ref := client.Collection(prefsCollection).Doc(userID)
+	fileID := ""
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		fileID = ""
+		var prefs userPrefs
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := doc.DataTo(&prefs); err != nil {
+			return err
+		}
+		if prefs.LastUploadID == "" {
+			return nil
+		}
+		fileID = prefs.LastUploadID
+		return tx.Set(ref, map[string]interface{}{"last_upload_id": ""}, firestore.MergeAll)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to take last upload: %w", err)
+	}
+	return fileID, nil
+}
+
+// handleUndoCommand moves the user's last upload to the trash.
+func handleUndoCommand(ctx context.Context, bot botClient, replyToken, userID string) {
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	fileID, err := takeLastUpload(ctx, firestoreClient, userID)
+	if err != nil {
+		log.Printf("Failed to load last upload for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "undo.failed"))
+		return
+	}
+	if fileID == "" {
+		replyText(bot, replyToken, tr(ctx, userID, "undo.nothing"))
+		return
+	}
+
+	name, err := trashFile(ctx, srv, fileID)
+	if isNotFound(err) {
+		replyText(bot, replyToken, tr(ctx, userID, "undo.nothing"))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to trash file %s for user %s: %v", fileID, userID, err)
+		// Keep the file so the user can try again.
+		if err := rememberLastUpload(ctx, firestoreClient, userID, fileID); err != nil {
+			log.Printf("Failed to restore last upload for user %s: %v", userID, err)
+		}
+		driveServices.invalidateOnAuthError(userID, err)
+		if !replyForError(ctx, bot, replyToken, userID, err) {
+			replyText(bot, replyToken, tr(ctx, userID, "undo.failed"))
+		}
+		return
+	}
+	replyText(bot, replyToken, trf(ctx, userID, "undo.done", name))
+}
+
+// trashFile moves a file to the trash and returns its name.
+func trashFile(ctx context.Context, srv *drive.Service, fileID string) (string, error) {
+	file, err := srv.Files.Update(fileID, &drive.File{Trashed: true}).SupportsAllDrives(supportsAllDrives()).Fields("id, name").Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	return file.Name, nil
+}
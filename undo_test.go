@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestTrashFile tests that the file is moved to the trash, not deleted.
+func TestTrashFile(t *testing.T) {
+	var trashed bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Path != "/files/file_1" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var body drive.File
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+		trashed = body.Trashed
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&drive.File{Id: "file_1", Name: "photo.jpg"})
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	name, err := trashFile(context.Background(), srv, "file_1")
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if !trashed {
+		t.Error("Expected the update to set trashed")
+	}
+	if name != "photo.jpg" {
+		t.Errorf("Expected name 'photo.jpg', but got: '%s'", name)
+	}
+}
+
+// TestTakeLastUpload tests the stored file ID's lifecycle: nothing to undo
+// before an upload, the latest upload wins, and it can only be taken once.
+func TestTakeLastUpload(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST not set")
+	}
+
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("Failed to create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	userID := "test_user_undo"
+	defer client.Collection(prefsCollection).Doc(userID).Delete(ctx)
+
+	if id, err := takeLastUpload(ctx, client, userID); err != nil || id != "" {
+		t.Fatalf("Expected nothing to undo, but got %q, %v", id, err)
+	}
+
+	for _, fileID := range []string{"file_1", "file_2"} {
+		if err := rememberLastUpload(ctx, client, userID, fileID); err != nil {
+			t.Fatalf("rememberLastUpload failed: %v", err)
+		}
+	}
+	id, err := takeLastUpload(ctx, client, userID)
+	if err != nil || id != "file_2" {
+		t.Fatalf("Expected 'file_2', but got %q, %v", id, err)
+	}
+	if id, err := takeLastUpload(ctx, client, userID); err != nil || id != "" {
+		t.Errorf("Expected the ID to be cleared after undo, but got %q, %v", id, err)
+	}
+}
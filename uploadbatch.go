@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+	"google.golang.org/api/drive/v3"
+)
+
+// batchReplyPrefix marks a reply token that belongs to a batch of media
+// messages sent together, e.g. an album. Replies sent with it are
+// collected and answered with one summary once the whole batch is done.
+const batchReplyPrefix = "batch:"
+
+// maxReplyMessages is how many messages one LINE reply may carry.
+const maxReplyMessages = 5
+
+// maxCarouselBubbles is how many bubbles one Flex carousel may carry.
+const maxCarouselBubbles = 12
+
+// uploadBatch collects the results of one user's media messages from a
+// single webhook request.
+type uploadBatch struct {
+	replyToken string
+	userID     string
+	direct     bool
+
+	mu    sync.Mutex
+	items []batchItem
+}
+
+// batchItem is the outcome of one media message in a batch. An item with
+// neither a file nor a status never replied, which keeps uploads that fail
+// silently on their own silent in the summary too. A pending item
+// acknowledged a slow upload whose result comes after the summary.
+type batchItem struct {
+	name     string
+	file     *drive.File
+	status   string
+	tip      string
+	pending  bool
+	messages []messaging_api.MessageInterface
+}
+
+var (
+	uploadBatchesMu sync.Mutex
+	uploadBatches   = map[string]*uploadBatch{}
+	uploadBatchSeq  int
+)
+
+// batchMediaEvents gives the media messages of every user who sent more
+// than one in this request a batch reply token. It returns the events,
+// with those tokens swapped in, and the IDs of the batches to flush once
+// the events were handled.
+func batchMediaEvents(ctx context.Context, events []webhook.EventInterface) ([]webhook.EventInterface, []string) {
+	counts := map[string]int{}
+	for _, event := range events {
+		if key, ok := mediaBatchKey(event); ok {
+			counts[key]++
+		}
+	}
+
+	uploadBatchesMu.Lock()
+	defer uploadBatchesMu.Unlock()
+
+	out := make([]webhook.EventInterface, len(events))
+	batchIDs := map[string]string{}
+	var ids []string
+	for i, event := range events {
+		out[i] = event
+		key, ok := mediaBatchKey(event)
+		if !ok || counts[key] < 2 {
+			continue
+		}
+		e := event.(webhook.MessageEvent)
+		userID, _ := extractUserID(e.Source)
+		id, ok := batchIDs[key]
+		if !ok {
+			uploadBatchSeq++
+			id = strconv.Itoa(uploadBatchSeq)
+			batchIDs[key] = id
+			ids = append(ids, id)
+			uploadBatches[id] = &uploadBatch{replyToken: e.ReplyToken, userID: userID, direct: isDirectChat(e.Source)}
+		}
+		b := uploadBatches[id]
+		b.items = append(b.items, batchItem{name: batchItemName(ctx, userID, e.Message, len(b.items)+1)})
+		e.ReplyToken = batchReplyPrefix + id + "|" + strconv.Itoa(len(b.items)-1) + "|" + e.ReplyToken
+		out[i] = e
+	}
+	return out, ids
+}
+
+// mediaBatchKey identifies the sender and chat of a media message. Events
+// without a known sender are never batched.
+func mediaBatchKey(event webhook.EventInterface) (string, bool) {
+	e, ok := event.(webhook.MessageEvent)
+	if !ok {
+		return "", false
+	}
+	switch e.Message.(type) {
+	case webhook.ImageMessageContent, webhook.VideoMessageContent, webhook.AudioMessageContent, webhook.FileMessageContent:
+	default:
+		return "", false
+	}
+	userID, ok := extractUserID(e.Source)
+	if !ok {
+		return "", false
+	}
+	switch s := e.Source.(type) {
+	case webhook.GroupSource:
+		return s.GroupId + "/" + userID, true
+	case webhook.RoomSource:
+		return s.RoomId + "/" + userID, true
+	}
+	return userID, true
+}
+
+// batchItemName is how the summary refers to a file whose upload failed:
+// its own name for files, otherwise its position in the batch.
+func batchItemName(ctx context.Context, userID string, m webhook.MessageContentInterface, n int) string {
+	if f, ok := m.(webhook.FileMessageContent); ok && f.FileName != "" {
+		return f.FileName
+	}
+	return trf(ctx, userID, "batch.item", n)
+}
+
+// lookupBatchItem resolves a batch reply token. ok is false for other
+// tokens; when the batch was already flushed, b is nil and original is
+// the event's own reply token to fall back to.
+func lookupBatchItem(replyToken string) (b *uploadBatch, index int, original string, ok bool) {
+	rest, ok := strings.CutPrefix(replyToken, batchReplyPrefix)
+	if !ok {
+		return nil, 0, "", false
+	}
+	parts := strings.SplitN(rest, "|", 3)
+	if len(parts) != 3 {
+		return nil, 0, "", false
+	}
+	index, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, 0, "", false
+	}
+	uploadBatchesMu.Lock()
+	b = uploadBatches[parts[0]]
+	uploadBatchesMu.Unlock()
+	if b != nil && index >= len(b.items) {
+		b = nil
+	}
+	return b, index, parts[2], true
+}
+
+// collectBatchReply records messages sent with a batch reply token. It
+// returns the token to send them with instead when the batch is already
+// flushed, and "" when they were collected.
+func collectBatchReply(replyToken string, messages []messaging_api.MessageInterface) string {
+	b, index, original, ok := lookupBatchItem(replyToken)
+	if !ok {
+		return replyToken
+	}
+	if b == nil {
+		return original
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	item := &b.items[index]
+	for _, m := range messages {
+		if t, ok := m.(*messaging_api.TextMessage); ok {
+			item.status = strings.TrimSpace(item.status + "\n" + t.Text)
+			continue
+		}
+		item.messages = append(item.messages, m)
+	}
+	return ""
+}
+
+// markBatchItemPending marks the item of a batch reply token as still
+// uploading instead of collecting an acknowledgement as its result. It
+// returns the token for the result: the same item, falling back to a push
+// to userID once the batch is flushed. ok is false for other tokens and
+// for batches already flushed.
+func markBatchItemPending(replyToken, userID string) (token string, ok bool) {
+	b, index, original, ok := lookupBatchItem(replyToken)
+	if !ok || b == nil {
+		return "", false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.items[index].pending = true
+	return strings.TrimSuffix(replyToken, original) + deferredReplyToken(userID), true
+}
+
+// recordBatchUpload records a successful upload made with a batch reply
+// token, reporting false for any other token.
+func recordBatchUpload(replyToken string, file *drive.File, tip string) bool {
+	b, index, _, ok := lookupBatchItem(replyToken)
+	if !ok || b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.items[index].file = file
+	b.items[index].tip = tip
+	return true
+}
+
+// flushUploadBatch answers a batch with one summary. Replies that arrive
+// afterwards, e.g. from uploads finished in the background, use their
+// event's own reply token.
+func flushUploadBatch(ctx context.Context, bot botClient, id string) {
+	uploadBatchesMu.Lock()
+	b := uploadBatches[id]
+	delete(uploadBatches, id)
+	uploadBatchesMu.Unlock()
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	messages := b.summary(ctx)
+	b.mu.Unlock()
+	if len(messages) == 0 {
+		return
+	}
+	err := sendReply(bot, b.replyToken, messages)
+	if err != nil && b.direct {
+		// Uploading the whole batch may outlast the reply token.
+		log.Printf("Failed to reply to upload batch for user %s, pushing instead: %v", b.userID, err)
+		err = sendReply(bot, deferredReplyToken(b.userID), messages)
+	}
+	if err != nil {
+		log.Printf("Failed to send upload batch summary for user %s: %v", b.userID, err)
+	}
+}
+
+// summary builds the reply for a batch: a text listing how many files were
+// uploaded and which were not, a carousel of the uploaded files, then any
+// other messages the uploads sent. Items are kept in the order they were
+// sent.
+func (b *uploadBatch) summary(ctx context.Context) []messaging_api.MessageInterface {
+	var uploaded []*drive.File
+	var problems, tips []string
+	var extra []messaging_api.MessageInterface
+	pending := 0
+	seenTips := map[string]bool{}
+	for _, item := range b.items {
+		if item.file != nil {
+			uploaded = append(uploaded, item.file)
+			if item.tip != "" && !seenTips[item.tip] {
+				seenTips[item.tip] = true
+				tips = append(tips, item.tip)
+			}
+		} else if item.status != "" {
+			problems = append(problems, "• "+item.name+"："+item.status)
+		} else if item.pending {
+			pending++
+		}
+		extra = append(extra, item.messages...)
+	}
+	if len(uploaded) == 0 && len(problems) == 0 && pending == 0 && len(extra) == 0 {
+		return nil
+	}
+
+	var lines []string
+	if len(uploaded) > 0 {
+		lines = append(lines, "✅ "+trf(ctx, b.userID, "batch.uploaded", len(uploaded)))
+	}
+	if len(problems) > 0 {
+		lines = append(lines, "⚠️ "+trf(ctx, b.userID, "batch.failed", len(problems)))
+		lines = append(lines, problems...)
+	}
+	if pending > 0 {
+		lines = append(lines, "⏳ "+trf(ctx, b.userID, "batch.pending", pending))
+	}
+
+	var messages []messaging_api.MessageInterface
+	if len(lines) > 0 {
+		messages = append(messages, &messaging_api.TextMessage{Text: strings.Join(lines, "\n")})
+	}
+	for start := 0; start < len(uploaded); start += maxCarouselBubbles {
+		end := min(start+maxCarouselBubbles, len(uploaded))
+		messages = append(messages, &messaging_api.FlexMessage{
+			AltText:  trf(ctx, b.userID, "batch.alt", len(uploaded)),
+			Contents: buildFilesCarousel(userLanguage(ctx, b.userID), uploaded[start:end]),
+		})
+	}
+	messages = append(messages, extra...)
+	for _, tip := range tips {
+		messages = append(messages, &messaging_api.TextMessage{Text: tip})
+	}
+	if len(messages) > maxReplyMessages {
+		log.Printf("Upload batch summary for user %s has %d messages, dropping %d", b.userID, len(messages), len(messages)-maxReplyMessages)
+		messages = messages[:maxReplyMessages]
+	}
+	return messages
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"github.com/line/line-bot-sdk-go/v8/linebot/webhook"
+	"google.golang.org/api/drive/v3"
+)
+
+// TestBatchMediaEvents tests that only users who sent several media
+// messages get batch reply tokens.
+func TestBatchMediaEvents(t *testing.T) {
+	u1 := webhook.UserSource{UserId: "U1"}
+	events := []webhook.EventInterface{
+		webhook.MessageEvent{ReplyToken: "r1", Source: u1, Message: webhook.ImageMessageContent{Id: "m1"}},
+		webhook.MessageEvent{ReplyToken: "r2", Source: u1, Message: webhook.TextMessageContent{Text: "hi"}},
+		webhook.MessageEvent{ReplyToken: "r3", Source: webhook.UserSource{UserId: "U2"}, Message: webhook.ImageMessageContent{Id: "m3"}},
+		webhook.MessageEvent{ReplyToken: "r4", Source: u1, Message: webhook.FileMessageContent{Id: "m4", FileName: "a.pdf"}},
+	}
+
+	out, ids := batchMediaEvents(context.Background(), events)
+	defer func() {
+		for _, id := range ids {
+			flushUploadBatch(context.Background(), nil, id)
+		}
+	}()
+	if len(ids) != 1 {
+		t.Fatalf("Expected one batch, but got: %v", ids)
+	}
+	for i, want := range []bool{true, false, false, true} {
+		token := out[i].(webhook.MessageEvent).ReplyToken
+		if got := strings.HasPrefix(token, batchReplyPrefix); got != want {
+			t.Errorf("Event %d: expected batched=%v, but got token %q", i, want, token)
+		}
+	}
+
+	b, index, original, ok := lookupBatchItem(out[3].(webhook.MessageEvent).ReplyToken)
+	if !ok || b == nil || index != 1 || original != "r4" {
+		t.Fatalf("Expected item 1 with original token r4, but got b=%v index=%d original=%q", b, index, original)
+	}
+	if b.replyToken != "r1" || b.items[0].name != "第 1 個檔案" || b.items[1].name != "a.pdf" {
+		t.Errorf("Unexpected batch: %+v", b)
+	}
+}
+
+// TestFlushUploadBatch tests that a batch with a success and a failure is
+// answered with a single reply, and that late replies use the event's own
+// token.
+func TestFlushUploadBatch(t *testing.T) {
+	type reply struct {
+		ReplyToken string `json:"replyToken"`
+		Messages   []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			Contents struct {
+				Contents []json.RawMessage `json:"contents"`
+			} `json:"contents"`
+		} `json:"messages"`
+	}
+	var replies []reply
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/bot/message/reply" {
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+		var req reply
+		json.NewDecoder(r.Body).Decode(&req)
+		replies = append(replies, req)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	bot, err := messaging_api.NewMessagingApiAPI("token", messaging_api.WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create mock bot: %v", err)
+	}
+
+	src := webhook.UserSource{UserId: "U1"}
+	out, ids := batchMediaEvents(context.Background(), []webhook.EventInterface{
+		webhook.MessageEvent{ReplyToken: "r1", Source: src, Message: webhook.ImageMessageContent{Id: "m1"}},
+		webhook.MessageEvent{ReplyToken: "r2", Source: src, Message: webhook.ImageMessageContent{Id: "m2"}},
+	})
+	first := out[0].(webhook.MessageEvent).ReplyToken
+	second := out[1].(webhook.MessageEvent).ReplyToken
+
+	file := &drive.File{Id: "f1", Name: "line-bot-upload-m1.jpg", WebViewLink: "https://drive.google.com/file/d/f1/view"}
+	if !recordBatchUpload(first, file, "") {
+		t.Fatal("Expected the upload to be recorded")
+	}
+	replyText(bot, second, "檔案是空的，請重新傳送")
+	if len(replies) != 0 {
+		t.Fatalf("Expected replies to be held until the batch is flushed, but got %d", len(replies))
+	}
+
+	flushUploadBatch(context.Background(), bot, ids[0])
+	if len(replies) != 1 {
+		t.Fatalf("Expected one reply, but got %d", len(replies))
+	}
+	if replies[0].ReplyToken != "r1" || len(replies[0].Messages) != 2 {
+		t.Fatalf("Expected a summary and a carousel replying to r1, but got: %+v", replies[0])
+	}
+	summary := replies[0].Messages[0].Text
+	if !strings.Contains(summary, "已上傳 1 個檔案") || !strings.Contains(summary, "第 2 個檔案：檔案是空的") {
+		t.Errorf("Expected the summary to list the success and the failure, but got: %q", summary)
+	}
+	if carousel := replies[0].Messages[1]; carousel.Type != "flex" || len(carousel.Contents.Contents) != 1 {
+		t.Errorf("Expected a carousel with one bubble, but got: %+v", carousel)
+	}
+
+	replyText(bot, second, "late")
+	if len(replies) != 2 || replies[1].ReplyToken != "r2" {
+		t.Errorf("Expected a late reply to use the event's token r2, but got: %+v", replies)
+	}
+}
+
+// TestAcknowledgeUploadInBatch tests that a slow upload's acknowledgement
+// is not listed as a failure and its late result is pushed.
+func TestAcknowledgeUploadInBatch(t *testing.T) {
+	bot := newFakeBot()
+	src := webhook.UserSource{UserId: "U1"}
+	out, ids := batchMediaEvents(context.Background(), []webhook.EventInterface{
+		webhook.MessageEvent{ReplyToken: "r1", Source: src, Message: webhook.ImageMessageContent{Id: "m1"}},
+		webhook.MessageEvent{ReplyToken: "r2", Source: src, Message: webhook.ImageMessageContent{Id: "m2"}},
+	})
+	first := out[0].(webhook.MessageEvent).ReplyToken
+	second := out[1].(webhook.MessageEvent).ReplyToken
+
+	recordBatchUpload(first, &drive.File{Id: "f1", Name: "a.jpg"}, "")
+	token := acknowledgeUpload(bot, second, "U1", "上傳中，完成後會通知您…")
+	if len(bot.replies) != 0 {
+		t.Fatalf("Expected the acknowledgement to be held, but got: %+v", bot.replies)
+	}
+
+	flushUploadBatch(context.Background(), bot, ids[0])
+	if len(bot.replies) != 1 {
+		t.Fatalf("Expected one summary reply, but got: %+v", bot.replies)
+	}
+	summary := bot.replies[0].texts[0]
+	if strings.Contains(summary, "未完成") || !strings.Contains(summary, fmt.Sprintf(translate(langZh, "batch.pending"), 1)) {
+		t.Errorf("Expected the item to be pending rather than failed, but got: %q", summary)
+	}
+
+	replyText(bot, token, "done")
+	if len(bot.pushes) != 1 || bot.pushes[0].to != "U1" {
+		t.Errorf("Expected the late result to be pushed to U1, but got: %+v", bot.pushes)
+	}
+}
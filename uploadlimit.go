@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var errUploadTooLarge = errors.New("upload exceeds MAX_UPLOAD_BYTES")
+
+// checkUploadSize rejects content whose declared length is over
+// maxUploadBytes before any of it is downloaded. An unknown length (-1)
+// passes; limitUploadBody catches it while streaming.
+func checkUploadSize(contentLength int64) error {
+	if maxUploadBytes > 0 && contentLength > maxUploadBytes {
+		return errUploadTooLarge
+	}
+	return nil
+}
+
+// replyUploadTooLarge tells the user the file was over maxUploadBytes.
+func replyUploadTooLarge(ctx context.Context, bot botClient, replyToken, userID string) {
+	replyText(bot, replyToken, trf(ctx, userID, "upload.too_large", formatBytes(maxUploadBytes)))
+}
+
+// uploadLimitReader fails reads once more than limit bytes went through
+// it, for content that didn't declare its length or declared it wrongly.
+type uploadLimitReader struct {
+	r     io.Reader
+	limit int64
+	n     int64
+
+	// exceeded stays set after the error, since callers such as the Drive
+	// client may not pass errUploadTooLarge through.
+	exceeded bool
+}
+
+// limitUploadBody wraps r with maxUploadBytes. With no limit configured
+// it never fails.
+func limitUploadBody(r io.Reader) *uploadLimitReader {
+	return &uploadLimitReader{r: r, limit: maxUploadBytes}
+}
+
+func (l *uploadLimitReader) Read(p []byte) (int, error) {
+	if l.limit <= 0 {
+		return l.r.Read(p)
+	}
+	if l.exceeded {
+		return 0, errUploadTooLarge
+	}
+	// Read one byte past the limit so content of exactly limit bytes
+	// still reaches EOF cleanly.
+	if max := l.limit + 1 - l.n; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		l.exceeded = true
+		return n, errUploadTooLarge
+	}
+	return n, err
+}
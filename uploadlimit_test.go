@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestCheckUploadSize tests the rejection based on Content-Length.
+func TestCheckUploadSize(t *testing.T) {
+	original := maxUploadBytes
+	defer func() { maxUploadBytes = original }()
+	maxUploadBytes = 10
+
+	tests := []struct {
+		length  int64
+		wantErr bool
+	}{
+		{5, false},
+		{10, false},
+		{11, true},
+		{-1, false},
+	}
+	for _, tt := range tests {
+		if err := checkUploadSize(tt.length); (err != nil) != tt.wantErr {
+			t.Errorf("Length %d: expected error %v, but got: %v", tt.length, tt.wantErr, err)
+		}
+	}
+
+	maxUploadBytes = 0
+	if err := checkUploadSize(1 << 40); err != nil {
+		t.Errorf("Expected no limit when MAX_UPLOAD_BYTES is 0, but got: %v", err)
+	}
+}
+
+// TestLimitUploadBody tests the rejection based on the streamed byte
+// count, for content without a usable Content-Length.
+func TestLimitUploadBody(t *testing.T) {
+	original := maxUploadBytes
+	defer func() { maxUploadBytes = original }()
+	maxUploadBytes = 10
+
+	body := limitUploadBody(strings.NewReader("0123456789"))
+	got, err := io.ReadAll(body)
+	if err != nil || string(got) != "0123456789" {
+		t.Errorf("Expected content at the limit to pass, but got %q, %v", got, err)
+	}
+	if body.exceeded {
+		t.Error("Expected content at the limit not to be marked as exceeded")
+	}
+
+	body = limitUploadBody(strings.NewReader("0123456789A"))
+	_, err = io.ReadAll(body)
+	if !errors.Is(err, errUploadTooLarge) {
+		t.Errorf("Expected errUploadTooLarge, but got: %v", err)
+	}
+	if !body.exceeded {
+		t.Error("Expected content over the limit to be marked as exceeded")
+	}
+	if _, err := body.Read(make([]byte, 1)); !errors.Is(err, errUploadTooLarge) {
+		t.Errorf("Expected later reads to keep failing, but got: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// uploadRetries is how many times a transient upload failure is
+	// retried.
+	uploadRetries = 3
+
+	// uploadRetryBufferBytes is the largest upload kept in memory so it
+	// can be sent again. Larger uploads stream and are only tried once;
+	// the Drive client still retries their individual chunks, see
+	// uploadChunkSize.
+	uploadRetryBufferBytes = 20 << 20
+)
+
+// uploadRetryBase is the first backoff delay, doubled on every retry.
+var uploadRetryBase = time.Second
+
+// isRetryableUploadError reports whether Drive failed in a way that may
+// succeed on a later attempt. Auth errors are never retried so classify
+// still sees them.
+func isRetryableUploadError(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// bufferForRetry reads content into memory if it is at most limit bytes
+// so it can be re-sent. Otherwise it returns a reader over the whole
+// content and retryable=false.
+func bufferForRetry(content io.Reader, limit int64) (r io.Reader, retryable bool, err error) {
+	buf, err := io.ReadAll(io.LimitReader(content, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(buf)) > limit {
+		return io.MultiReader(bytes.NewReader(buf), content), false, nil
+	}
+	return bytes.NewReader(buf), true, nil
+}
+
+// createWithRetry creates the file, retrying transient failures with
+// exponential backoff and jitter. Content larger than uploadChunkSize is
+// sent with Drive's resumable protocol, so a failed chunk is re-sent on
+// its own instead of restarting the whole upload.
+func createWithRetry(ctx context.Context, srv *drive.Service, file *drive.File, content io.Reader) (*drive.File, error) {
+	body, retryable, err := bufferForRetry(content, uploadRetryBufferBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload content: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		created, err := srv.Files.Create(file).SupportsAllDrives(supportsAllDrives()).Media(body, googleapi.ChunkSize(uploadChunkSize)).Fields("id, name, webViewLink").Context(ctx).Do()
+		if err == nil || !retryable || attempt == uploadRetries || !isRetryableUploadError(err) {
+			return created, err
+		}
+
+		wait := uploadRetryBase<<attempt + rand.N(uploadRetryBase)
+		log.Printf("Upload of '%s' failed (attempt %d), retrying in %v: %v", file.Name, attempt+1, wait, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("upload retry canceled: %w", ctx.Err())
+		}
+		body.(*bytes.Reader).Seek(0, io.SeekStart)
+	}
+}
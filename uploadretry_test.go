@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// TestCreateWithRetry tests that an upload succeeds after two 503s and
+// that the full content is sent on the final attempt.
+func TestCreateWithRetry(t *testing.T) {
+	uploadRetryBase = time.Millisecond
+	defer func() { uploadRetryBase = time.Second }()
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := io.ReadAll(r.Body)
+		if attempts <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": {"code": 503, "message": "backend error"}}`))
+			return
+		}
+		if !strings.Contains(string(body), "hello") {
+			t.Errorf("Expected the content to be re-sent, but got: %s", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&drive.File{Id: "file_1"})
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	created, err := createWithRetry(context.Background(), srv, &drive.File{Name: "a.txt"}, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if created.Id != "file_1" || attempts != 3 {
+		t.Errorf("Expected file_1 after 3 attempts, but got %q after %d", created.Id, attempts)
+	}
+}
+
+// TestCreateWithRetryResumable tests that content larger than a chunk is
+// sent in chunks and that a chunk failing with 503 is re-sent on its own
+// instead of restarting the upload.
+func TestCreateWithRetryResumable(t *testing.T) {
+	original := uploadChunkSize
+	uploadChunkSize = googleapi.MinUploadChunkSize
+	defer func() { uploadChunkSize = original }()
+
+	content := bytes.Repeat([]byte("0123456789"), 60000)
+	var (
+		received  []byte
+		sessions  int
+		chunks    int
+		failed    bool
+		serverURL string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.URL.Path == "/upload/drive/v3/files":
+			if got := r.URL.Query().Get("uploadType"); got != "resumable" {
+				t.Errorf("Expected a resumable upload, but got uploadType=%q", got)
+			}
+			sessions++
+			w.Header().Set("Location", serverURL+"/session/1")
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/session/1":
+			chunks++
+			if chunks == 2 && !failed {
+				failed = true
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			var start, end, total int
+			if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil {
+				fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/*", &start, &end)
+			}
+			if start != len(received) {
+				t.Errorf("Expected the chunk to start at %d, but got Content-Range %q", len(received), r.Header.Get("Content-Range"))
+			}
+			received = append(received, body...)
+			if total == 0 || len(received) < total {
+				// The client asks for 200 with this header instead of a
+				// bare 308 "Resume Incomplete".
+				w.Header().Set("X-Http-Status-Code-Override", "308")
+				w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(received)-1))
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(&drive.File{Id: "file_1"})
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	serverURL = server.URL
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	created, err := createWithRetry(context.Background(), srv, &drive.File{Name: "video.mp4"}, bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if created.Id != "file_1" {
+		t.Errorf("Expected file_1, but got %q", created.Id)
+	}
+	if sessions != 1 {
+		t.Errorf("Expected one upload session, but got %d", sessions)
+	}
+	if !failed || chunks < 4 {
+		t.Errorf("Expected the failed chunk to be retried, but got %d chunk requests", chunks)
+	}
+	if !bytes.Equal(received, content) {
+		t.Errorf("Expected %d bytes to arrive intact, but got %d", len(content), len(received))
+	}
+}
+
+// TestCreateWithRetryAuthError tests that auth errors are not retried.
+func TestCreateWithRetryAuthError(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": {"code": 401, "message": "invalid credentials"}}`))
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	_, err = createWithRetry(context.Background(), srv, &drive.File{Name: "a.txt"}, strings.NewReader("hello"))
+	if classify(err) != ErrTokenInvalid {
+		t.Errorf("Expected ErrTokenInvalid, but got: %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt, but got %d", attempts)
+	}
+}
+
+// TestBufferForRetry tests the in-memory size threshold.
+func TestBufferForRetry(t *testing.T) {
+	r, retryable, err := bufferForRetry(strings.NewReader("hello"), 5)
+	if err != nil || !retryable {
+		t.Errorf("Expected small content to be retryable, but got %v %v", retryable, err)
+	}
+	if b, _ := io.ReadAll(r); string(b) != "hello" {
+		t.Errorf("Expected 'hello', but got: %s", b)
+	}
+
+	r, retryable, _ = bufferForRetry(strings.NewReader("hello world"), 5)
+	if retryable {
+		t.Errorf("Expected large content not to be retryable")
+	}
+	if b, _ := io.ReadAll(r); string(b) != "hello world" {
+		t.Errorf("Expected the full content, but got: %s", b)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"google.golang.org/api/drive/v3"
+)
+
+// usageCacheTTL is how long /usage results are reused. Summing sizes
+// lists every file, so repeated requests shouldn't redo the work.
+const usageCacheTTL = 5 * time.Minute
+
+// monthUsage is the total size of the files in one month folder.
+type monthUsage struct {
+	Month    string
+	FolderID string
+	Bytes    int64
+}
+
+type usageCacheEntry struct {
+	rootName string
+	months   []monthUsage
+	expires  time.Time
+}
+
+var (
+	usageCacheMu sync.Mutex
+	usageCache   = map[string]usageCacheEntry{}
+)
+
+func handleUsageCommand(ctx context.Context, bot botClient, replyToken, userID string) {
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+
+	months, err := getCachedUsage(ctx, srv, userID, userRootFolderName(ctx, userID), time.Now())
+	if err != nil {
+		log.Printf("Failed to get usage for user %s: %v", userID, err)
+		replyForError(ctx, bot, replyToken, userID, err)
+		return
+	}
+
+	if len(months) == 0 {
+		replyText(bot, replyToken, tr(ctx, userID, "files.none"))
+		return
+	}
+
+	if _, err := bot.ReplyMessage(
+		&messaging_api.ReplyMessageRequest{
+			ReplyToken: replyToken,
+			Messages: []messaging_api.MessageInterface{
+				&messaging_api.FlexMessage{
+					AltText:  tr(ctx, userID, "usage.alt"),
+					Contents: buildUsageBubble(userLanguage(ctx, userID), months),
+				},
+			},
+		},
+	); err != nil {
+		log.Print(err)
+	}
+}
+
+// getCachedUsage returns the user's month usage, computing it only when
+// the cached copy is missing, older than usageCacheTTL or was computed for
+// a different root folder.
+func getCachedUsage(ctx context.Context, srv *drive.Service, userID, rootName string, now time.Time) ([]monthUsage, error) {
+	usageCacheMu.Lock()
+	entry, ok := usageCache[userID]
+	usageCacheMu.Unlock()
+	if ok && entry.rootName == rootName && now.Before(entry.expires) {
+		return entry.months, nil
+	}
+
+	months, err := getMonthUsage(ctx, srv, rootName)
+	if err != nil {
+		return nil, err
+	}
+
+	usageCacheMu.Lock()
+	usageCache[userID] = usageCacheEntry{rootName: rootName, months: months, expires: now.Add(usageCacheTTL)}
+	usageCacheMu.Unlock()
+	return months, nil
+}
+
+// getMonthUsage sums the file sizes in every month folder under the
+// rootName folder, largest first.
+func getMonthUsage(ctx context.Context, srv *drive.Service, rootName string) ([]monthUsage, error) {
+	mainFolderID, err := findOrCreateFolder(ctx, srv, rootName, "root")
+	if err != nil {
+		return nil, fmt.Errorf("could not find or create the main upload folder: %w", err)
+	}
+
+	query := fmt.Sprintf("mimeType='application/vnd.google-apps.folder' and trashed=false and '%s' in parents", mainFolderID)
+	r, err := scopeFileList(srv.Files.List()).Q(query).PageSize(1000).Fields("files(id, name)").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list month folders: %w", err)
+	}
+
+	var months []monthUsage
+	for _, folder := range r.Files {
+		size, err := sumFolderSize(ctx, srv, folder.Id)
+		if err != nil {
+			return nil, err
+		}
+		months = append(months, monthUsage{Month: folder.Name, FolderID: folder.Id, Bytes: size})
+	}
+	sort.SliceStable(months, func(i, j int) bool { return months[i].Bytes > months[j].Bytes })
+	return months, nil
+}
+
+// sumFolderSize adds up the sizes of the non-trashed files directly inside
+// a folder, following all result pages.
+func sumFolderSize(ctx context.Context, srv *drive.Service, folderID string) (int64, error) {
+	query := fmt.Sprintf("'%s' in parents and trashed=false", folderID)
+	var total int64
+	pageToken := ""
+	for {
+		call := scopeFileList(srv.Files.List()).Q(query).PageSize(1000).Fields("nextPageToken, files(size)")
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		r, err := call.Context(ctx).Do()
+		if err != nil {
+			return 0, fmt.Errorf("failed to list files in folder '%s': %w", folderID, err)
+		}
+		for _, f := range r.Files {
+			total += f.Size
+		}
+		if r.NextPageToken == "" {
+			return total, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// formatBytes renders n using binary units, e.g. "3.2 GB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// buildUsageBubble renders the month usage as a list. Tapping a row opens
+// that month's folder in Drive.
+func buildUsageBubble(lang string, months []monthUsage) *messaging_api.FlexBubble {
+	contents := []messaging_api.FlexComponentInterface{
+		&messaging_api.FlexText{
+			Text:   translate(lang, "usage.title"),
+			Weight: "bold",
+			Size:   "xl",
+		},
+	}
+	for _, m := range months {
+		contents = append(contents,
+			&messaging_api.FlexSeparator{Margin: "md"},
+			&messaging_api.FlexBox{
+				Layout: "horizontal",
+				Margin: "md",
+				Action: &messaging_api.UriAction{
+					Label: m.Month,
+					Uri:   "https://drive.google.com/drive/folders/" + m.FolderID,
+				},
+				Contents: []messaging_api.FlexComponentInterface{
+					&messaging_api.FlexText{
+						Text:  m.Month,
+						Size:  "md",
+						Color: cardAccentColor,
+						Flex:  3,
+					},
+					&messaging_api.FlexText{
+						Text:  formatBytes(m.Bytes),
+						Size:  "md",
+						Align: "end",
+						Flex:  2,
+					},
+				},
+			},
+		)
+	}
+
+	return &messaging_api.FlexBubble{
+		Body: &messaging_api.FlexBox{
+			Layout:   "vertical",
+			Contents: contents,
+		},
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestSumFolderSize tests that sumFolderSize follows page tokens.
+func TestSumFolderSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("pageToken") == "" {
+			json.NewEncoder(w).Encode(&drive.FileList{
+				Files:         []*drive.File{{Size: 100}, {Size: 200}},
+				NextPageToken: "page2",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Size: 50}}})
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	size, err := sumFolderSize(context.Background(), srv, "folder_id")
+	if err != nil {
+		t.Errorf("Expected no error, but got: %v", err)
+	}
+	if size != 350 {
+		t.Errorf("Expected 350 bytes, but got: %d", size)
+	}
+}
+
+// TestFormatBytes tests rendering sizes with binary units.
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{5 << 20, "5.0 MB"},
+		{3435973837, "3.2 GB"},
+		{1 << 40, "1.0 TB"},
+		{5 << 50, "5120.0 TB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.in); got != tt.want {
+			t.Errorf("formatBytes(%d): expected '%s', but got: '%s'", tt.in, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// userRateLimiter is a per-user token bucket: each user may make up to
+// burst requests at once, refilled at burst per interval. A zero burst
+// disables the limit.
+type userRateLimiter struct {
+	burst    int
+	interval time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	at     time.Time
+}
+
+// connectLimiter throttles /connect_drive and /reconnect, each of which
+// writes a new OAuth state document.
+var connectLimiter = newUserRateLimiter(5, time.Minute)
+
+func newUserRateLimiter(burst int, interval time.Duration) *userRateLimiter {
+	return &userRateLimiter{burst: burst, interval: interval, buckets: map[string]tokenBucket{}}
+}
+
+// allow takes a token from the user's bucket, reporting false when it is
+// empty.
+func (l *userRateLimiter) allow(userID string, now time.Time) bool {
+	if l.burst <= 0 || l.interval <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[userID]
+	if !ok {
+		b = tokenBucket{tokens: float64(l.burst), at: now}
+	}
+	b.tokens = l.refill(b, now)
+	b.at = now
+	if b.tokens < 1 {
+		l.buckets[userID] = b
+		return false
+	}
+	b.tokens--
+	l.buckets[userID] = b
+	l.prune(now)
+	return true
+}
+
+// refill returns the bucket's tokens after the time elapsed since it was
+// last touched, capped at burst.
+func (l *userRateLimiter) refill(b tokenBucket, now time.Time) float64 {
+	elapsed := now.Sub(b.at)
+	if elapsed <= 0 {
+		return b.tokens
+	}
+	tokens := b.tokens + float64(l.burst)*elapsed.Seconds()/l.interval.Seconds()
+	if tokens > float64(l.burst) {
+		tokens = float64(l.burst)
+	}
+	return tokens
+}
+
+// prune drops buckets that have refilled completely; they behave the same
+// as a missing bucket. It only runs once the map has grown, to keep allow
+// cheap.
+func (l *userRateLimiter) prune(now time.Time) {
+	if len(l.buckets) < 1000 {
+		return
+	}
+	for userID, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.burst) {
+			delete(l.buckets, userID)
+		}
+	}
+}
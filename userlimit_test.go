@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestUserRateLimiter tests that a user is throttled after the burst and
+// that the bucket refills over time.
+func TestUserRateLimiter(t *testing.T) {
+	l := newUserRateLimiter(5, time.Minute)
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		if !l.allow("u1", now) {
+			t.Fatalf("Expected attempt %d to be allowed", i+1)
+		}
+	}
+	if l.allow("u1", now) {
+		t.Error("Expected the sixth attempt within a minute to be throttled")
+	}
+	if !l.allow("u2", now) {
+		t.Error("Expected other users not to be affected")
+	}
+
+	// One token refills every 12 seconds.
+	if l.allow("u1", now.Add(11*time.Second)) {
+		t.Error("Expected no token before 12 seconds")
+	}
+	if !l.allow("u1", now.Add(13*time.Second)) {
+		t.Error("Expected one token after 12 seconds")
+	}
+	if l.allow("u1", now.Add(14*time.Second)) {
+		t.Error("Expected the refilled token to be used up")
+	}
+
+	// A long pause refills to the burst, but not beyond it.
+	later := now.Add(time.Hour)
+	for i := 0; i < 5; i++ {
+		if !l.allow("u1", later) {
+			t.Fatalf("Expected attempt %d after refill to be allowed", i+1)
+		}
+	}
+	if l.allow("u1", later) {
+		t.Error("Expected the bucket to be capped at the burst")
+	}
+}
+
+// TestUserRateLimiterDisabled tests that a zero burst disables the limit.
+func TestUserRateLimiterDisabled(t *testing.T) {
+	l := newUserRateLimiter(0, time.Minute)
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		if !l.allow("u1", now) {
+			t.Fatal("Expected a disabled limiter to allow every attempt")
+		}
+	}
+}
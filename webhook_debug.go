@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// redactedWebhookKeys are payload fields that grant access on their own
+// and must never reach the logs.
+var redactedWebhookKeys = map[string]bool{
+	"replyToken": true,
+	"nonce":      true,
+}
+
+// bufferRequestBody reads req.Body and replaces it with an in-memory copy,
+// so it can still be consumed by webhook.ParseRequest.
+func bufferRequestBody(req *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// redactWebhookPayload returns body with secret fields replaced. Bodies
+// that aren't valid JSON are not logged at all.
+func redactWebhookPayload(body []byte) string {
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "<unparseable payload>"
+	}
+	redactValue(payload)
+	out, err := json.Marshal(payload)
+	if err != nil {
+		return "<unparseable payload>"
+	}
+	return string(out)
+}
+
+func redactValue(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if redactedWebhookKeys[k] {
+				v[k] = "[REDACTED]"
+				continue
+			}
+			redactValue(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			redactValue(child)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRedactWebhookPayload tests that reply tokens and nonces are hidden.
+func TestRedactWebhookPayload(t *testing.T) {
+	body := `{"destination":"U0","events":[{"type":"message","replyToken":"secret-token","message":{"type":"text","text":"hi"}},{"type":"accountLink","link":{"result":"ok","nonce":"secret-nonce"}}]}`
+
+	got := redactWebhookPayload([]byte(body))
+	for _, secret := range []string{"secret-token", "secret-nonce"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("expected %q to be redacted, got: %s", secret, got)
+		}
+	}
+	if !strings.Contains(got, `"text":"hi"`) {
+		t.Errorf("expected message text to be kept, got: %s", got)
+	}
+
+	if got := redactWebhookPayload([]byte("not json")); got != "<unparseable payload>" {
+		t.Errorf("expected unparseable marker, but got: %s", got)
+	}
+}
+
+// TestBufferRequestBody tests that the body can be read again afterwards.
+func TestBufferRequestBody(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"events":[]}`))
+
+	body, err := bufferRequestBody(req)
+	if err != nil {
+		t.Fatalf("bufferRequestBody failed: %v", err)
+	}
+	again, _ := io.ReadAll(req.Body)
+	if string(body) != `{"events":[]}` || string(again) != string(body) {
+		t.Errorf("expected body to be restored, got '%s' and '%s'", body, again)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// handleWhereCommand tells the user where their next uploads will go.
+func handleWhereCommand(ctx context.Context, bot botClient, replyToken, userID string) {
+	srv, ok := getDriveServiceOrPrompt(ctx, bot, replyToken, userID)
+	if !ok {
+		return
+	}
+	prefs, err := getUserPrefs(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load prefs for user %s: %v", userID, err)
+		replyText(bot, replyToken, tr(ctx, userID, "error.read_settings"))
+		return
+	}
+
+	text, err := describeDestination(ctx, srv, userLanguage(ctx, userID), prefs, time.Now())
+	if err != nil {
+		log.Printf("Failed to resolve destination for user %s: %v", userID, err)
+		if !replyForError(ctx, bot, replyToken, userID, err) {
+			replyText(bot, replyToken, tr(ctx, userID, "error.lookup_folders"))
+		}
+		return
+	}
+	replyText(bot, replyToken, text)
+}
+
+// describeDestination explains where uploads go, mirroring the order
+// uploadToDrive resolves them in: type routes, then the folder chosen with
+// /choose_folder, then the date folder. It looks folders up without
+// creating them.
+func describeDestination(ctx context.Context, srv *drive.Service, lang string, prefs *userPrefs, now time.Time) (string, error) {
+	rootName := rootFolderName(prefs)
+	mainFolderID, err := findFolder(ctx, srv, rootName, "root")
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(translate(lang, "where.header"))
+
+	dest := ""
+	if prefs.DestinationFolderID != "" {
+		folder, err := srv.Files.Get(prefs.DestinationFolderID).SupportsAllDrives(supportsAllDrives()).Fields("id, name, trashed").Context(ctx).Do()
+		if err == nil && !folder.Trashed {
+			dest = fmt.Sprintf("%s\n%s", folder.Name, folderURL(folder.Id))
+		} else {
+			sb.WriteString("\n" + translate(lang, "where.folder_gone"))
+		}
+	}
+	if dest == "" {
+		dateFolder := dateFolderName(now)
+		if dateFolder == "" {
+			dest = rootName
+			if mainFolderID != "" {
+				dest += "\n" + folderURL(mainFolderID)
+			}
+		} else {
+			dest = rootName + "/" + dateFolder
+			if mainFolderID != "" {
+				dateID, err := findFolder(ctx, srv, dateFolder, mainFolderID)
+				if err != nil {
+					return "", err
+				}
+				if dateID != "" {
+					dest += "\n" + folderURL(dateID)
+				}
+			}
+		}
+	}
+	sb.WriteString("\n" + dest)
+
+	var names []string
+	for name, mediaType := range routeTypes {
+		if _, ok := prefs.Routes[mediaType]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		sb.WriteString("\n\n" + translate(lang, "where.routes"))
+		for _, name := range names {
+			fmt.Fprintf(&sb, "\n%s → %s/%s", name, rootName, prefs.Routes[routeTypes[name]])
+		}
+	}
+	return sb.String(), nil
+}
+
+// folderURL links to a folder in the Drive web UI.
+func folderURL(folderID string) string {
+	return "https://drive.google.com/drive/folders/" + folderID
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestDescribeDestination tests the default month folder and routes.
+func TestDescribeDestination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet || r.URL.Path != "/files" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		q := r.URL.Query().Get("q")
+		switch {
+		case strings.Contains(q, "'root' in parents"):
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "main_id"}}})
+		default:
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{{Id: "month_id"}}})
+		}
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+
+	prefs := &userPrefs{Routes: map[string]string{mediaTypeImage: "Photos"}}
+	got, err := describeDestination(context.Background(), srv, langZh, prefs, time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	for _, want := range []string{mainFolderName + "/2024-03", folderURL("month_id"), "images → " + mainFolderName + "/Photos"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in reply, got: %s", want, got)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// driveUserCacheTTL is how long /whoami reuses the account it looked up.
+// Connecting or disconnecting drops the cached account right away.
+const driveUserCacheTTL = time.Hour
+
+type driveUserCacheEntry struct {
+	user    *drive.User
+	expires time.Time
+}
+
+var (
+	driveUserCacheMu sync.Mutex
+	driveUserCache   = map[string]driveUserCacheEntry{}
+)
+
+// handleWhoamiCommand replies with the Google account the user connected.
+func handleWhoamiCommand(ctx context.Context, bot botClient, replyToken, userID string) {
+	srv, err := getGoogleDriveService(ctx, userID)
+	if errors.Is(err, ErrTokenNotFound) {
+		replyText(bot, replyToken, trf(ctx, userID, "whoami.not_connected", commandText("connect_drive")))
+		return
+	}
+	if err == nil {
+		var user *drive.User
+		if user, err = getCachedDriveUser(ctx, srv, userID, time.Now()); err == nil {
+			replyText(bot, replyToken, trf(ctx, userID, "whoami.connected", user.DisplayName, user.EmailAddress))
+			return
+		}
+	}
+
+	log.Printf("Failed to look up Google account for user %s: %v", userID, err)
+	driveServices.invalidateOnAuthError(userID, err)
+	if !replyForError(ctx, bot, replyToken, userID, err) {
+		replyText(bot, replyToken, tr(ctx, userID, "whoami.failed"))
+	}
+}
+
+// getCachedDriveUser returns the Google account behind srv, calling
+// About.Get only when the cached copy is missing or expired.
+func getCachedDriveUser(ctx context.Context, srv *drive.Service, userID string, now time.Time) (*drive.User, error) {
+	driveUserCacheMu.Lock()
+	entry, ok := driveUserCache[userID]
+	driveUserCacheMu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.user, nil
+	}
+
+	about, err := srv.About.Get().Fields("user").Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	if about.User == nil {
+		return nil, errors.New("drive returned no user")
+	}
+
+	driveUserCacheMu.Lock()
+	driveUserCache[userID] = driveUserCacheEntry{user: about.User, expires: now.Add(driveUserCacheTTL)}
+	driveUserCacheMu.Unlock()
+	return about.User, nil
+}
+
+// forgetDriveUser drops the cached account after the user's token was
+// replaced or revoked.
+func forgetDriveUser(userID string) {
+	driveUserCacheMu.Lock()
+	delete(driveUserCache, userID)
+	driveUserCacheMu.Unlock()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// TestHandleWhoamiCommand tests that the About response is parsed into the
+// reply and cached for the next /whoami.
+func TestHandleWhoamiCommand(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/about" || r.URL.Query().Get("fields") != "user" {
+			t.Errorf("Unexpected request: %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"user": {"displayName": "Alice Chen", "emailAddress": "alice@example.com"}}`))
+	}))
+	defer server.Close()
+
+	srv, err := drive.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create mock drive service: %v", err)
+	}
+	original := driveServices
+	defer func() { driveServices = original }()
+	driveServices = newDriveServiceCache(time.Minute, func(ctx context.Context, userID string) (*drive.Service, error) {
+		return srv, nil
+	})
+	userID := "whoami-user"
+	defer forgetDriveUser(userID)
+
+	bot, texts := newReplyRecorder(t)
+	handleWhoamiCommand(context.Background(), bot, "token1", userID)
+	handleWhoamiCommand(context.Background(), bot, "token2", userID)
+
+	want := "已連結的 Google 帳號：Alice Chen (alice@example.com)"
+	if len(*texts) != 2 || (*texts)[0] != want || (*texts)[1] != want {
+		t.Errorf("Expected %q twice, but got: %q", want, *texts)
+	}
+	if requests != 1 {
+		t.Errorf("Expected the account to be cached, but got %d About requests", requests)
+	}
+}
+
+// TestHandleWhoamiCommandNotConnected tests the reply for users without a
+// stored token.
+func TestHandleWhoamiCommandNotConnected(t *testing.T) {
+	original := driveServices
+	defer func() { driveServices = original }()
+	driveServices = newDriveServiceCache(time.Minute, func(ctx context.Context, userID string) (*drive.Service, error) {
+		return nil, ErrTokenNotFound
+	})
+
+	bot, texts := newReplyRecorder(t)
+	handleWhoamiCommand(context.Background(), bot, "token", "whoami-user")
+
+	want := translate(langZh, "whoami.not_connected")
+	if len(*texts) != 1 || (*texts)[0] != fmt.Sprintf(want, commandText("connect_drive")) {
+		t.Errorf("Expected the not connected reply, but got: %q", *texts)
+	}
+}